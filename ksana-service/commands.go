@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"ksana-service/internal"
+	"ksana-service/internal/api"
+	"ksana-service/internal/auth"
+	"ksana-service/internal/manifest"
+	"ksana-service/internal/store"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runCommand 处理子命令，返回 false 表示未识别，按服务模式启动
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	var err error
+	switch args[0] {
+	case "migrate":
+		err = runMigrate(args[1:])
+	case "apply":
+		err = runApply(args[1:])
+	case "keygen":
+		err = runKeygen(args[1:])
+	default:
+		return false
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	return true
+}
+
+func runMigrate(args []string) error {
+	config := internal.LoadConfigFromEnv()
+
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dataDir := flags.String("data-dir", config.DataDir, "data directory containing jobs.json / snapshot.json")
+	dryRun := flags.Bool("dry-run", false, "print the planned migration without writing any file")
+	flags.Parse(args)
+
+	results, err := store.Migrate(*dataDir, *dryRun)
+	for _, result := range results {
+		if !result.Migrated() {
+			fmt.Printf("%s: up to date (version %d)\n", result.Path, result.ToVersion)
+			continue
+		}
+
+		fmt.Printf("%s: version %d -> %d\n", result.Path, result.FromVersion, result.ToVersion)
+		for _, step := range result.Steps {
+			fmt.Printf("  [%d -> %d] %s\n", step.From, step.To, step.Description)
+			for _, change := range step.Changes {
+				fmt.Printf("    - %s\n", change)
+			}
+		}
+		if result.BackupPath != "" {
+			fmt.Printf("  backup: %s\n", result.BackupPath)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("no store files found in %s\n", *dataDir)
+	} else if *dryRun {
+		fmt.Println("dry run: no files were changed")
+	}
+	return nil
+}
+
+func runApply(args []string) error {
+	config := internal.LoadConfigFromEnv()
+
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	dir := flags.String("f", "", "directory containing job manifest files (*.json)")
+	server := flags.String("server", "http://localhost:"+config.Port, "ksana service base URL")
+	apiKey := flags.String("api-key", os.Getenv("KSANA_API_KEY"), "API key (defaults to $KSANA_API_KEY)")
+	owner := flags.String("owner", manifest.DefaultOwner, "ownership marker written to managed_by; only jobs with this marker are updated or pruned")
+	prune := flags.Bool("prune", false, "delete managed jobs that are no longer present in the manifests")
+	dryRun := flags.Bool("dry-run", false, "print the plan and diff without changing anything")
+	flags.Parse(args)
+
+	if *dir == "" {
+		return errors.New("-f <dir> is required")
+	}
+
+	jobs, err := manifest.LoadDir(*dir)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(api.ApplyRequest{
+		Owner:  *owner,
+		Prune:  *prune,
+		DryRun: *dryRun,
+		Jobs:   jobs,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(*server, "/")+"/apply", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", *apiKey)
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr api.ErrorResponse
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s: %s", apiErr.Error, apiErr.Message)
+		}
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	var result api.ApplyResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+
+	printApplyResult(&result)
+
+	if result.Failed > 0 {
+		return fmt.Errorf("%d action(s) failed", result.Failed)
+	}
+	return nil
+}
+
+func printApplyResult(result *api.ApplyResponse) {
+	symbols := map[string]string{
+		manifest.OpCreate:    "+",
+		manifest.OpUpdate:    "~",
+		manifest.OpDelete:    "-",
+		manifest.OpUnchanged: "=",
+	}
+
+	for _, action := range result.Actions {
+		line := fmt.Sprintf("%s %s %s", symbols[action.Op], action.Op, action.Key)
+		if action.JobID != "" {
+			line += " (" + action.JobID + ")"
+		}
+		if action.Error != "" {
+			line += ": FAILED: " + action.Error
+		}
+		fmt.Println(line)
+
+		if action.Op != manifest.OpUpdate {
+			continue
+		}
+		for _, change := range action.Changes {
+			fmt.Printf("    %s: %s -> %s\n", change.Path, formatValue(change.Before), formatValue(change.After))
+		}
+	}
+
+	for _, key := range result.Orphaned {
+		fmt.Printf("! orphaned %s (not in manifests, use -prune to delete)\n", key)
+	}
+
+	fmt.Printf("\n%d to create, %d to update, %d to delete, %d unchanged\n",
+		result.Summary[manifest.OpCreate],
+		result.Summary[manifest.OpUpdate],
+		result.Summary[manifest.OpDelete],
+		result.Summary[manifest.OpUnchanged])
+	if result.DryRun {
+		fmt.Println("dry run: no jobs were changed")
+	}
+}
+
+func formatValue(value interface{}) string {
+	if value == nil {
+		return "<none>"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func runKeygen(args []string) error {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	name := flags.String("name", "", "human readable key name, e.g. monitoring")
+	owner := flags.String("owner", "", "team or person responsible for the key")
+	expires := flags.String("expires", "", "expiry as a date (2027-01-01), RFC 3339 time, or relative (90d, 720h); empty means never")
+	namespaces := flags.String("namespaces", "", "comma separated namespaces the key may access (default all)")
+	scopes := flags.String("scopes", "", "comma separated scopes: jobs:read, jobs:write, jobs:run, admin (default admin)")
+	flags.Parse(args)
+
+	spec := auth.KeySpec{Name: *name, Owner: *owner}
+	if *namespaces != "" {
+		spec.Namespaces = strings.Split(*namespaces, ",")
+	}
+	if *scopes != "" {
+		spec.Scopes = strings.Split(*scopes, ",")
+	}
+	if *expires != "" {
+		t, err := parseExpiry(*expires, time.Now())
+		if err != nil {
+			return err
+		}
+		spec.Expires = t
+	}
+
+	key, line, err := auth.GenerateKey(spec)
+	if err != nil {
+		return err
+	}
+
+	// 密钥输出到 stderr，文件行输出到 stdout，便于 `keygen >> api_keys.txt`
+	fmt.Fprintf(os.Stderr, "API key (shown only once, store it securely): %s\n", key)
+	fmt.Fprintln(os.Stderr, "Add the following line to the API keys file:")
+	fmt.Println(line)
+	return nil
+}
+
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return now.AddDate(0, 0, n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry %q", value)
+}
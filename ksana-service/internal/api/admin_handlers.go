@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"ksana-service/internal/backup"
+	"ksana-service/internal/revision"
+	"net/http"
+)
+
+func (h *JobHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnrestricted(w, r) {
+		return
+	}
+
+	manifest, err := h.backups.Create(r.Context(), backup.TriggerManual)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create backup", err.Error())
+		return
+	}
+
+	noteAudit(r).target = manifest.ID
+	h.writeJSON(w, http.StatusCreated, manifest)
+}
+
+func (h *JobHandler) ListBackups(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnrestricted(w, r) {
+		return
+	}
+
+	manifests, err := h.backups.List()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list backups", err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, manifests)
+}
+
+func (h *JobHandler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnrestricted(w, r) {
+		return
+	}
+
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+	if req.ID == "" {
+		h.writeError(w, http.StatusBadRequest, "Validation failed", "id is required")
+		return
+	}
+	noteAudit(r).target = req.ID
+
+	manifest, err := h.backups.Restore(r.Context(), req.ID)
+	switch {
+	case errors.Is(err, backup.ErrNotFound):
+		h.writeError(w, http.StatusNotFound, "Backup not found", err.Error())
+		return
+	case errors.Is(err, backup.ErrInvalid):
+		h.writeError(w, http.StatusUnprocessableEntity, "Invalid backup", err.Error())
+		return
+	case err != nil:
+		h.writeStoreError(w, err, "Failed to restore backup")
+		return
+	}
+
+	// 修订历史不在备份范围内：为每个恢复后的任务追加一条 restore 修订，历史中可以看到恢复后的定义
+	defs, err := h.store.ListDefinitions(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list restored jobs for revision history", "backup_id", manifest.ID, "error", err)
+	}
+	for i := range defs {
+		h.recordRevision(r, revision.ActionRestore, &defs[i], 0)
+	}
+
+	h.logger.Info("Backup restored via API", "backup_id", manifest.ID, "client_ip", getClientIP(r))
+	h.writeJSON(w, http.StatusOK, manifest)
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/audit"
+	"ksana-service/internal/manifest"
+	"ksana-service/internal/model"
+	"ksana-service/internal/revision"
+	"net/http"
+)
+
+// Apply 按声明式清单同步任务：先生成完整计划，存在校验错误或归属冲突时不做任何修改
+func (h *JobHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	var req ApplyRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+
+	for i := range req.Jobs {
+		ns, err := resolveNamespace(r, req.Jobs[i].Namespace)
+		if err != nil {
+			h.writeNamespaceError(w, fmt.Errorf("job %q: %w", req.Jobs[i].Key, err))
+			return
+		}
+		req.Jobs[i].Namespace = ns
+	}
+
+	if !req.DryRun {
+		h.createMu.Lock()
+		defer h.createMu.Unlock()
+	}
+
+	current, err := h.accessibleDefinitions(r, req.Jobs)
+	if err != nil {
+		if errors.Is(err, manifest.ErrOwnershipConflict) {
+			h.writeError(w, http.StatusConflict, "Ownership conflict", err.Error())
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Failed to list jobs", err.Error())
+		return
+	}
+
+	plan, err := manifest.BuildPlan(req.Jobs, current, req.Owner, req.Prune, h.namespaces)
+	if err != nil {
+		if errors.Is(err, manifest.ErrOwnershipConflict) {
+			h.writeError(w, http.StatusConflict, "Ownership conflict", err.Error())
+			return
+		}
+		h.writeValidationError(w, err)
+		return
+	}
+
+	if err := h.checkPlanJobCounts(r, plan); err != nil {
+		h.writeValidationError(w, err)
+		return
+	}
+	if err := h.checkPlanSecrets(plan); err != nil {
+		h.writeValidationError(w, err)
+		return
+	}
+
+	resp := ApplyResponse{
+		Owner:    plan.Owner,
+		Prune:    plan.Prune,
+		DryRun:   req.DryRun,
+		Actions:  []ApplyActionResponse{},
+		Orphaned: plan.Orphaned,
+		Summary: map[string]int{
+			manifest.OpCreate:    plan.Count(manifest.OpCreate),
+			manifest.OpUpdate:    plan.Count(manifest.OpUpdate),
+			manifest.OpDelete:    plan.Count(manifest.OpDelete),
+			manifest.OpUnchanged: plan.Count(manifest.OpUnchanged),
+		},
+	}
+
+	note := noteAudit(r)
+	note.dryRun = req.DryRun
+	for i := range plan.Actions {
+		action := &plan.Actions[i]
+		action.Changes = redactHeaderChanges(action.Changes)
+		result := ApplyActionResponse{
+			Op:        action.Op,
+			Key:       action.Key,
+			Namespace: action.Namespace,
+			JobID:     action.JobID,
+			Name:      action.Name,
+			Changes:   action.Changes,
+		}
+
+		if !req.DryRun {
+			if err := h.applyAction(r, action); err != nil {
+				h.logger.Error("Failed to apply manifest action", "op", action.Op, "key", action.Key, "error", err)
+				result.Error = err.Error()
+				resp.Failed++
+			}
+			if action.Op == manifest.OpCreate && action.Definition != nil {
+				result.JobID = action.Definition.ID
+			}
+		}
+
+		resp.Actions = append(resp.Actions, result)
+		if action.Op != manifest.OpUnchanged && result.Error == "" {
+			note.jobs = append(note.jobs, audit.JobChange{JobID: result.JobID, Op: action.Op, Changes: action.Changes})
+		}
+	}
+
+	if !req.DryRun {
+		h.logger.Info("Manifest applied",
+			"owner", plan.Owner,
+			"created", resp.Summary[manifest.OpCreate],
+			"updated", resp.Summary[manifest.OpUpdate],
+			"deleted", resp.Summary[manifest.OpDelete],
+			"failed", resp.Failed)
+	}
+
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// accessibleDefinitions 返回调用方可访问的任务定义，清单只能管理和清理这些任务；
+// 清单中的 key 已被其它命名空间的任务占用时视为归属冲突
+func (h *JobHandler) accessibleDefinitions(r *http.Request, jobs []manifest.Job) ([]model.JobDefinition, error) {
+	defs, err := h.store.ListDefinitions(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool, len(jobs))
+	for i := range jobs {
+		keys[jobs[i].Key] = true
+	}
+
+	accessible := defs[:0:0]
+	for i := range defs {
+		if canAccess(r, defs[i].Namespace) {
+			accessible = append(accessible, defs[i])
+			continue
+		}
+		if defs[i].Key != "" && keys[defs[i].Key] {
+			return nil, fmt.Errorf("job %q: %w (used in another namespace)", defs[i].Key, manifest.ErrOwnershipConflict)
+		}
+	}
+	return accessible, nil
+}
+
+// checkPlanJobCounts 按命名空间统计计划新增与删除的任务数，净增加时检查任务数上限
+func (h *JobHandler) checkPlanJobCounts(r *http.Request, plan *manifest.Plan) error {
+	delta := make(map[string]int)
+	for _, action := range plan.Actions {
+		switch action.Op {
+		case manifest.OpCreate:
+			delta[action.Namespace]++
+		case manifest.OpDelete:
+			delta[action.Namespace]--
+		}
+	}
+
+	for ns, adding := range delta {
+		if adding <= 0 {
+			continue
+		}
+		if err := h.checkJobCount(r.Context(), ns, adding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPlanSecrets 校验计划中新建或修改的任务引用的密文，任何一项不通过时整个清单都不应用
+func (h *JobHandler) checkPlanSecrets(plan *manifest.Plan) error {
+	for _, action := range plan.Actions {
+		if action.Definition == nil {
+			continue
+		}
+		if err := h.checkSecretRefs(action.Definition); err != nil {
+			return fmt.Errorf("job %q: %w", action.Key, err)
+		}
+	}
+	return nil
+}
+
+// applyAction 执行单个计划项；更新和删除以生成计划时的 resource_version 做并发检查
+func (h *JobHandler) applyAction(r *http.Request, action *manifest.Action) error {
+	switch action.Op {
+	case manifest.OpCreate:
+		job := &model.Job{JobDefinition: *action.Definition}
+		if err := h.store.PutDefinition(r.Context(), &job.JobDefinition); err != nil {
+			return err
+		}
+		*action.Definition = job.JobDefinition
+
+		if err := h.scheduler.AddJob(job); err != nil {
+			h.logger.Error("Failed to add job to scheduler", "job_id", job.ID, "error", err)
+		}
+		h.recordRevision(r, revision.ActionCreate, &job.JobDefinition, 0)
+
+	case manifest.OpUpdate:
+		job, err := h.store.Get(r.Context(), action.JobID)
+		if err != nil {
+			return err
+		}
+		job.JobDefinition = *action.Definition
+
+		if err := h.store.CompareAndPutDefinition(r.Context(), &job.JobDefinition, action.ExpectedVersion); err != nil {
+			return err
+		}
+
+		if err := h.scheduler.UpdateJob(job); err != nil {
+			h.logger.Error("Failed to update job in scheduler", "job_id", job.ID, "error", err)
+		}
+		h.recordRevision(r, revision.ActionUpdate, &job.JobDefinition, 0)
+
+	case manifest.OpDelete:
+		job, err := h.store.Get(r.Context(), action.JobID)
+		if err != nil {
+			return err
+		}
+		return h.removeJob(r, job, &action.ExpectedVersion, false)
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"ksana-service/internal/audit"
+	"ksana-service/internal/diff"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+
+	// maxAuditErrorBody 为记录失败原因时最多读取的响应体长度
+	maxAuditErrorBody = 4096
+)
+
+// auditNote 供处理函数补充审计信息：路径中没有的目标任务、非任务目标、批量变更等
+type auditNote struct {
+	jobID  string
+	target string
+	actor  string
+	dryRun bool
+	jobs   []audit.JobChange
+
+	// created 表示 jobID 为本次请求新建的任务，变更前的定义视为空
+	created bool
+}
+
+type auditNoteKey struct{}
+
+// noteAudit 返回当前请求的审计补充信息，未经 audited 包装的请求返回一个被丢弃的空记录
+func noteAudit(r *http.Request) *auditNote {
+	if note, ok := r.Context().Value(auditNoteKey{}).(*auditNote); ok {
+		return note
+	}
+	return &auditNote{}
+}
+
+// auditResponseWriter 记录状态码，并在失败时保留响应体开头用于提取错误信息
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status >= 400 && w.body.Len() < maxAuditErrorBody {
+		w.body.Write(data[:min(len(data), maxAuditErrorBody-w.body.Len())])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// audited 包装修改类接口：记录调用方、客户端 IP、动作、目标任务、任务定义的前后差异与结果。
+// 写入审计日志失败不影响请求本身，只记录错误日志
+func (h *JobHandler) audited(action string, next http.HandlerFunc) http.HandlerFunc {
+	return h.auditedAs(action, false, next)
+}
+
+// auditedAnonymous 用于无需 API 密钥、以令牌鉴权的接口（Webhook、执行回调）：
+// 只有处理函数确认了令牌、记下调用方后才写入审计日志，未知令牌等无效请求不会让日志无限增长
+func (h *JobHandler) auditedAnonymous(action string, next http.HandlerFunc) http.HandlerFunc {
+	return h.auditedAs(action, true, next)
+}
+
+func (h *JobHandler) auditedAs(action string, anonymous bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pathJobID := auditJobID(r)
+		before := h.auditDefinition(r.Context(), pathJobID)
+		note := &auditNote{jobID: pathJobID, target: auditTarget(r)}
+
+		recorder := &auditResponseWriter{ResponseWriter: w}
+		next(recorder, r.WithContext(context.WithValue(r.Context(), auditNoteKey{}, note)))
+		if anonymous && note.actor == "" {
+			return
+		}
+
+		entry := &audit.Entry{
+			Actor:    note.actor,
+			ClientIP: getClientIP(r),
+			Method:   r.Method,
+			Path:     auditPath(r),
+			Action:   action,
+			JobID:    note.jobID,
+			Target:   note.target,
+			DryRun:   note.dryRun,
+			Jobs:     note.jobs,
+			Status:   recorder.status,
+		}
+		if entry.Actor == "" {
+			entry.Actor = callerKeyID(r)
+		}
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+
+		switch {
+		case entry.Status < 400:
+			entry.Outcome = audit.OutcomeSuccess
+		case entry.Status == http.StatusUnauthorized || entry.Status == http.StatusForbidden:
+			entry.Outcome = audit.OutcomeDenied
+			entry.Error = auditError(&recorder.body)
+		default:
+			entry.Outcome = audit.OutcomeFailure
+			entry.Error = auditError(&recorder.body)
+		}
+
+		// 处理函数事后才确定的任务（如 Webhook、执行回调）没有变更前的定义，不比较
+		if entry.Outcome == audit.OutcomeSuccess && !note.dryRun && note.jobID != "" &&
+			(note.jobID == pathJobID || note.created) {
+			after := h.auditDefinition(r.Context(), note.jobID)
+			changes, _ := diff.Compute(before, after)
+			if len(changes) > 0 {
+				entry.Changes = changes
+			}
+		}
+
+		if err := h.audit.Append(entry); err != nil {
+			h.logger.Error("Failed to write audit log", "action", action, "path", entry.Path, "error", err)
+		}
+	}
+}
+
+// auditDefinition 返回用于比较的任务定义，任务不存在时为 nil（创建前、删除后）
+func (h *JobHandler) auditDefinition(ctx context.Context, jobID string) interface{} {
+	if jobID == "" {
+		return nil
+	}
+	job, err := h.store.Get(ctx, jobID)
+	if err != nil {
+		return nil
+	}
+	return diffableDefinition(job.JobDefinition)
+}
+
+// auditJobID 从路径中取出目标任务 ID：/jobs/{id}/... 与 /trash/{id}/...
+func auditJobID(r *http.Request) string {
+	for _, prefix := range []string{"/jobs/", "/trash/"} {
+		if rest, ok := strings.CutPrefix(r.URL.Path, prefix); ok {
+			id, _, _ := strings.Cut(rest, "/")
+			return id
+		}
+	}
+	return ""
+}
+
+// auditTarget 从路径中取出非任务目标：Webhook、执行记录、密钥与 API 密钥的 ID 或名称
+func auditTarget(r *http.Request) string {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) >= 4 && parts[0] == "jobs" && parts[2] == "hooks":
+		return parts[3]
+	case len(parts) >= 2 && (parts[0] == "runs" || parts[0] == "secrets"):
+		return parts[1]
+	case len(parts) >= 3 && parts[0] == "admin" && parts[1] == "keys":
+		return parts[2]
+	}
+	return ""
+}
+
+// auditPath 返回记录的路径，Webhook 地址中的令牌本身就是凭据，不写入审计日志
+func auditPath(r *http.Request) string {
+	if strings.HasPrefix(r.URL.Path, "/hooks/") {
+		return "/hooks/<token>"
+	}
+	return r.URL.Path
+}
+
+func auditError(body *bytes.Buffer) string {
+	var resp ErrorResponse
+	if json.Unmarshal(body.Bytes(), &resp) == nil && resp.Error != "" {
+		if resp.Message == "" {
+			return resp.Error
+		}
+		return resp.Error + ": " + resp.Message
+	}
+	return strings.TrimSpace(body.String())
+}
+
+// noteBatchResults 记录批量操作中已生效（演练时为计划生效）的任务
+func noteBatchResults(r *http.Request, resp *BatchResponse) {
+	note := noteAudit(r)
+	note.dryRun = resp.DryRun
+	for _, result := range resp.Results {
+		if result.Status == BatchStatusOK || result.Status == BatchStatusPlanned {
+			note.jobs = append(note.jobs, audit.JobChange{JobID: result.ID, Op: resp.Action, Changes: result.Changes})
+		}
+	}
+}
+
+// ListAudit 按时间倒序返回审计记录，支持 from/to（RFC 3339）、actor、action、job_id 与 limit
+func (h *JobHandler) ListAudit(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnrestricted(w, r) {
+		return
+	}
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid query", err.Error())
+		return
+	}
+
+	entries, err := h.audit.Query(filter)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to read audit log", err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, entries)
+}
+
+// VerifyAudit 校验整条哈希链，返回第一处断裂的位置
+func (h *JobHandler) VerifyAudit(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnrestricted(w, r) {
+		return
+	}
+
+	result, err := h.audit.Verify()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to verify audit log", err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+func parseAuditFilter(r *http.Request) (audit.Filter, error) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		JobID:  query.Get("job_id"),
+		Limit:  defaultAuditLimit,
+	}
+
+	for name, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*target = t
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, fmt.Errorf("from must be before to")
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxAuditLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxAuditLimit)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
@@ -0,0 +1,329 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/auth"
+	"ksana-service/internal/diff"
+	"ksana-service/internal/model"
+	"ksana-service/internal/revision"
+	"ksana-service/internal/store"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	BatchActionPause  = "pause"
+	BatchActionResume = "resume"
+	BatchActionDelete = "delete"
+	BatchActionRunNow = "run-now"
+	BatchActionPatch  = "patch"
+
+	BatchStatusOK        = "ok"
+	BatchStatusPlanned   = "planned"
+	BatchStatusUnchanged = "unchanged"
+	BatchStatusNotFound  = "not_found"
+	BatchStatusFailed    = "failed"
+
+	maxBatchIDs = 5000
+)
+
+// batchFilterKeys 为 filter 支持的字段，与 GET /jobs 的过滤参数一致
+var batchFilterKeys = map[string]bool{
+	"selector":        true,
+	"namespace":       true,
+	"enabled":         true,
+	"last_status":     true,
+	"type":            true,
+	"name":            true,
+	"next_run_before": true,
+	"next_run_after":  true,
+}
+
+// Batch 对一组任务执行同一操作。pause/resume/patch/delete 的存储变更整批原子写入：
+// 任一任务在解析目标后被并发修改时整批拒绝（412），不会出现部分生效；run-now 逐个触发
+func (h *JobHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	var req BatchRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid batch request", err.Error())
+		return
+	}
+
+	// 批量操作所需的权限与对应的单任务接口一致
+	scope := auth.ScopeJobsWrite
+	if req.Action == BatchActionRunNow {
+		scope = auth.ScopeJobsRun
+	}
+	if !h.checkScope(w, r, auth.ScopeJobsRead) || !h.checkScope(w, r, scope) {
+		return
+	}
+
+	jobs, results, err := h.resolveBatchTargets(r, &req)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid filter", err.Error())
+		return
+	}
+
+	resp := BatchResponse{
+		Action:  req.Action,
+		DryRun:  req.DryRun,
+		Matched: len(jobs),
+		Atomic:  req.Action != BatchActionRunNow,
+		Results: results,
+	}
+
+	if req.Action == BatchActionRunNow {
+		h.batchRunNow(&req, jobs, &resp)
+		noteBatchResults(r, &resp)
+		h.writeJSON(w, http.StatusOK, resp.summarize())
+		return
+	}
+
+	// 先为所有任务计算变更并校验，任一失败时不做任何修改
+	var ops []store.BatchOp
+	var changed []*model.Job
+	var invalid []error
+	for i := range jobs {
+		job := &jobs[i]
+		result := BatchResultResponse{ID: job.ID, Name: job.Name, Status: BatchStatusPlanned}
+		before := job.JobDefinition
+
+		switch req.Action {
+		case BatchActionPause, BatchActionResume:
+			enabled := req.Action == BatchActionResume
+			if job.Enabled == enabled {
+				result.Status = BatchStatusUnchanged
+			}
+			job.Enabled = enabled
+
+		case BatchActionPatch:
+			h.applyJobUpdates(job, req.Patch)
+			if err := h.validateJob(&job.JobDefinition); err != nil {
+				result.Status = BatchStatusFailed
+				result.Error = err.Error()
+				invalid = append(invalid, fmt.Errorf("job %s: %w", job.ID, err))
+				break
+			}
+			changes, _ := diff.Compute(diffableDefinition(before), diffableDefinition(job.JobDefinition))
+			if len(changes) == 0 {
+				result.Status = BatchStatusUnchanged
+			}
+			result.Changes = changes
+		}
+
+		resp.Results = append(resp.Results, result)
+		if result.Status != BatchStatusPlanned {
+			continue
+		}
+
+		op := store.BatchOp{ExpectedVersion: before.ResourceVersion}
+		if req.Action == BatchActionDelete {
+			op.DeleteID = job.ID
+		} else {
+			op.Definition = &job.JobDefinition
+		}
+		ops = append(ops, op)
+		changed = append(changed, job)
+	}
+
+	if len(invalid) > 0 {
+		h.writeValidationError(w, errors.Join(invalid...))
+		return
+	}
+
+	if req.DryRun || len(ops) == 0 {
+		noteBatchResults(r, &resp)
+		h.writeJSON(w, http.StatusOK, resp.summarize())
+		return
+	}
+
+	if err := h.commitBatch(r, &req, ops, changed); err != nil {
+		if errors.Is(err, errTrashFailed) {
+			h.writeError(w, http.StatusInternalServerError, "Failed to move job to trash", err.Error())
+			return
+		}
+		h.writeStoreError(w, err, "Failed to apply batch")
+		return
+	}
+
+	for i := range resp.Results {
+		if resp.Results[i].Status == BatchStatusPlanned {
+			resp.Results[i].Status = BatchStatusOK
+		}
+	}
+
+	resp.summarize()
+	noteBatchResults(r, &resp)
+	h.logger.Info("Batch applied",
+		"action", req.Action,
+		"matched", resp.Matched,
+		"changed", len(ops),
+		"key_id", callerKeyID(r))
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+func (req *BatchRequest) validate() error {
+	switch req.Action {
+	case BatchActionPause, BatchActionResume, BatchActionDelete, BatchActionRunNow:
+		if req.Patch != nil {
+			return errors.New("patch is only allowed with action 'patch'")
+		}
+	case BatchActionPatch:
+		if req.Patch == nil {
+			return errors.New("action 'patch' requires a patch object")
+		}
+	default:
+		return errors.New("action must be one of pause, resume, delete, run-now, patch")
+	}
+
+	if req.Overrides != nil && req.Action != BatchActionRunNow {
+		return errors.New("overrides are only allowed with action 'run-now'")
+	}
+
+	// 不允许省略目标，避免一个空请求作用于所有任务
+	if (len(req.IDs) > 0) == (req.Filter != nil) {
+		return errors.New("exactly one of ids or filter must be given")
+	}
+	if len(req.IDs) > maxBatchIDs {
+		return fmt.Errorf("at most %d ids are allowed", maxBatchIDs)
+	}
+	for key := range req.Filter {
+		if !batchFilterKeys[key] {
+			return fmt.Errorf("unknown filter field %q", key)
+		}
+	}
+	return nil
+}
+
+// resolveBatchTargets 返回目标任务；按 ID 指定时不存在的任务记为 not_found，不影响其它任务
+func (h *JobHandler) resolveBatchTargets(r *http.Request, req *BatchRequest) ([]model.Job, []BatchResultResponse, error) {
+	results := []BatchResultResponse{}
+
+	if req.Filter != nil {
+		values := url.Values{}
+		for key, value := range req.Filter {
+			values.Set(key, value)
+		}
+		query, err := parseJobQuery(values)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		jobs, err := h.store.ListBySelector(r.Context(), query.selector)
+		if err != nil {
+			return nil, nil, err
+		}
+		matched, _, _ := query.apply(filterAccessible(r, jobs))
+		return matched, results, nil
+	}
+
+	var jobs []model.Job
+	seen := make(map[string]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		id = strings.TrimSpace(id)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		job, err := h.getJob(r, id)
+		if err != nil {
+			results = append(results, BatchResultResponse{ID: id, Status: BatchStatusNotFound, Error: err.Error()})
+			continue
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, results, nil
+}
+
+// commitBatch 整批写入存储后同步调度器、记录修订并按需取消运行中的任务；
+// 删除时先放入回收站，写入失败则全部撤回
+func (h *JobHandler) commitBatch(r *http.Request, req *BatchRequest, ops []store.BatchOp, jobs []*model.Job) error {
+	var trashed []string
+	if req.Action == BatchActionDelete && !req.Permanent && h.trash.Enabled() {
+		for _, job := range jobs {
+			if _, err := h.trash.Put(job, callerKeyID(r)); err != nil {
+				h.removeFromTrash(trashed)
+				return fmt.Errorf("%w: %v", errTrashFailed, err)
+			}
+			trashed = append(trashed, job.ID)
+		}
+	}
+
+	if err := h.store.Batch(r.Context(), ops); err != nil {
+		h.removeFromTrash(trashed)
+		return err
+	}
+
+	revisionAction := map[string]string{
+		BatchActionPause:  revision.ActionPause,
+		BatchActionResume: revision.ActionResume,
+		BatchActionPatch:  revision.ActionUpdate,
+		BatchActionDelete: revision.ActionDelete,
+	}[req.Action]
+
+	for _, job := range jobs {
+		if req.Action == BatchActionDelete {
+			h.scheduler.RemoveJob(job.ID)
+		} else if err := h.scheduler.UpdateJob(job); err != nil {
+			h.logger.Error("Failed to update job in scheduler", "job_id", job.ID, "error", err)
+		}
+
+		h.recordRevision(r, revisionAction, &job.JobDefinition, 0)
+
+		if req.CancelRunning && (req.Action == BatchActionDelete || !job.Enabled) {
+			if cancelled := h.runs.CancelJobRuns(job.ID); cancelled > 0 {
+				h.logger.Info("Cancelled in-flight runs", "job_id", job.ID, "count", cancelled)
+			}
+		}
+	}
+	return nil
+}
+
+func (h *JobHandler) removeFromTrash(ids []string) {
+	for _, id := range ids {
+		h.trash.Remove(id)
+	}
+}
+
+func (h *JobHandler) batchRunNow(req *BatchRequest, jobs []model.Job, resp *BatchResponse) {
+	for i := range jobs {
+		result := BatchResultResponse{ID: jobs[i].ID, Name: jobs[i].Name, Status: BatchStatusPlanned}
+
+		if !req.DryRun {
+			runID, err := h.scheduler.RunNow(jobs[i].ID, req.Overrides)
+			if err != nil {
+				result.Status = BatchStatusFailed
+				result.Error = err.Error()
+			} else {
+				result.Status = BatchStatusOK
+				result.RunID = runID
+			}
+		}
+
+		resp.Results = append(resp.Results, result)
+	}
+}
+
+// summarize 按状态统计结果，并让结果按 ID 排序输出
+func (resp *BatchResponse) summarize() *BatchResponse {
+	sort.SliceStable(resp.Results, func(i, j int) bool {
+		return resp.Results[i].ID < resp.Results[j].ID
+	})
+
+	resp.Summary = make(map[string]int)
+	for _, result := range resp.Results {
+		resp.Summary[result.Status]++
+	}
+	return resp
+}
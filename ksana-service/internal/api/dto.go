@@ -1,45 +1,136 @@
 package api
 
 import (
+	"ksana-service/internal/auth"
+	"ksana-service/internal/diff"
+	"ksana-service/internal/manifest"
 	"ksana-service/internal/model"
+	"ksana-service/internal/namespace"
+	"ksana-service/internal/revision"
+	"ksana-service/internal/secrets"
+	"ksana-service/internal/store"
+	"ksana-service/internal/trash"
 	"time"
 )
 
 type CreateJobRequest struct {
-	Name         string              `json:"name"`
-	Enabled      *bool               `json:"enabled,omitempty"`
-	Type         string              `json:"type"`
-	HTTP         model.HTTPConfig    `json:"http"`
-	Schedule     model.Schedule      `json:"schedule"`
-	Timeout      model.Duration      `json:"timeout,omitempty"`
-	MaxRetries   *int                `json:"max_retries,omitempty"`
-	RetryBackoff model.Duration      `json:"retry_backoff,omitempty"`
+	Namespace    string            `json:"namespace,omitempty"`
+	Name         string            `json:"name"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Enabled      *bool             `json:"enabled,omitempty"`
+	Type         string            `json:"type"`
+	HTTP         model.HTTPConfig  `json:"http"`
+	Schedule     model.Schedule    `json:"schedule"`
+	Timeout      model.Duration    `json:"timeout,omitempty"`
+	MaxRetries   *int              `json:"max_retries,omitempty"`
+	RetryBackoff model.Duration    `json:"retry_backoff,omitempty"`
+	Completion   model.Completion  `json:"completion,omitempty"`
 }
 
 type UpdateJobRequest struct {
-	Name         *string             `json:"name,omitempty"`
-	Enabled      *bool               `json:"enabled,omitempty"`
-	HTTP         *model.HTTPConfig   `json:"http,omitempty"`
-	Schedule     *model.Schedule     `json:"schedule,omitempty"`
-	Timeout      *model.Duration     `json:"timeout,omitempty"`
-	MaxRetries   *int                `json:"max_retries,omitempty"`
-	RetryBackoff *model.Duration     `json:"retry_backoff,omitempty"`
+	Name         *string            `json:"name,omitempty"`
+	Labels       *map[string]string `json:"labels,omitempty"`
+	Enabled      *bool              `json:"enabled,omitempty"`
+	HTTP         *model.HTTPConfig  `json:"http,omitempty"`
+	Schedule     *model.Schedule    `json:"schedule,omitempty"`
+	Timeout      *model.Duration    `json:"timeout,omitempty"`
+	MaxRetries   *int               `json:"max_retries,omitempty"`
+	RetryBackoff *model.Duration    `json:"retry_backoff,omitempty"`
+	Completion   *model.Completion  `json:"completion,omitempty"`
 }
 
 type JobResponse struct {
-	ID            string                 `json:"id"`
-	Name          string                 `json:"name"`
-	Enabled       bool                   `json:"enabled"`
-	Type          string                 `json:"type"`
-	HTTP          model.HTTPConfig       `json:"http"`
-	Schedule      model.Schedule         `json:"schedule"`
-	Timeout       model.Duration         `json:"timeout"`
-	MaxRetries    int                    `json:"max_retries"`
-	RetryBackoff  model.Duration         `json:"retry_backoff"`
-	LastRunAt     *time.Time             `json:"last_run_at,omitempty"`
-	NextRunAt     *time.Time             `json:"next_run_at,omitempty"`
-	LastStatus    string                 `json:"last_status"`
-	LastError     string                 `json:"last_error"`
+	JobDefinitionResponse
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt  *time.Time `json:"next_run_at,omitempty"`
+	LastStatus string     `json:"last_status"`
+	LastError  string     `json:"last_error"`
+}
+
+type JobDefinitionResponse struct {
+	ID              string            `json:"id"`
+	ResourceVersion int64             `json:"resource_version"`
+	Namespace       string            `json:"namespace"`
+	Key             string            `json:"key,omitempty"`
+	ManagedBy       string            `json:"managed_by,omitempty"`
+	Name            string            `json:"name"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Enabled         bool              `json:"enabled"`
+	Type            string            `json:"type"`
+	HTTP            model.HTTPConfig  `json:"http"`
+	Schedule        model.Schedule    `json:"schedule"`
+	Timeout         model.Duration    `json:"timeout"`
+	MaxRetries      int               `json:"max_retries"`
+	RetryBackoff    model.Duration    `json:"retry_backoff"`
+	Completion      model.Completion  `json:"completion"`
+}
+
+type CompleteRunRequest struct {
+	Status string `json:"status"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type RunNowResponse struct {
+	Message string `json:"message"`
+	RunID   string `json:"run_id"`
+}
+
+type CreateHookRequest struct {
+	Signed    bool `json:"signed"`
+	RateLimit int  `json:"rate_limit,omitempty"`
+}
+
+type HookResponse struct {
+	ID        string     `json:"id"`
+	Signed    bool       `json:"signed"`
+	RateLimit int        `json:"rate_limit"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+}
+
+type HookCredentialsResponse struct {
+	HookResponse
+	Token  string `json:"token"`
+	Secret string `json:"secret,omitempty"`
+	Path   string `json:"path"`
+	URL    string `json:"url"`
+}
+
+type RevisionResponse struct {
+	Revision        int                   `json:"revision"`
+	JobID           string                `json:"job_id"`
+	ResourceVersion int64                 `json:"resource_version"`
+	Action          string                `json:"action"`
+	KeyID           string                `json:"key_id,omitempty"`
+	At              time.Time             `json:"at"`
+	RolledBackFrom  int                   `json:"rolled_back_from,omitempty"`
+	Definition      JobDefinitionResponse `json:"definition"`
+}
+
+type RevisionDiffResponse struct {
+	JobID   string        `json:"job_id"`
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Changes []diff.Change `json:"changes"`
+}
+
+type TrashEntryResponse struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	DeletedAt time.Time   `json:"deleted_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	KeyID     string      `json:"key_id,omitempty"`
+	Job       JobResponse `json:"job"`
+}
+
+type RestoreRequest struct {
+	ID string `json:"id"`
+}
+
+type HealthResponse struct {
+	Status string        `json:"status"`
+	Store  *store.Status `json:"store,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -47,15 +138,21 @@ type ErrorResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
-func (r *CreateJobRequest) ToJob() *model.Job {
+// ToJob 按命名空间默认值与全局默认值补全未设置的字段
+func (r *CreateJobRequest) ToJob(defaults *namespace.Defaults) *model.Job {
 	job := &model.Job{
-		Name:         r.Name,
-		Type:         r.Type,
-		HTTP:         r.HTTP,
-		Schedule:     r.Schedule,
-		Timeout:      r.Timeout,
-		MaxRetries:   0,
-		RetryBackoff: r.RetryBackoff,
+		JobDefinition: model.JobDefinition{
+			Namespace:    r.Namespace,
+			Name:         r.Name,
+			Labels:       r.Labels,
+			Type:         r.Type,
+			HTTP:         r.HTTP,
+			Schedule:     r.Schedule,
+			Timeout:      r.Timeout,
+			MaxRetries:   0,
+			RetryBackoff: r.RetryBackoff,
+			Completion:   r.Completion,
+		},
 	}
 
 	if r.Enabled != nil {
@@ -66,24 +163,214 @@ func (r *CreateJobRequest) ToJob() *model.Job {
 		job.MaxRetries = *r.MaxRetries
 	}
 
+	defaults.Apply(&job.JobDefinition, r.MaxRetries != nil)
 	job.SetDefaults()
 	return job
 }
 
+func HookToResponse(hook *model.Hook) HookResponse {
+	return HookResponse{
+		ID:        hook.ID,
+		Signed:    hook.Signed(),
+		RateLimit: hook.RateLimit,
+		CreatedAt: hook.CreatedAt,
+		RotatedAt: hook.RotatedAt,
+	}
+}
+
 func JobToResponse(job *model.Job) JobResponse {
 	return JobResponse{
-		ID:            job.ID,
-		Name:          job.Name,
-		Enabled:       job.Enabled,
-		Type:          job.Type,
-		HTTP:          job.HTTP,
-		Schedule:      job.Schedule,
-		Timeout:       job.Timeout,
-		MaxRetries:    job.MaxRetries,
-		RetryBackoff:  job.RetryBackoff,
-		LastRunAt:     job.LastRunAt,
-		NextRunAt:     job.NextRunAt,
-		LastStatus:    job.LastStatus,
-		LastError:     job.LastError,
+		JobDefinitionResponse: DefinitionToResponse(&job.JobDefinition),
+		LastRunAt:             job.LastRunAt,
+		NextRunAt:             job.NextRunAt,
+		LastStatus:            job.LastStatus,
+		LastError:             job.LastError,
+	}
+}
+
+// DefinitionToResponse 转换任务定义，敏感请求头的取值以 [REDACTED] 代替
+func DefinitionToResponse(def *model.JobDefinition) JobDefinitionResponse {
+	return definitionResponse(def, maskRedacted)
+}
+
+func definitionResponse(def *model.JobDefinition, mask func(string) string) JobDefinitionResponse {
+	httpConfig := def.HTTP
+	httpConfig.Headers = redactHeaders(def.HTTP.Headers, mask)
+
+	return JobDefinitionResponse{
+		ID:              def.ID,
+		ResourceVersion: def.ResourceVersion,
+		Namespace:       def.Namespace,
+		Key:             def.Key,
+		ManagedBy:       def.ManagedBy,
+		Name:            def.Name,
+		Labels:          def.Labels,
+		Enabled:         def.Enabled,
+		Type:            def.Type,
+		HTTP:            httpConfig,
+		Schedule:        def.Schedule,
+		Timeout:         def.Timeout,
+		MaxRetries:      def.MaxRetries,
+		RetryBackoff:    def.RetryBackoff,
+		Completion:      def.Completion,
+	}
+}
+
+func RevisionToResponse(rev *revision.Revision) RevisionResponse {
+	return RevisionResponse{
+		Revision:        rev.Revision,
+		JobID:           rev.JobID,
+		ResourceVersion: rev.ResourceVersion,
+		Action:          rev.Action,
+		KeyID:           rev.KeyID,
+		At:              rev.At,
+		RolledBackFrom:  rev.RolledBackFrom,
+		Definition:      DefinitionToResponse(&rev.Definition),
+	}
+}
+
+func TrashEntryToResponse(entry *trash.Entry) TrashEntryResponse {
+	return TrashEntryResponse{
+		ID:        entry.Job.ID,
+		Name:      entry.Job.Name,
+		DeletedAt: entry.DeletedAt,
+		ExpiresAt: entry.ExpiresAt,
+		KeyID:     entry.KeyID,
+		Job:       JobToResponse(&entry.Job),
 	}
-}
\ No newline at end of file
+}
+
+type ApplyRequest struct {
+	Owner  string         `json:"owner,omitempty"`
+	Prune  bool           `json:"prune,omitempty"`
+	DryRun bool           `json:"dry_run,omitempty"`
+	Jobs   []manifest.Job `json:"jobs"`
+}
+
+type ApplyActionResponse struct {
+	Op        string        `json:"op"`
+	Key       string        `json:"key"`
+	Namespace string        `json:"namespace"`
+	JobID     string        `json:"job_id,omitempty"`
+	Name      string        `json:"name"`
+	Changes   []diff.Change `json:"changes,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+type ApplyResponse struct {
+	Owner    string                `json:"owner"`
+	Prune    bool                  `json:"prune"`
+	DryRun   bool                  `json:"dry_run"`
+	Actions  []ApplyActionResponse `json:"actions"`
+	Orphaned []string              `json:"orphaned,omitempty"`
+	Summary  map[string]int        `json:"summary"`
+	Failed   int                   `json:"failed"`
+}
+
+// PutSecretRequest 中 namespaces 为允许引用该密文的任务命名空间（"*" 表示全部），
+// 省略时更新保留原有设置，新建则只允许 default 命名空间
+type PutSecretRequest struct {
+	Value      string   `json:"value"`
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+type SecretResponse struct {
+	Name       string    `json:"name"`
+	Namespaces []string  `json:"namespaces"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type RotateKeyResponse struct {
+	KeyID   string `json:"key_id"`
+	Secrets int    `json:"secrets"`
+}
+
+func SecretToResponse(info *secrets.Info) SecretResponse {
+	return SecretResponse{
+		Name:       info.Name,
+		Namespaces: info.Namespaces,
+		CreatedAt:  info.CreatedAt,
+		UpdatedAt:  info.UpdatedAt,
+	}
+}
+
+// CreateKeyRequest 中 expires_at 与 expires_in 至多指定一个，都未指定时密钥不过期
+type CreateKeyRequest struct {
+	Name       string         `json:"name"`
+	Owner      string         `json:"owner"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	ExpiresIn  model.Duration `json:"expires_in,omitempty"`
+	Namespaces []string       `json:"namespaces,omitempty"`
+	Scopes     []string       `json:"scopes,omitempty"`
+}
+
+// CreateKeyResponse 中的 key 只在创建时返回一次
+type CreateKeyResponse struct {
+	Key string `json:"key"`
+	auth.KeyInfo
+}
+
+type WatchEventResponse struct {
+	Seq   int64        `json:"seq"`
+	Type  string       `json:"type"`
+	JobID string       `json:"job_id,omitempty"`
+	At    time.Time    `json:"at"`
+	Job   *JobResponse `json:"job,omitempty"`
+}
+
+type WatchResponse struct {
+	Epoch   string               `json:"epoch"`
+	LastSeq int64                `json:"last_seq"`
+	Events  []WatchEventResponse `json:"events"`
+}
+
+func EventToResponse(event *store.Event) WatchEventResponse {
+	resp := WatchEventResponse{
+		Seq:   event.Seq,
+		Type:  event.Type,
+		JobID: event.JobID,
+		At:    event.At,
+	}
+	if event.Job != nil {
+		job := JobToResponse(event.Job)
+		resp.Job = &job
+	}
+	return resp
+}
+
+// JobListResponse 为分页查询的响应信封，next_cursor 为空表示已是最后一页
+type JobListResponse struct {
+	Items      interface{} `json:"items"`
+	Total      int         `json:"total"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+type BatchRequest struct {
+	Action        string              `json:"action"`
+	IDs           []string            `json:"ids,omitempty"`
+	Filter        map[string]string   `json:"filter,omitempty"`
+	Patch         *UpdateJobRequest   `json:"patch,omitempty"`
+	Overrides     *model.RunOverrides `json:"overrides,omitempty"`
+	Permanent     bool                `json:"permanent,omitempty"`
+	CancelRunning bool                `json:"cancel_running,omitempty"`
+	DryRun        bool                `json:"dry_run,omitempty"`
+}
+
+type BatchResultResponse struct {
+	ID      string        `json:"id"`
+	Name    string        `json:"name,omitempty"`
+	Status  string        `json:"status"`
+	Changes []diff.Change `json:"changes,omitempty"`
+	RunID   string        `json:"run_id,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Action  string                `json:"action"`
+	DryRun  bool                  `json:"dry_run"`
+	Atomic  bool                  `json:"atomic"`
+	Matched int                   `json:"matched"`
+	Results []BatchResultResponse `json:"results"`
+	Summary map[string]int        `json:"summary"`
+}
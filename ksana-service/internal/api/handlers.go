@@ -1,33 +1,129 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"ksana-service/internal/audit"
+	"ksana-service/internal/auth"
+	"ksana-service/internal/backup"
 	"ksana-service/internal/model"
+	"ksana-service/internal/namespace"
+	"ksana-service/internal/revision"
+	"ksana-service/internal/secrets"
 	"ksana-service/internal/store"
+	"ksana-service/internal/trash"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+const maxRunNowWait = 10 * time.Minute
+
+var errTrashFailed = errors.New("failed to move job to trash")
+
 type SchedulerService interface {
 	AddJob(job *model.Job) error
 	UpdateJob(job *model.Job) error
 	RemoveJob(jobID string)
-	RunNow(jobID string) error
+	RunNow(jobID string, overrides *model.RunOverrides) (string, error)
+	Trigger(jobID, trigger string, overrides *model.RunOverrides) (string, error)
+}
+
+type RunManager interface {
+	ListRuns(state string) []model.Run
+	GetRun(runID string) (*model.Run, error)
+	CancelRun(runID string) error
+	CancelJobRuns(jobID string) int
+	CompleteRun(runID, token, status, output, errorMsg string) error
+	WaitRun(ctx context.Context, runID string) (*model.Run, error)
+}
+
+type BackupService interface {
+	Create(ctx context.Context, trigger string) (*backup.Manifest, error)
+	List() ([]backup.Manifest, error)
+	Restore(ctx context.Context, id string) (*backup.Manifest, error)
+}
+
+type RevisionStore interface {
+	Record(rev *revision.Revision) error
+	List(jobID string) ([]revision.Revision, error)
+	Get(jobID string, number int) (*revision.Revision, error)
+}
+
+type TrashService interface {
+	Enabled() bool
+	Put(job *model.Job, keyID string) (*trash.Entry, error)
+	List() ([]trash.Entry, error)
+	Get(id string) (*trash.Entry, error)
+	Remove(id string) error
+}
+
+type SecretService interface {
+	List() []secrets.Info
+	Put(name, value string, namespaces []string) (*secrets.Info, bool, error)
+	Check(namespace, name string) error
+	Delete(name string) error
+	Rotate() (string, int, error)
+}
+
+type KeyService interface {
+	Keys() []auth.KeyInfo
+	CreateKey(spec auth.KeySpec) (string, *auth.KeyInfo, error)
+	RevokeKey(id, revokedBy string) (*auth.KeyInfo, error)
+}
+
+type AuditLog interface {
+	Append(entry *audit.Entry) error
+	Query(filter audit.Filter) ([]audit.Entry, error)
+	Verify() (audit.VerifyResult, error)
 }
 
 type JobHandler struct {
-	store     store.Store
-	scheduler SchedulerService
-	logger    *slog.Logger
+	store       store.Store
+	scheduler   SchedulerService
+	runs        RunManager
+	backups     BackupService
+	revisions   RevisionStore
+	trash       TrashService
+	secrets     SecretService
+	namespaces  *namespace.Registry
+	keys        KeyService
+	audit       AuditLog
+	hookLimiter *rateLimiter
+	hooks       *hookIndex
+	publicURL   string
+	logger      *slog.Logger
+
+	unknownHookLimiter *rateLimiter
+
+	// createMu 串行化新建任务时的数量配额检查与写入
+	createMu sync.Mutex
 }
 
-func NewJobHandler(store store.Store, scheduler SchedulerService, logger *slog.Logger) *JobHandler {
+func NewJobHandler(store store.Store, scheduler SchedulerService, runs RunManager, backups BackupService, revisions RevisionStore, trash TrashService, secrets SecretService, namespaces *namespace.Registry, keys KeyService, auditLog AuditLog, publicURL string, logger *slog.Logger) *JobHandler {
 	return &JobHandler{
-		store:     store,
-		scheduler: scheduler,
-		logger:    logger,
+		store:       store,
+		scheduler:   scheduler,
+		runs:        runs,
+		backups:     backups,
+		revisions:   revisions,
+		trash:       trash,
+		secrets:     secrets,
+		namespaces:  namespaces,
+		keys:        keys,
+		audit:       auditLog,
+		hookLimiter: newRateLimiter(),
+		hooks:       newHookIndex(store),
+		publicURL:   strings.TrimSuffix(publicURL, "/"),
+		logger:      logger,
+
+		unknownHookLimiter: newRateLimiter(),
 	}
 }
 
@@ -38,13 +134,29 @@ func (h *JobHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	job := req.ToJob()
-	if err := job.Validate(); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Validation failed", err.Error())
+	ns, err := resolveNamespace(r, req.Namespace)
+	if err != nil {
+		h.writeNamespaceError(w, err)
 		return
 	}
+	req.Namespace = ns
+
+	defaults := h.namespaces.Get(ns).Defaults
+	job := req.ToJob(&defaults)
+	if err := h.validateJob(&job.JobDefinition); err != nil {
+		h.writeValidationError(w, err)
+		return
+	}
+
+	h.createMu.Lock()
+	defer h.createMu.Unlock()
 
-	if err := h.store.Put(r.Context(), job); err != nil {
+	if err := h.checkJobCount(r.Context(), ns, 1); err != nil {
+		h.writeValidationError(w, err)
+		return
+	}
+
+	if err := h.store.PutDefinition(r.Context(), &job.JobDefinition); err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to save job", err.Error())
 		return
 	}
@@ -53,22 +165,64 @@ func (h *JobHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 		h.logger.Error("Failed to add job to scheduler", "job_id", job.ID, "error", err)
 	}
 
+	h.recordRevision(r, revision.ActionCreate, &job.JobDefinition, 0)
+
+	note := noteAudit(r)
+	note.jobID, note.created = job.ID, true
+
+	setETag(w, &job.JobDefinition)
 	h.writeJSON(w, http.StatusCreated, JobToResponse(job))
 }
 
 func (h *JobHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
-	jobs, err := h.store.List(r.Context())
+	setWatchHeaders(w, h.store.Feed())
+
+	query, err := parseJobQuery(r.URL.Query())
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid query", err.Error())
+		return
+	}
+
+	jobs, err := h.store.ListBySelector(r.Context(), query.selector)
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to list jobs", err.Error())
 		return
 	}
 
-	var responses []JobResponse
-	for _, job := range jobs {
-		responses = append(responses, JobToResponse(&job))
+	page, total, nextCursor := query.apply(filterAccessible(r, jobs))
+
+	var items interface{}
+	if r.URL.Query().Get("view") == "definition" {
+		responses := []JobDefinitionResponse{}
+		for i := range page {
+			responses = append(responses, DefinitionToResponse(&page[i].JobDefinition))
+		}
+		items = responses
+	} else {
+		var responses []JobResponse
+		for _, job := range page {
+			responses = append(responses, JobToResponse(&job))
+		}
+		if responses == nil && query.envelope {
+			responses = []JobResponse{}
+		}
+		items = responses
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if !query.envelope {
+		if nextCursor != "" {
+			w.Header().Set("X-Next-Cursor", nextCursor)
+		}
+		h.writeJSON(w, http.StatusOK, items)
+		return
 	}
 
-	h.writeJSON(w, http.StatusOK, responses)
+	h.writeJSON(w, http.StatusOK, JobListResponse{
+		Items:      items,
+		Total:      total,
+		NextCursor: nextCursor,
+	})
 }
 
 func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
@@ -78,12 +232,13 @@ func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	job, err := h.store.Get(r.Context(), jobID)
+	job, err := h.getJob(r, jobID)
 	if err != nil {
 		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
 		return
 	}
 
+	setETag(w, &job.JobDefinition)
 	h.writeJSON(w, http.StatusOK, JobToResponse(job))
 }
 
@@ -94,12 +249,17 @@ func (h *JobHandler) UpdateJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	job, err := h.store.Get(r.Context(), jobID)
+	job, err := h.getJob(r, jobID)
 	if err != nil {
 		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
 		return
 	}
 
+	expected, ok := h.checkIfMatch(w, r, job)
+	if !ok {
+		return
+	}
+
 	var req UpdateJobRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.writeError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
@@ -108,13 +268,13 @@ func (h *JobHandler) UpdateJob(w http.ResponseWriter, r *http.Request) {
 
 	h.applyJobUpdates(job, &req)
 
-	if err := job.Validate(); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Validation failed", err.Error())
+	if err := h.validateJob(&job.JobDefinition); err != nil {
+		h.writeValidationError(w, err)
 		return
 	}
 
-	if err := h.store.Put(r.Context(), job); err != nil {
-		h.writeError(w, http.StatusInternalServerError, "Failed to update job", err.Error())
+	if err := h.saveDefinition(r, &job.JobDefinition, expected); err != nil {
+		h.writeStoreError(w, err, "Failed to update job")
 		return
 	}
 
@@ -122,6 +282,9 @@ func (h *JobHandler) UpdateJob(w http.ResponseWriter, r *http.Request) {
 		h.logger.Error("Failed to update job in scheduler", "job_id", job.ID, "error", err)
 	}
 
+	h.recordRevision(r, revision.ActionUpdate, &job.JobDefinition, 0)
+
+	setETag(w, &job.JobDefinition)
 	h.writeJSON(w, http.StatusOK, JobToResponse(job))
 }
 
@@ -132,15 +295,60 @@ func (h *JobHandler) DeleteJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.Delete(r.Context(), jobID); err != nil {
+	job, err := h.getJob(r, jobID)
+	if err != nil {
 		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
 		return
 	}
 
-	h.scheduler.RemoveJob(jobID)
+	expected, err := ifMatchVersion(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid If-Match header", err.Error())
+		return
+	}
+
+	permanent := r.URL.Query().Get("permanent") == "true"
+	if err := h.removeJob(r, job, expected, permanent); err != nil {
+		if errors.Is(err, errTrashFailed) {
+			h.writeError(w, http.StatusInternalServerError, "Failed to move job to trash", err.Error())
+			return
+		}
+		h.writeStoreError(w, err, "Failed to delete job")
+		return
+	}
+
+	h.cancelRunsIfRequested(r, job.ID)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// removeJob 删除任务并从调度器移除；先放入回收站再删除，删除失败时撤回，保证任务不会两边都不存在
+func (h *JobHandler) removeJob(r *http.Request, job *model.Job, expected *int64, permanent bool) error {
+	trashed := false
+	if !permanent && h.trash.Enabled() {
+		if _, err := h.trash.Put(job, callerKeyID(r)); err != nil {
+			return fmt.Errorf("%w: %v", errTrashFailed, err)
+		}
+		trashed = true
+	}
+
+	var err error
+	if expected == nil {
+		err = h.store.Delete(r.Context(), job.ID)
+	} else {
+		err = h.store.CompareAndDelete(r.Context(), job.ID, *expected)
+	}
+	if err != nil {
+		if trashed {
+			h.trash.Remove(job.ID)
+		}
+		return err
+	}
+
+	h.scheduler.RemoveJob(job.ID)
+	h.recordRevision(r, revision.ActionDelete, &job.JobDefinition, 0)
+	return nil
+}
+
 func (h *JobHandler) RunNow(w http.ResponseWriter, r *http.Request) {
 	jobID := h.extractJobID(r)
 	if jobID == "" {
@@ -148,13 +356,63 @@ func (h *JobHandler) RunNow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.scheduler.RunNow(jobID); err != nil {
+	var wait time.Duration
+	if raw := r.URL.Query().Get("wait"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 || parsed > maxRunNowWait {
+			h.writeError(w, http.StatusBadRequest, "Invalid wait duration", fmt.Sprintf("wait must be a duration between 0 and %s", maxRunNowWait))
+			return
+		}
+		wait = parsed
+	}
+
+	var overrides *model.RunOverrides
+	if r.ContentLength != 0 {
+		var req model.RunOverrides
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+			return
+		}
+		overrides = &req
+	}
+
+	if _, err := h.getJob(r, jobID); err != nil {
 		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Job triggered successfully"})
+	runID, err := h.scheduler.RunNow(jobID, overrides)
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
+		return
+	}
+	noteAudit(r).target = runID
+
+	if wait == 0 {
+		h.writeJSON(w, http.StatusOK, RunNowResponse{
+			Message: "Job triggered successfully",
+			RunID:   runID,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	run, err := h.runs.WaitRun(ctx, runID)
+	if run == nil {
+		h.writeJSON(w, http.StatusAccepted, RunNowResponse{
+			Message: "Job triggered, run has not started yet",
+			RunID:   runID,
+		})
+		return
+	}
+
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusAccepted
+	}
+	h.writeJSON(w, status, run)
 }
 
 func (h *JobHandler) PauseJob(w http.ResponseWriter, r *http.Request) {
@@ -172,16 +430,21 @@ func (h *JobHandler) setJobEnabled(w http.ResponseWriter, r *http.Request, enabl
 		return
 	}
 
-	job, err := h.store.Get(r.Context(), jobID)
+	job, err := h.getJob(r, jobID)
 	if err != nil {
 		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
 		return
 	}
 
+	expected, ok := h.checkIfMatch(w, r, job)
+	if !ok {
+		return
+	}
+
 	job.Enabled = enabled
 
-	if err := h.store.Put(r.Context(), job); err != nil {
-		h.writeError(w, http.StatusInternalServerError, "Failed to update job", err.Error())
+	if err := h.saveDefinition(r, &job.JobDefinition, expected); err != nil {
+		h.writeStoreError(w, err, "Failed to update job")
 		return
 	}
 
@@ -189,28 +452,60 @@ func (h *JobHandler) setJobEnabled(w http.ResponseWriter, r *http.Request, enabl
 		h.logger.Error("Failed to update job in scheduler", "job_id", job.ID, "error", err)
 	}
 
+	if !enabled {
+		h.cancelRunsIfRequested(r, jobID)
+	}
+
 	action := "paused"
+	revisionAction := revision.ActionPause
 	if enabled {
 		action = "resumed"
+		revisionAction = revision.ActionResume
 	}
+	h.recordRevision(r, revisionAction, &job.JobDefinition, 0)
 
+	setETag(w, &job.JobDefinition)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": fmt.Sprintf("Job %s successfully", action)})
 }
 
+func (h *JobHandler) cancelRunsIfRequested(r *http.Request, jobID string) {
+	if r.URL.Query().Get("cancel_running") != "true" {
+		return
+	}
+
+	if cancelled := h.runs.CancelJobRuns(jobID); cancelled > 0 {
+		h.logger.Info("Cancelled in-flight runs", "job_id", jobID, "count", cancelled)
+	}
+}
+
 func (h *JobHandler) Health(w http.ResponseWriter, r *http.Request) {
-	h.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	status := h.store.Status()
+	if status.Incident == nil {
+		h.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, HealthResponse{
+		Status: "degraded",
+		Store:  &status,
+	})
 }
 
 func (h *JobHandler) applyJobUpdates(job *model.Job, req *UpdateJobRequest) {
 	if req.Name != nil {
 		job.Name = *req.Name
 	}
+	if req.Labels != nil {
+		job.Labels = *req.Labels
+	}
 	if req.Enabled != nil {
 		job.Enabled = *req.Enabled
 	}
 	if req.HTTP != nil {
+		current := job.HTTP.Headers
 		job.HTTP = *req.HTTP
+		job.HTTP.Headers = keepRedactedHeaders(req.HTTP.Headers, current)
 	}
 	if req.Schedule != nil {
 		job.Schedule = *req.Schedule
@@ -225,6 +520,16 @@ func (h *JobHandler) applyJobUpdates(job *model.Job, req *UpdateJobRequest) {
 	if req.RetryBackoff != nil {
 		job.RetryBackoff = *req.RetryBackoff
 	}
+	if req.Completion != nil {
+		job.Completion = *req.Completion
+	}
+}
+
+func callerKeyID(r *http.Request) string {
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+		return identity.KeyID
+	}
+	return ""
 }
 
 func (h *JobHandler) extractJobID(r *http.Request) string {
@@ -251,4 +556,4 @@ func (h *JobHandler) writeError(w http.ResponseWriter, statusCode int, error, me
 		Error:   error,
 		Message: message,
 	})
-}
\ No newline at end of file
+}
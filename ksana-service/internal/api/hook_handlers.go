@@ -0,0 +1,425 @@
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"ksana-service/internal/model"
+	"ksana-service/internal/revision"
+	"ksana-service/internal/store"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHookRateLimit = 60
+	maxHookPayload       = 1 << 20
+
+	// unknownHookRateLimit 为每个客户端地址每分钟允许的未知令牌请求数
+	unknownHookRateLimit = 30
+)
+
+func (h *JobHandler) ListHooks(w http.ResponseWriter, r *http.Request) {
+	job, err := h.getJob(r, h.extractJobID(r))
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
+		return
+	}
+
+	responses := []HookResponse{}
+	for i := range job.Hooks {
+		responses = append(responses, HookToResponse(&job.Hooks[i]))
+	}
+
+	h.writeJSON(w, http.StatusOK, responses)
+}
+
+func (h *JobHandler) CreateHook(w http.ResponseWriter, r *http.Request) {
+	job, err := h.getJob(r, h.extractJobID(r))
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
+		return
+	}
+
+	var req CreateHookRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			h.writeError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+			return
+		}
+	}
+
+	if req.RateLimit < 0 {
+		h.writeError(w, http.StatusBadRequest, "Validation failed", "rate_limit must be non-negative")
+		return
+	}
+	if req.RateLimit == 0 {
+		req.RateLimit = defaultHookRateLimit
+	}
+
+	token := generateSecret()
+	hook := model.Hook{
+		ID:        generateSecret()[:16],
+		TokenHash: hashHookToken(token),
+		RateLimit: req.RateLimit,
+		CreatedAt: time.Now().UTC(),
+	}
+	var secret string
+	if req.Signed {
+		secret = generateSecret()
+		if hook.SealedSecret, err = sealHookSecret(token, hook.ID, secret); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to create hook", err.Error())
+			return
+		}
+	}
+
+	// 从存储读取的任务与存储共享 Hooks 底层数组，修改前先复制
+	job.Hooks = append(cloneHooks(job.Hooks), hook)
+	if err := h.saveHooks(r, job); err != nil {
+		h.writeStoreError(w, err, "Failed to save hook")
+		return
+	}
+
+	noteAudit(r).target = hook.ID
+	h.writeJSON(w, http.StatusCreated, h.hookCredentials(r, &hook, token, secret))
+}
+
+func (h *JobHandler) RotateHook(w http.ResponseWriter, r *http.Request) {
+	job, err := h.getJob(r, h.extractJobID(r))
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
+		return
+	}
+
+	job.Hooks = cloneHooks(job.Hooks)
+	hook := findHook(job, h.extractHookID(r))
+	if hook == nil {
+		h.writeError(w, http.StatusNotFound, "Hook not found", "")
+		return
+	}
+
+	token := generateSecret()
+	now := time.Now().UTC()
+	hook.TokenHash = hashHookToken(token)
+	hook.RotatedAt = &now
+
+	// 签名密钥随令牌一起更换，旧版本的明文密钥也在此时改为加密保存
+	var secret string
+	if hook.Signed() {
+		secret = generateSecret()
+		if hook.SealedSecret, err = sealHookSecret(token, hook.ID, secret); err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to rotate hook", err.Error())
+			return
+		}
+		hook.Secret = ""
+	}
+
+	if err := h.saveHooks(r, job); err != nil {
+		h.writeStoreError(w, err, "Failed to save hook")
+		return
+	}
+
+	h.hookLimiter.Forget(hook.ID)
+	h.writeJSON(w, http.StatusOK, h.hookCredentials(r, hook, token, secret))
+}
+
+func (h *JobHandler) RevokeHook(w http.ResponseWriter, r *http.Request) {
+	job, err := h.getJob(r, h.extractJobID(r))
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
+		return
+	}
+
+	hookID := h.extractHookID(r)
+	if findHook(job, hookID) == nil {
+		h.writeError(w, http.StatusNotFound, "Hook not found", "")
+		return
+	}
+
+	var hooks []model.Hook
+	for _, hook := range job.Hooks {
+		if hook.ID != hookID {
+			hooks = append(hooks, hook)
+		}
+	}
+	job.Hooks = hooks
+
+	if err := h.saveHooks(r, job); err != nil {
+		h.writeStoreError(w, err, "Failed to save hook")
+		return
+	}
+
+	h.hookLimiter.Forget(hookID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *JobHandler) TriggerHook(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/hooks/")
+	if token == "" || strings.Contains(token, "/") {
+		h.writeError(w, http.StatusNotFound, "Hook not found", "")
+		return
+	}
+
+	job, hook, err := h.findJobByHookToken(r, token)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to look up hook", err.Error())
+		return
+	}
+	if hook == nil {
+		// 按连接地址限流，X-Forwarded-For 等请求头可以伪造，不作为限流依据
+		if !h.unknownHookLimiter.Allow(remoteHost(r), unknownHookRateLimit) {
+			w.Header().Set("Retry-After", "60")
+			h.writeError(w, http.StatusTooManyRequests, "Rate limit exceeded", "")
+			return
+		}
+		h.logger.Warn("Webhook trigger rejected: unknown token", "client_ip", getClientIP(r))
+		h.writeError(w, http.StatusNotFound, "Hook not found", "")
+		return
+	}
+
+	note := noteAudit(r)
+	note.actor, note.jobID = "hook:"+hook.ID, job.ID
+
+	if !h.hookLimiter.Allow(hook.ID, hook.RateLimit) {
+		w.Header().Set("Retry-After", "60")
+		h.writeError(w, http.StatusTooManyRequests, "Rate limit exceeded", "")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHookPayload))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Failed to read body", err.Error())
+		return
+	}
+
+	secret, err := openHookSecret(token, hook)
+	if err != nil {
+		h.logger.Error("Failed to decrypt hook secret", "job_id", job.ID, "hook_id", hook.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "Failed to verify signature", "")
+		return
+	}
+	if secret != "" && !verifyHookSignature(secret, body, r.Header.Get("X-Ksana-Signature")) {
+		h.logger.Warn("Webhook trigger rejected: invalid signature",
+			"job_id", job.ID,
+			"hook_id", hook.ID,
+			"client_ip", getClientIP(r))
+		h.writeError(w, http.StatusUnauthorized, "Invalid signature", "")
+		return
+	}
+	if hook.Secret != "" {
+		h.sealLegacyHookSecret(r, job, hook.ID, token)
+	}
+
+	if !job.Enabled {
+		h.writeError(w, http.StatusConflict, "Job is paused", "")
+		return
+	}
+
+	var payload interface{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &payload); err != nil {
+			payload = string(body)
+		}
+	}
+
+	runID, err := h.scheduler.Trigger(job.ID, model.RunTriggerHook, &model.RunOverrides{Payload: payload})
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
+		return
+	}
+
+	note.target = runID
+	h.logger.Info("Job triggered by webhook", "job_id", job.ID, "hook_id", hook.ID, "run_id", runID)
+	h.writeJSON(w, http.StatusAccepted, RunNowResponse{
+		Message: "Job triggered successfully",
+		RunID:   runID,
+	})
+}
+
+// saveHooks 以读取时的资源版本写入，期间任务被其它请求修改时返回 errConcurrentUpdate
+func (h *JobHandler) saveHooks(r *http.Request, job *model.Job) error {
+	if err := h.saveDefinition(r, &job.JobDefinition, nil); err != nil {
+		return err
+	}
+
+	if err := h.scheduler.UpdateJob(job); err != nil {
+		h.logger.Error("Failed to update job in scheduler", "job_id", job.ID, "error", err)
+	}
+
+	h.recordRevision(r, revision.ActionHooks, &job.JobDefinition, 0)
+	return nil
+}
+
+// findJobByHookToken 通过内存索引定位令牌所属的任务与触发器，只读取命中的那一个任务
+func (h *JobHandler) findJobByHookToken(r *http.Request, token string) (*model.Job, *model.Hook, error) {
+	tokenHash := hashHookToken(token)
+	ref, ok, err := h.hooks.lookup(r.Context(), tokenHash)
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+
+	job, err := h.store.Get(r.Context(), ref.jobID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hook := findHook(job, ref.hookID)
+	if hook == nil || !hmac.Equal([]byte(hook.TokenHash), []byte(tokenHash)) {
+		return nil, nil, nil
+	}
+	return job, hook, nil
+}
+
+// sealLegacyHookSecret 在旧版本明文保存的签名密钥首次验证通过时，借助本次请求中的令牌将其加密保存
+func (h *JobHandler) sealLegacyHookSecret(r *http.Request, job *model.Job, hookID, token string) {
+	job.Hooks = cloneHooks(job.Hooks)
+	hook := findHook(job, hookID)
+
+	sealed, err := sealHookSecret(token, hook.ID, hook.Secret)
+	if err == nil {
+		hook.SealedSecret, hook.Secret = sealed, ""
+		err = h.store.CompareAndPutDefinition(r.Context(), &job.JobDefinition, job.ResourceVersion)
+	}
+	if err != nil {
+		h.logger.Warn("Failed to encrypt legacy hook secret", "job_id", job.ID, "hook_id", hookID, "error", err)
+		return
+	}
+	h.logger.Info("Legacy hook secret encrypted", "job_id", job.ID, "hook_id", hookID)
+}
+
+// hookCredentials 中的触发器 URL 与执行回调地址一样以 PUBLIC_URL 为准，未配置时才按请求的主机拼接
+func (h *JobHandler) hookCredentials(r *http.Request, hook *model.Hook, token, secret string) HookCredentialsResponse {
+	path := "/hooks/" + token
+
+	baseURL := h.publicURL
+	if baseURL == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			scheme = proto
+		}
+		baseURL = scheme + "://" + r.Host
+	}
+
+	return HookCredentialsResponse{
+		HookResponse: HookToResponse(hook),
+		Token:        token,
+		Secret:       secret,
+		Path:         path,
+		URL:          baseURL + path,
+	}
+}
+
+func (h *JobHandler) extractHookID(r *http.Request) string {
+	parts := strings.Split(r.URL.Path, "/")
+	for i, part := range parts {
+		if part == "hooks" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+func findHook(job *model.Job, hookID string) *model.Hook {
+	for i := range job.Hooks {
+		if job.Hooks[i].ID == hookID {
+			return &job.Hooks[i]
+		}
+	}
+	return nil
+}
+
+func cloneHooks(hooks []model.Hook) []model.Hook {
+	return append([]model.Hook(nil), hooks...)
+}
+
+// hookSecretKey 由触发令牌派生签名密钥的加密密钥
+func hookSecretKey(token string) []byte {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte("ksana hook secret"))
+	return mac.Sum(nil)
+}
+
+// sealHookSecret 以 AES-256-GCM 加密签名密钥，Webhook ID 作为附加数据，结果为 base64(nonce || 密文)
+func sealHookSecret(token, hookID, secret string) (string, error) {
+	block, err := aes.NewCipher(hookSecretKey(token))
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), []byte(hookID))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openHookSecret 返回签名密钥，未启用签名时为空
+func openHookSecret(token string, hook *model.Hook) (string, error) {
+	if hook.SealedSecret == "" {
+		return hook.Secret, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(hook.SealedSecret)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(hookSecretKey(token))
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("sealed hook secret is truncated")
+	}
+
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(hook.ID))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func hashHookToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func verifyHookSignature(secret string, body []byte, signature string) bool {
+	signature = strings.TrimPrefix(signature, "sha256=")
+	provided, err := hex.DecodeString(signature)
+	if err != nil || len(provided) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), provided)
+}
+
+func generateSecret() string {
+	bytes := make([]byte, 32)
+	io.ReadFull(rand.Reader, bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"ksana-service/internal/model"
+	"ksana-service/internal/store"
+	"sync"
+)
+
+type hookRef struct {
+	jobID  string
+	hookID string
+}
+
+// hookIndex 维护 令牌哈希 → 任务/触发器 的内存索引，按存储变更事件增量更新；
+// 每次查询前先追上事件流，刚创建或吊销的令牌立即生效
+type hookIndex struct {
+	store store.Store
+
+	mu     sync.Mutex
+	loaded bool
+	seq    int64
+	byHash map[string]hookRef
+	byJob  map[string][]string
+}
+
+func newHookIndex(s store.Store) *hookIndex {
+	return &hookIndex{store: s}
+}
+
+func (x *hookIndex) lookup(ctx context.Context, tokenHash string) (hookRef, bool, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if err := x.catchUp(ctx); err != nil {
+		return hookRef{}, false, err
+	}
+	ref, ok := x.byHash[tokenHash]
+	return ref, ok, nil
+}
+
+func (x *hookIndex) catchUp(ctx context.Context) error {
+	feed := x.store.Feed()
+	if !x.loaded {
+		return x.rebuild(ctx)
+	}
+
+	events, _, err := feed.Since(x.seq, 0)
+	if errors.Is(err, store.ErrSequenceOutOfRange) {
+		return x.rebuild(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range events {
+		switch events[i].Type {
+		case store.EventCreated, store.EventUpdated:
+			if events[i].Job != nil {
+				x.index(events[i].JobID, events[i].Job.Hooks)
+			}
+		case store.EventDeleted:
+			x.index(events[i].JobID, nil)
+		case store.EventReset:
+			return x.rebuild(ctx)
+		}
+		x.seq = events[i].Seq
+	}
+	return nil
+}
+
+// rebuild 先记下事件序号再全量读取，期间发生的变更会在下次追赶时重复应用，结果相同
+func (x *hookIndex) rebuild(ctx context.Context) error {
+	seq := x.store.Feed().LastSeq()
+	jobs, err := x.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	x.byHash = make(map[string]hookRef)
+	x.byJob = make(map[string][]string)
+	for i := range jobs {
+		x.index(jobs[i].ID, jobs[i].Hooks)
+	}
+	x.seq = seq
+	x.loaded = true
+	return nil
+}
+
+func (x *hookIndex) index(jobID string, hooks []model.Hook) {
+	for _, hash := range x.byJob[jobID] {
+		delete(x.byHash, hash)
+	}
+	delete(x.byJob, jobID)
+
+	for i := range hooks {
+		x.byHash[hooks[i].TokenHash] = hookRef{jobID: jobID, hookID: hooks[i].ID}
+		x.byJob[jobID] = append(x.byJob[jobID], hooks[i].TokenHash)
+	}
+}
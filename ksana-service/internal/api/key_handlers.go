@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"ksana-service/internal/auth"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ListKeys 返回所有 API 密钥的元数据，不包含密钥与哈希
+func (h *JobHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnrestricted(w, r) {
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, h.keys.Keys())
+}
+
+// CreateKey 生成新密钥并写入密钥文件，密钥本身只在响应中出现一次
+func (h *JobHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnrestricted(w, r) {
+		return
+	}
+
+	var req CreateKeyRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresIn != 0 {
+		h.writeError(w, http.StatusBadRequest, "Validation failed", "at most one of expires_at and expires_in may be given")
+		return
+	}
+
+	spec := auth.KeySpec{
+		Name:       req.Name,
+		Owner:      req.Owner,
+		Namespaces: req.Namespaces,
+		Scopes:     req.Scopes,
+	}
+	switch {
+	case req.ExpiresAt != nil:
+		spec.Expires = *req.ExpiresAt
+	case req.ExpiresIn != 0:
+		spec.Expires = time.Now().Add(req.ExpiresIn.ToDuration())
+	}
+
+	key, info, err := h.keys.CreateKey(spec)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidKeySpec) {
+			h.writeError(w, http.StatusBadRequest, "Validation failed", err.Error())
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Failed to create API key", err.Error())
+		return
+	}
+
+	noteAudit(r).target = info.ID
+	h.logger.Info("API key created", "id", info.ID, "name", info.Name, "key_id", callerKeyID(r))
+	h.writeJSON(w, http.StatusCreated, CreateKeyResponse{Key: key, KeyInfo: *info})
+}
+
+// RevokeKey 从密钥文件中移除密钥，立即生效；不允许撤销最后一个管理员密钥
+func (h *JobHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnrestricted(w, r) {
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/admin/keys/")
+	info, err := h.keys.RevokeKey(id, "key "+callerKeyID(r))
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		h.writeError(w, http.StatusNotFound, "API key not found", err.Error())
+		return
+	case errors.Is(err, auth.ErrLastAdminKey):
+		h.writeError(w, http.StatusConflict, "Cannot revoke API key", err.Error())
+		return
+	case err != nil:
+		h.writeError(w, http.StatusInternalServerError, "Failed to revoke API key", err.Error())
+		return
+	}
+
+	h.logger.Info("API key revoked", "id", info.ID, "name", info.Name, "key_id", callerKeyID(r))
+	h.writeJSON(w, http.StatusOK, info)
+}
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/labels"
+	"ksana-service/internal/model"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+
+	listFormatArray    = "array"
+	listFormatEnvelope = "envelope"
+)
+
+var sortFields = map[string]bool{
+	"id":               true,
+	"name":             true,
+	"last_run_at":      true,
+	"next_run_at":      true,
+	"resource_version": true,
+}
+
+// jobQuery 为 GET /jobs 的过滤、排序与分页参数
+type jobQuery struct {
+	selector      labels.Selector
+	namespace     string
+	enabled       *bool
+	lastStatuses  map[string]bool
+	jobType       string
+	name          string
+	nextRunBefore *time.Time
+	nextRunAfter  *time.Time
+
+	sortField  string
+	descending bool
+	limit      int
+	cursor     *listCursor
+	envelope   bool
+}
+
+// listCursor 记录上一页最后一个任务的排序键，下一页从严格大于该键的位置开始，
+// 期间新增或删除任务不会导致重复或遗漏
+type listCursor struct {
+	Sort string `json:"s"`
+	Num  int64  `json:"n,omitempty"`
+	Str  string `json:"v,omitempty"`
+	ID   string `json:"id"`
+}
+
+type sortKey struct {
+	num int64
+	str string
+	id  string
+}
+
+func parseJobQuery(values url.Values) (*jobQuery, error) {
+	q := &jobQuery{}
+
+	var err error
+	if q.selector, err = parseSelector(values); err != nil {
+		return nil, err
+	}
+
+	q.namespace = values.Get("namespace")
+
+	if raw := values.Get("enabled"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("enabled must be true or false")
+		}
+		q.enabled = &enabled
+	}
+
+	if raw := values.Get("last_status"); raw != "" {
+		q.lastStatuses = make(map[string]bool)
+		for _, status := range strings.Split(raw, ",") {
+			q.lastStatuses[strings.TrimSpace(status)] = true
+		}
+	}
+
+	q.jobType = values.Get("type")
+	q.name = strings.ToLower(values.Get("name"))
+
+	if q.nextRunBefore, err = parseTimeParam(values, "next_run_before"); err != nil {
+		return nil, err
+	}
+	if q.nextRunAfter, err = parseTimeParam(values, "next_run_after"); err != nil {
+		return nil, err
+	}
+
+	if raw := values.Get("sort"); raw != "" {
+		q.sortField = strings.TrimPrefix(raw, "-")
+		q.descending = strings.HasPrefix(raw, "-")
+		if !sortFields[q.sortField] {
+			return nil, fmt.Errorf("sort must be one of id, name, last_run_at, next_run_at, resource_version (prefix with '-' for descending)")
+		}
+	}
+
+	paginate := values.Has("limit") || values.Has("cursor")
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		q.limit = limit
+	} else if paginate {
+		q.limit = defaultPageSize
+	}
+
+	// 分页需要确定的顺序，未指定排序时按 ID 排序
+	if paginate && q.sortField == "" {
+		q.sortField = "id"
+	}
+
+	sortParam := values.Get("sort")
+	if sortParam == "" {
+		sortParam = q.sortField
+	}
+	if raw := values.Get("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+		if cursor.Sort != sortParam {
+			return nil, errors.New("cursor was issued for a different sort order")
+		}
+		q.cursor = cursor
+	}
+
+	// 兼容旧客户端：不带分页参数时仍返回数组，format 可显式指定
+	switch values.Get("format") {
+	case "":
+		q.envelope = paginate
+	case listFormatEnvelope:
+		q.envelope = true
+	case listFormatArray:
+		q.envelope = false
+	default:
+		return nil, errors.New("format must be 'array' or 'envelope'")
+	}
+
+	return q, nil
+}
+
+// parseSelector 解析 selector 查询参数，例如 `selector=team=billing,env!=dev`
+func parseSelector(values url.Values) (labels.Selector, error) {
+	selector, err := labels.Parse(values.Get("selector"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+	return selector, nil
+}
+
+func parseTimeParam(values url.Values, name string) (*time.Time, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC3339 timestamp", name)
+	}
+	return &parsed, nil
+}
+
+func (q *jobQuery) matches(job *model.Job) bool {
+	if q.namespace != "" && job.Namespace != q.namespace {
+		return false
+	}
+	if q.enabled != nil && job.Enabled != *q.enabled {
+		return false
+	}
+	if q.lastStatuses != nil && !q.lastStatuses[job.LastStatus] {
+		return false
+	}
+	if q.jobType != "" && job.Type != q.jobType {
+		return false
+	}
+	if q.name != "" && !strings.Contains(strings.ToLower(job.Name), q.name) {
+		return false
+	}
+	if q.nextRunBefore != nil && (job.NextRunAt == nil || !job.NextRunAt.Before(*q.nextRunBefore)) {
+		return false
+	}
+	if q.nextRunAfter != nil && (job.NextRunAt == nil || !job.NextRunAt.After(*q.nextRunAfter)) {
+		return false
+	}
+	return true
+}
+
+// apply 过滤、排序并截取一页，返回当前页、过滤后的总数与下一页游标
+func (q *jobQuery) apply(jobs []model.Job) ([]model.Job, int, string) {
+	filtered := jobs[:0:0]
+	for i := range jobs {
+		if q.matches(&jobs[i]) {
+			filtered = append(filtered, jobs[i])
+		}
+	}
+	total := len(filtered)
+
+	if q.sortField == "" {
+		return filtered, total, ""
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return q.less(q.key(&filtered[i]), q.key(&filtered[j]))
+	})
+
+	start := 0
+	if q.cursor != nil {
+		after := sortKey{num: q.cursor.Num, str: q.cursor.Str, id: q.cursor.ID}
+		start = sort.Search(len(filtered), func(i int) bool {
+			return q.less(after, q.key(&filtered[i]))
+		})
+	}
+
+	page := filtered[start:]
+	if q.limit <= 0 || len(page) <= q.limit {
+		return page, total, ""
+	}
+
+	page = page[:q.limit]
+	return page, total, q.encodeCursor(q.key(&page[len(page)-1]))
+}
+
+// key 计算排序键；未设置的时间按最大值处理，升序时排在末尾
+func (q *jobQuery) key(job *model.Job) sortKey {
+	key := sortKey{id: job.ID}
+
+	switch q.sortField {
+	case "name":
+		key.str = job.Name
+	case "last_run_at":
+		key.num = timeKey(job.LastRunAt)
+	case "next_run_at":
+		key.num = timeKey(job.NextRunAt)
+	case "resource_version":
+		key.num = job.ResourceVersion
+	}
+	return key
+}
+
+func (q *jobQuery) less(a, b sortKey) bool {
+	if a.num != b.num {
+		return (a.num < b.num) != q.descending
+	}
+	if a.str != b.str {
+		return (a.str < b.str) != q.descending
+	}
+	// ID 作为最后的排序键保证顺序稳定
+	if a.id != b.id {
+		return (a.id < b.id) != q.descending
+	}
+	return false
+}
+
+func (q *jobQuery) encodeCursor(key sortKey) string {
+	sortParam := q.sortField
+	if q.descending {
+		sortParam = "-" + sortParam
+	}
+
+	data, _ := json.Marshal(listCursor{Sort: sortParam, Num: key.num, Str: key.str, ID: key.id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor listCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+func timeKey(t *time.Time) int64 {
+	if t == nil {
+		return math.MaxInt64
+	}
+	return t.UnixNano()
+}
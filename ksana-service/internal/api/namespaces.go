@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ksana-service/internal/auth"
+	"ksana-service/internal/model"
+	"ksana-service/internal/namespace"
+	"ksana-service/internal/secrets"
+	"ksana-service/internal/store"
+	"net/http"
+)
+
+var errNamespaceForbidden = errors.New("namespace is not accessible with this API key")
+
+// canAccess 判断调用方能否访问命名空间；未经鉴权的内部调用（如 Webhook）不受限制
+func canAccess(r *http.Request, ns string) bool {
+	identity := auth.IdentityFromContext(r.Context())
+	if identity == nil {
+		return true
+	}
+	if ns == "" {
+		ns = model.DefaultNamespace
+	}
+	return identity.CanAccess(ns)
+}
+
+func unrestricted(r *http.Request) bool {
+	identity := auth.IdentityFromContext(r.Context())
+	return identity == nil || identity.Unrestricted()
+}
+
+// requireUnrestricted 用于密文、备份等不属于任何命名空间的全局操作
+func (h *JobHandler) requireUnrestricted(w http.ResponseWriter, r *http.Request) bool {
+	if unrestricted(r) {
+		return true
+	}
+	h.writeError(w, http.StatusForbidden, "Forbidden", "this operation requires an API key bound to all namespaces")
+	return false
+}
+
+// resolveNamespace 确定新建任务的命名空间：未指定时，绑定单个命名空间的密钥使用该命名空间，
+// 绑定多个命名空间的密钥必须显式指定，不受限的密钥使用 default
+func resolveNamespace(r *http.Request, requested string) (string, error) {
+	ns := requested
+	if ns == "" {
+		ns = model.DefaultNamespace
+		if identity := auth.IdentityFromContext(r.Context()); identity != nil && !identity.Unrestricted() {
+			if len(identity.Namespaces) != 1 {
+				return "", errors.New("namespace is required for API keys bound to multiple namespaces")
+			}
+			ns = identity.Namespaces[0]
+		}
+	}
+
+	if !model.ValidNamespace(ns) {
+		return "", fmt.Errorf("invalid namespace %q", ns)
+	}
+	if !canAccess(r, ns) {
+		return "", fmt.Errorf("%w: %s", errNamespaceForbidden, ns)
+	}
+	return ns, nil
+}
+
+// getJob 读取调用方可访问的任务，其它命名空间的任务与不存在的任务一样返回 ErrNotFound，避免泄露其存在
+func (h *JobHandler) getJob(r *http.Request, id string) (*model.Job, error) {
+	job, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccess(r, job.Namespace) {
+		return nil, fmt.Errorf("%w: %s", store.ErrNotFound, id)
+	}
+	return job, nil
+}
+
+func filterAccessible(r *http.Request, jobs []model.Job) []model.Job {
+	if unrestricted(r) {
+		return jobs
+	}
+
+	filtered := jobs[:0:0]
+	for i := range jobs {
+		if canAccess(r, jobs[i].Namespace) {
+			filtered = append(filtered, jobs[i])
+		}
+	}
+	return filtered
+}
+
+// validateJob 校验任务定义、所属命名空间的配额以及引用的密文
+func (h *JobHandler) validateJob(def *model.JobDefinition) error {
+	if err := def.Validate(); err != nil {
+		return err
+	}
+	quotas := h.namespaces.Get(def.Namespace).Quotas
+	if err := quotas.CheckDefinition(def); err != nil {
+		return err
+	}
+	return h.checkSecretRefs(def)
+}
+
+// checkSecretRefs 校验任务引用的每个密文都存在且允许任务所属的命名空间使用；
+// 执行器解析密文时会再次校验，这里保证越权的引用在保存前就被拒绝
+func (h *JobHandler) checkSecretRefs(def *model.JobDefinition) error {
+	refs, err := def.HTTP.SecretRefs()
+	if err != nil {
+		return err
+	}
+	for _, name := range refs {
+		if err := h.secrets.Check(def.Namespace, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkJobCount 校验在命名空间中新增 adding 个任务后是否超过任务数上限
+func (h *JobHandler) checkJobCount(ctx context.Context, ns string, adding int) error {
+	quotas := h.namespaces.Get(ns).Quotas
+	if quotas.MaxJobs == 0 {
+		return nil
+	}
+
+	defs, err := h.store.ListDefinitions(ctx)
+	if err != nil {
+		return err
+	}
+
+	current := 0
+	for i := range defs {
+		if defs[i].Namespace == ns {
+			current++
+		}
+	}
+	return quotas.CheckJobCount(ns, current, adding)
+}
+
+func (h *JobHandler) writeNamespaceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNamespaceForbidden) {
+		h.writeError(w, http.StatusForbidden, "Forbidden", err.Error())
+		return
+	}
+	h.writeError(w, http.StatusBadRequest, "Invalid namespace", err.Error())
+}
+
+// writeValidationError 区分配额错误与越权引用密文（403）以及普通校验错误（400）
+func (h *JobHandler) writeValidationError(w http.ResponseWriter, err error) {
+	if errors.Is(err, namespace.ErrQuotaExceeded) {
+		h.writeError(w, http.StatusForbidden, "Quota exceeded", err.Error())
+		return
+	}
+	if errors.Is(err, secrets.ErrNamespaceDenied) {
+		h.writeError(w, http.StatusForbidden, "Secret not available", err.Error())
+		return
+	}
+	h.writeError(w, http.StatusBadRequest, "Validation failed", err.Error())
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"ksana-service/internal/model"
+	"ksana-service/internal/store"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	errInvalidIfMatch   = errors.New("If-Match must be a single ETag such as \"42\" or *")
+	errConcurrentUpdate = errors.New("job was modified by another request, read it again and retry")
+)
+
+func setETag(w http.ResponseWriter, def *model.JobDefinition) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(def.ResourceVersion, 10)))
+}
+
+// ifMatchVersion 解析 If-Match 请求头，未携带或为 * 时返回 nil 表示无条件写入
+func ifMatchVersion(r *http.Request) (*int64, error) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" || raw == "*" {
+		return nil, nil
+	}
+
+	raw = strings.TrimPrefix(raw, "W/")
+	unquoted, err := strconv.Unquote(raw)
+	if err != nil {
+		unquoted = raw
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil {
+		return nil, errInvalidIfMatch
+	}
+	return &version, nil
+}
+
+// checkIfMatch 在读取任务后立即校验前置条件，失败时写出响应并返回 false
+func (h *JobHandler) checkIfMatch(w http.ResponseWriter, r *http.Request, job *model.Job) (*int64, bool) {
+	expected, err := ifMatchVersion(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid If-Match header", err.Error())
+		return nil, false
+	}
+
+	if expected != nil && *expected != job.ResourceVersion {
+		h.writePreconditionFailed(w, job.ResourceVersion, *expected)
+		return nil, false
+	}
+	return expected, true
+}
+
+// saveDefinition 写入读取后修改的定义。未携带 If-Match 时同样以读取到的资源版本做并发检查，
+// 避免两个同时进行的修改互相覆盖；这种冲突返回 errConcurrentUpdate（409），与 If-Match 不匹配（412）区分
+func (h *JobHandler) saveDefinition(r *http.Request, def *model.JobDefinition, expected *int64) error {
+	if expected != nil {
+		return h.store.CompareAndPutDefinition(r.Context(), def, *expected)
+	}
+
+	err := h.store.CompareAndPutDefinition(r.Context(), def, def.ResourceVersion)
+	if errors.Is(err, store.ErrConflict) {
+		return fmt.Errorf("%w: %v", errConcurrentUpdate, err)
+	}
+	return err
+}
+
+func (h *JobHandler) writeStoreError(w http.ResponseWriter, err error, title string) {
+	switch {
+	case errors.Is(err, errConcurrentUpdate):
+		h.writeError(w, http.StatusConflict, "Conflict", err.Error())
+	case errors.Is(err, store.ErrConflict):
+		h.writeError(w, http.StatusPreconditionFailed, "Precondition failed", err.Error())
+	case errors.Is(err, store.ErrReadOnly):
+		h.writeError(w, http.StatusServiceUnavailable, "Store is read-only", err.Error())
+	case errors.Is(err, store.ErrNotFound):
+		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
+	default:
+		h.writeError(w, http.StatusInternalServerError, title, err.Error())
+	}
+}
+
+func (h *JobHandler) writePreconditionFailed(w http.ResponseWriter, current, expected int64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(current, 10)))
+	h.writeError(w, http.StatusPreconditionFailed, "Precondition failed",
+		fmt.Sprintf("job is at resource version %d, If-Match expected %d", current, expected))
+}
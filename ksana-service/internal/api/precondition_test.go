@@ -0,0 +1,193 @@
+package api
+
+import (
+	"context"
+	"io"
+	"ksana-service/internal/model"
+	"ksana-service/internal/store"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    int64
+		wantNil bool
+		wantErr bool
+	}{
+		{header: "", wantNil: true},
+		{header: " * ", wantNil: true},
+		{header: `"42"`, want: 42},
+		{header: `W/"42"`, want: 42},
+		{header: "42", want: 42},
+		{header: `"abc"`, wantErr: true},
+		{header: `"1", "2"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPut, "/api/v1/jobs/a", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+
+		got, err := ifMatchVersion(r)
+		switch {
+		case tt.wantErr:
+			if err != errInvalidIfMatch {
+				t.Errorf("If-Match %q: err = %v, want errInvalidIfMatch", tt.header, err)
+			}
+		case err != nil:
+			t.Errorf("If-Match %q: %v", tt.header, err)
+		case tt.wantNil:
+			if got != nil {
+				t.Errorf("If-Match %q = %d, want unconditional", tt.header, *got)
+			}
+		case got == nil || *got != tt.want:
+			t.Errorf("If-Match %q = %v, want %d", tt.header, got, tt.want)
+		}
+	}
+}
+
+func newPreconditionHandler(t *testing.T) (*JobHandler, *model.Job) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := store.NewJSONStore(t.TempDir(), store.IntegrityConfig{}, logger)
+	if _, err := s.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(context.Background(), &model.Job{JobDefinition: model.JobDefinition{ID: "a", Name: "first", Namespace: model.DefaultNamespace}}); err != nil {
+		t.Fatal(err)
+	}
+	job, err := s.Get(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &JobHandler{store: s, logger: logger}, job
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	h, job := newPreconditionHandler(t)
+	current := strconv.Quote(strconv.FormatInt(job.ResourceVersion, 10))
+
+	tests := []struct {
+		name       string
+		header     string
+		wantOK     bool
+		wantStatus int
+	}{
+		{name: "no header", wantOK: true},
+		{name: "current version", header: current, wantOK: true},
+		{name: "stale version", header: `"0"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "invalid header", header: "latest", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/api/v1/jobs/a", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			_, ok := h.checkIfMatch(w, r, job)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok {
+				return
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusPreconditionFailed && w.Header().Get("ETag") != current {
+				t.Errorf("ETag = %q, want the current version %s", w.Header().Get("ETag"), current)
+			}
+		})
+	}
+}
+
+func TestSaveDefinitionConflicts(t *testing.T) {
+	tests := []struct {
+		name       string
+		ifMatch    bool
+		concurrent bool
+		wantStatus int
+	}{
+		{name: "without If-Match", wantStatus: http.StatusOK},
+		{name: "with If-Match", ifMatch: true, wantStatus: http.StatusOK},
+		{name: "concurrent write without If-Match", concurrent: true, wantStatus: http.StatusConflict},
+		{name: "concurrent write with If-Match", ifMatch: true, concurrent: true, wantStatus: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, job := newPreconditionHandler(t)
+			ctx := context.Background()
+			r := httptest.NewRequest(http.MethodPut, "/api/v1/jobs/a", nil)
+
+			var expected *int64
+			if tt.ifMatch {
+				version := job.ResourceVersion
+				expected = &version
+			}
+			if tt.concurrent {
+				other := job.JobDefinition
+				other.Name = "other"
+				if err := h.store.PutDefinition(ctx, &other); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			def := job.JobDefinition
+			def.Name = "mine"
+			err := h.saveDefinition(r, &def, expected)
+
+			if tt.wantStatus == http.StatusOK {
+				if err != nil {
+					t.Fatalf("saveDefinition: %v", err)
+				}
+				if saved, _ := h.store.Get(ctx, "a"); saved.Name != "mine" || saved.ResourceVersion <= job.ResourceVersion {
+					t.Errorf("saved = %q at version %d, want mine after version %d", saved.Name, saved.ResourceVersion, job.ResourceVersion)
+				}
+				return
+			}
+
+			w := httptest.NewRecorder()
+			h.writeStoreError(w, err, "Failed to update job")
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d for %v, want %d", w.Code, err, tt.wantStatus)
+			}
+			if saved, _ := h.store.Get(ctx, "a"); saved.Name != "other" {
+				t.Errorf("concurrent write was overwritten: name = %q", saved.Name)
+			}
+		})
+	}
+}
+
+func TestWriteStoreError(t *testing.T) {
+	h := &JobHandler{}
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: errConcurrentUpdate, want: http.StatusConflict},
+		{err: store.ErrConflict, want: http.StatusPreconditionFailed},
+		{err: store.ErrReadOnly, want: http.StatusServiceUnavailable},
+		{err: store.ErrNotFound, want: http.StatusNotFound},
+		{err: io.ErrUnexpectedEOF, want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.writeStoreError(w, tt.err, "Failed")
+		if w.Code != tt.want {
+			t.Errorf("%v: status = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
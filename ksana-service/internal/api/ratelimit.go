@@ -0,0 +1,75 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRateLimitBuckets 超过该数量时清理空闲的桶，避免按客户端地址限流时无限增长
+const maxRateLimitBuckets = 10000
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (l *rateLimiter) Allow(key string, perMinute int) bool {
+	if perMinute <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	capacity := float64(perMinute)
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		if len(l.buckets) >= maxRateLimitBuckets {
+			l.pruneIdle(now)
+		}
+		bucket = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Minutes() * capacity
+	if bucket.tokens > capacity {
+		bucket.tokens = capacity
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+	return true
+}
+
+// pruneIdle 删除一分钟内没有请求的桶，它们已回满，删除后重新创建的效果相同
+func (l *rateLimiter) pruneIdle(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *rateLimiter) Forget(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.buckets, key)
+}
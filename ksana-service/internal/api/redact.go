@@ -0,0 +1,102 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"ksana-service/internal/diff"
+	"ksana-service/internal/secrets"
+	"strings"
+)
+
+// 名称包含这些片段的请求头视为敏感，返回时隐藏取值
+var sensitiveHeaderParts = []string{
+	"authorization", "cookie", "token", "secret", "password", "passwd",
+	"api-key", "apikey", "api_key", "signature", "credential", "session",
+}
+
+// headerFingerprintKey 为进程内随机密钥：差异中的脱敏值带上以它计算的指纹，
+// 可以看出取值是否变化，离开进程后无法据此猜测原值
+var headerFingerprintKey = func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}()
+
+func sensitiveHeader(name, value string) bool {
+	lower := strings.ToLower(name)
+	for _, part := range sensitiveHeaderParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+
+	value = strings.ToLower(strings.TrimSpace(value))
+	return strings.HasPrefix(value, "bearer ") || strings.HasPrefix(value, "basic ")
+}
+
+// redactHeaders 返回隐藏了敏感取值的请求头副本，mask 决定替换后的内容
+func redactHeaders(headers map[string]string, mask func(string) string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if sensitiveHeader(name, value) {
+			value = mask(value)
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+func maskRedacted(string) string {
+	return secrets.Redacted
+}
+
+func maskFingerprint(value string) string {
+	mac := hmac.New(sha256.New, headerFingerprintKey)
+	mac.Write([]byte(value))
+	return secrets.Redacted + ":" + hex.EncodeToString(mac.Sum(nil))[:8]
+}
+
+// redactHeaderChanges 隐藏差异中敏感请求头的前后取值
+func redactHeaderChanges(changes []diff.Change) []diff.Change {
+	const prefix = "http.headers."
+	for i := range changes {
+		name, ok := strings.CutPrefix(changes[i].Path, prefix)
+		if !ok {
+			continue
+		}
+		before, _ := changes[i].Before.(string)
+		after, _ := changes[i].After.(string)
+		if !sensitiveHeader(name, before) && !sensitiveHeader(name, after) {
+			continue
+		}
+		if changes[i].Before != nil {
+			changes[i].Before = maskFingerprint(before)
+		}
+		if changes[i].After != nil {
+			changes[i].After = maskFingerprint(after)
+		}
+	}
+	return changes
+}
+
+// keepRedactedHeaders 返回提交的请求头副本，客户端把读到的 [REDACTED] 原样提交回来时保留已保存的取值
+func keepRedactedHeaders(updated, current map[string]string) map[string]string {
+	if updated == nil {
+		return nil
+	}
+
+	headers := make(map[string]string, len(updated))
+	for name, value := range updated {
+		if previous, exists := current[name]; exists && value == secrets.Redacted {
+			value = previous
+		}
+		headers[name] = value
+	}
+	return headers
+}
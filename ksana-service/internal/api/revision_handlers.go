@@ -0,0 +1,197 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"ksana-service/internal/diff"
+	"ksana-service/internal/model"
+	"ksana-service/internal/revision"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (h *JobHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	revisions, err := h.revisions.List(h.extractJobID(r))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list revisions", err.Error())
+		return
+	}
+
+	if len(revisions) > 0 && !canAccess(r, revisions[len(revisions)-1].Definition.Namespace) {
+		revisions = nil
+	}
+
+	responses := []RevisionResponse{}
+	for i := len(revisions) - 1; i >= 0; i-- {
+		responses = append(responses, RevisionToResponse(&revisions[i]))
+	}
+
+	h.writeJSON(w, http.StatusOK, responses)
+}
+
+func (h *JobHandler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	rev, ok := h.lookupRevision(w, r, h.extractRevision(r))
+	if !ok {
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, RevisionToResponse(rev))
+}
+
+func (h *JobHandler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	number, err := strconv.Atoi(h.extractRevision(r))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid revision", "revision must be a number")
+		return
+	}
+
+	from := number - 1
+	if raw := r.URL.Query().Get("from"); raw != "" {
+		if from, err = strconv.Atoi(raw); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid revision", "from must be a number")
+			return
+		}
+	}
+
+	to, ok := h.lookupRevision(w, r, strconv.Itoa(number))
+	if !ok {
+		return
+	}
+
+	// from 为 0 表示与空定义比较，即列出该修订的全部字段
+	var before interface{}
+	if from > 0 {
+		base, ok := h.lookupRevision(w, r, strconv.Itoa(from))
+		if !ok {
+			return
+		}
+		before = diffableDefinition(base.Definition)
+	}
+
+	changes, err := diff.Compute(before, diffableDefinition(to.Definition))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to diff revisions", err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, RevisionDiffResponse{
+		JobID:   to.JobID,
+		From:    from,
+		To:      number,
+		Changes: changes,
+	})
+}
+
+func (h *JobHandler) RollbackRevision(w http.ResponseWriter, r *http.Request) {
+	rev, ok := h.lookupRevision(w, r, h.extractRevision(r))
+	if !ok {
+		return
+	}
+
+	job, err := h.getJob(r, rev.JobID)
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "Job not found", err.Error())
+		return
+	}
+
+	expected, ok := h.checkIfMatch(w, r, job)
+	if !ok {
+		return
+	}
+
+	// 回滚只恢复用户维护的配置，触发器令牌保持当前状态，避免已吊销的令牌重新生效；
+	// 命名空间、清单 key 与归属也保持当前值，否则回滚可能让任务脱离当前清单的管理或与其它任务的 key 冲突
+	def := rev.Definition
+	def.ID = job.ID
+	def.ResourceVersion = job.ResourceVersion
+	def.Hooks = job.Hooks
+	def.Namespace = job.Namespace
+	def.Key = job.Key
+	def.ManagedBy = job.ManagedBy
+	job.JobDefinition = def
+	job.NextRunAt = nil
+
+	if err := h.validateJob(&job.JobDefinition); err != nil {
+		h.writeValidationError(w, err)
+		return
+	}
+
+	if err := h.saveDefinition(r, &job.JobDefinition, expected); err != nil {
+		h.writeStoreError(w, err, "Failed to roll back job")
+		return
+	}
+
+	if err := h.scheduler.UpdateJob(job); err != nil {
+		h.logger.Error("Failed to update job in scheduler", "job_id", job.ID, "error", err)
+	}
+
+	h.recordRevision(r, revision.ActionRollback, &job.JobDefinition, rev.Revision)
+
+	setETag(w, &job.JobDefinition)
+	h.writeJSON(w, http.StatusOK, JobToResponse(job))
+}
+
+// diffableDefinition 去掉每次写入都会变化的资源版本号，只比较用户维护的字段；
+// 敏感请求头替换为带指纹的脱敏值，差异中能看出变化但不暴露取值
+func diffableDefinition(def model.JobDefinition) JobDefinitionResponse {
+	def.ResourceVersion = 0
+	return definitionResponse(&def, maskFingerprint)
+}
+
+func (h *JobHandler) recordRevision(r *http.Request, action string, def *model.JobDefinition, rolledBackFrom int) {
+	rev := &revision.Revision{
+		JobID:           def.ID,
+		ResourceVersion: def.ResourceVersion,
+		Action:          action,
+		RolledBackFrom:  rolledBackFrom,
+		KeyID:           callerKeyID(r),
+		Definition:      *def,
+	}
+
+	if err := h.revisions.Record(rev); err != nil {
+		h.logger.Error("Failed to record job revision", "job_id", def.ID, "action", action, "error", err)
+	}
+}
+
+func (h *JobHandler) lookupRevision(w http.ResponseWriter, r *http.Request, raw string) (*revision.Revision, bool) {
+	number, err := strconv.Atoi(raw)
+	if err != nil || number <= 0 {
+		h.writeError(w, http.StatusBadRequest, "Invalid revision", "revision must be a positive number")
+		return nil, false
+	}
+
+	jobID := h.extractJobID(r)
+	rev, err := h.revisions.Get(jobID, number)
+	if err == nil && !h.revisionsAccessible(r, jobID) {
+		err = fmt.Errorf("%w: %s", revision.ErrNotFound, jobID)
+	}
+	if errors.Is(err, revision.ErrNotFound) {
+		h.writeError(w, http.StatusNotFound, "Revision not found", err.Error())
+		return nil, false
+	}
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to load revision", err.Error())
+		return nil, false
+	}
+	return rev, true
+}
+
+// revisionsAccessible 以任务最新修订的命名空间判断访问权限，任务已删除时同样适用
+func (h *JobHandler) revisionsAccessible(r *http.Request, jobID string) bool {
+	revisions, err := h.revisions.List(jobID)
+	if err != nil || len(revisions) == 0 {
+		return unrestricted(r)
+	}
+	return canAccess(r, revisions[len(revisions)-1].Definition.Namespace)
+}
+
+func (h *JobHandler) extractRevision(r *http.Request) string {
+	parts := strings.Split(r.URL.Path, "/")
+	for i, part := range parts {
+		if part == "revisions" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
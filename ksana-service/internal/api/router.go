@@ -1,29 +1,43 @@
 package api
 
 import (
+	"errors"
+	"ksana-service/internal/audit"
 	"ksana-service/internal/auth"
+	"ksana-service/internal/metrics"
+	"ksana-service/internal/store"
 	"log/slog"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 )
 
-func NewRouter(handler *JobHandler, authManager *auth.Manager, logger *slog.Logger) http.Handler {
+// NewRouter 中 jwtVerifier 为 nil 时不接受 Bearer 令牌
+func NewRouter(handler *JobHandler, authManager *auth.Manager, jwtVerifier *auth.JWTVerifier, logger *slog.Logger) http.Handler {
 	mux := http.NewServeMux()
 
-	authMiddleware := apiKeyMiddleware(authManager, logger)
+	authMiddleware := apiKeyMiddleware(authManager, jwtVerifier, logger)
 
 	mux.HandleFunc("/jobs", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPost:
-			handler.CreateJob(w, r)
+			handler.audited(audit.ActionJobCreate, handler.requireScope(auth.ScopeJobsWrite, handler.CreateJob))(w, r)
 		case http.MethodGet:
-			handler.ListJobs(w, r)
+			handler.requireScope(auth.ScopeJobsRead, handler.ListJobs)(w, r)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
 
+	mux.HandleFunc("/jobs:batch", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.audited(audit.ActionJobBatch, handler.Batch)(w, r)
+	}))
+
 	mux.HandleFunc("/jobs/", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		path := strings.TrimPrefix(r.URL.Path, "/jobs/")
 		parts := strings.Split(path, "/")
@@ -31,25 +45,57 @@ func NewRouter(handler *JobHandler, authManager *auth.Manager, logger *slog.Logg
 		if len(parts) == 1 && parts[0] != "" {
 			switch r.Method {
 			case http.MethodGet:
-				handler.GetJob(w, r)
+				handler.requireScope(auth.ScopeJobsRead, handler.GetJob)(w, r)
 			case http.MethodPatch:
-				handler.UpdateJob(w, r)
+				handler.audited(audit.ActionJobUpdate, handler.requireScope(auth.ScopeJobsWrite, handler.UpdateJob))(w, r)
 			case http.MethodDelete:
-				handler.DeleteJob(w, r)
+				handler.audited(audit.ActionJobDelete, handler.requireScope(auth.ScopeJobsWrite, handler.DeleteJob))(w, r)
 			default:
 				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			}
 			return
 		}
 
+		if len(parts) >= 2 && parts[0] != "" && parts[1] == "revisions" {
+			switch {
+			case len(parts) == 2 && r.Method == http.MethodGet:
+				handler.requireScope(auth.ScopeJobsRead, handler.ListRevisions)(w, r)
+			case len(parts) == 3 && parts[2] != "" && r.Method == http.MethodGet:
+				handler.requireScope(auth.ScopeJobsRead, handler.GetRevision)(w, r)
+			case len(parts) == 4 && parts[3] == "diff" && r.Method == http.MethodGet:
+				handler.requireScope(auth.ScopeJobsRead, handler.DiffRevisions)(w, r)
+			case len(parts) == 4 && parts[3] == "rollback" && r.Method == http.MethodPost:
+				handler.audited(audit.ActionRevisionRollback, handler.requireScope(auth.ScopeJobsWrite, handler.RollbackRevision))(w, r)
+			default:
+				http.Error(w, "Not found", http.StatusNotFound)
+			}
+			return
+		}
+
+		if len(parts) >= 2 && parts[0] != "" && parts[1] == "hooks" {
+			switch {
+			case len(parts) == 2 && r.Method == http.MethodGet:
+				handler.requireScope(auth.ScopeJobsRead, handler.ListHooks)(w, r)
+			case len(parts) == 2 && r.Method == http.MethodPost:
+				handler.audited(audit.ActionHookCreate, handler.requireScope(auth.ScopeJobsWrite, handler.CreateHook))(w, r)
+			case len(parts) == 3 && parts[2] != "" && r.Method == http.MethodDelete:
+				handler.audited(audit.ActionHookRevoke, handler.requireScope(auth.ScopeJobsWrite, handler.RevokeHook))(w, r)
+			case len(parts) == 4 && parts[2] != "" && parts[3] == "rotate" && r.Method == http.MethodPost:
+				handler.audited(audit.ActionHookRotate, handler.requireScope(auth.ScopeJobsWrite, handler.RotateHook))(w, r)
+			default:
+				http.Error(w, "Not found", http.StatusNotFound)
+			}
+			return
+		}
+
 		if len(parts) == 2 && parts[0] != "" && r.Method == http.MethodPost {
 			switch parts[1] {
 			case "run-now":
-				handler.RunNow(w, r)
+				handler.audited(audit.ActionJobRun, handler.requireScope(auth.ScopeJobsRun, handler.RunNow))(w, r)
 			case "pause":
-				handler.PauseJob(w, r)
+				handler.audited(audit.ActionJobPause, handler.requireScope(auth.ScopeJobsWrite, handler.PauseJob))(w, r)
 			case "resume":
-				handler.ResumeJob(w, r)
+				handler.audited(audit.ActionJobResume, handler.requireScope(auth.ScopeJobsWrite, handler.ResumeJob))(w, r)
 			default:
 				http.Error(w, "Not found", http.StatusNotFound)
 			}
@@ -59,9 +105,202 @@ func NewRouter(handler *JobHandler, authManager *auth.Manager, logger *slog.Logg
 		http.Error(w, "Not found", http.StatusNotFound)
 	}))
 
+	mux.HandleFunc("/runs", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.requireScope(auth.ScopeJobsRead, handler.ListRuns)(w, r)
+	}))
+
+	runRoutes := authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/runs/")
+		parts := strings.Split(path, "/")
+
+		if len(parts) == 1 && parts[0] != "" && r.Method == http.MethodGet {
+			handler.requireScope(auth.ScopeJobsRead, handler.GetRun)(w, r)
+			return
+		}
+
+		if len(parts) == 2 && parts[0] != "" && parts[1] == "cancel" && r.Method == http.MethodPost {
+			handler.audited(audit.ActionRunCancel, handler.requireScope(auth.ScopeJobsRun, handler.CancelRun))(w, r)
+			return
+		}
+
+		http.Error(w, "Not found", http.StatusNotFound)
+	})
+
+	mux.HandleFunc("/runs/", func(w http.ResponseWriter, r *http.Request) {
+		// 异步完成回调使用每次执行独立的令牌鉴权，不需要 API 密钥
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/runs/"), "/")
+		if len(parts) == 2 && parts[0] != "" && parts[1] == "complete" && r.Method == http.MethodPost {
+			handler.auditedAnonymous(audit.ActionRunComplete, handler.CompleteRun)(w, r)
+			return
+		}
+
+		runRoutes(w, r)
+	})
+
+	mux.HandleFunc("/watch", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.requireScope(auth.ScopeJobsRead, handler.Watch)(w, r)
+	}))
+
+	mux.HandleFunc("/apply", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.audited(audit.ActionApply, handler.requireScope(auth.ScopeJobsWrite, handler.Apply))(w, r)
+	}))
+
+	mux.HandleFunc("/trash", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.requireScope(auth.ScopeJobsRead, handler.ListTrash)(w, r)
+	}))
+
+	mux.HandleFunc("/trash/", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/trash/"), "/")
+
+		switch {
+		case len(parts) == 1 && parts[0] != "" && r.Method == http.MethodDelete:
+			handler.audited(audit.ActionTrashPurge, handler.requireScope(auth.ScopeJobsWrite, handler.PurgeTrash))(w, r)
+		case len(parts) == 2 && parts[0] != "" && parts[1] == "restore" && r.Method == http.MethodPost:
+			handler.audited(audit.ActionTrashRestore, handler.requireScope(auth.ScopeJobsWrite, handler.RestoreTrash))(w, r)
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+	}))
+
+	mux.HandleFunc("/secrets", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.requireScope(auth.ScopeAdmin, handler.ListSecrets)(w, r)
+	}))
+
+	mux.HandleFunc("/secrets/", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, "/secrets/") == "" {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			handler.audited(audit.ActionSecretPut, handler.requireScope(auth.ScopeAdmin, handler.PutSecret))(w, r)
+		case http.MethodDelete:
+			handler.audited(audit.ActionSecretDelete, handler.requireScope(auth.ScopeAdmin, handler.DeleteSecret))(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/admin/secrets/rotate", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.audited(audit.ActionSecretRotate, handler.requireScope(auth.ScopeAdmin, handler.RotateSecretsKey))(w, r)
+	}))
+
+	mux.HandleFunc("/admin/backups", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handler.audited(audit.ActionBackupCreate, handler.requireScope(auth.ScopeAdmin, handler.CreateBackup))(w, r)
+		case http.MethodGet:
+			handler.requireScope(auth.ScopeAdmin, handler.ListBackups)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/admin/keys", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.requireScope(auth.ScopeAdmin, handler.ListKeys)(w, r)
+		case http.MethodPost:
+			handler.audited(audit.ActionKeyCreate, handler.requireScope(auth.ScopeAdmin, handler.CreateKey))(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/admin/keys/", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/admin/keys/")
+		if id == "" || strings.Contains(id, "/") {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.audited(audit.ActionKeyRevoke, handler.requireScope(auth.ScopeAdmin, handler.RevokeKey))(w, r)
+	}))
+
+	mux.HandleFunc("/audit", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.requireScope(auth.ScopeAdmin, handler.ListAudit)(w, r)
+	}))
+
+	mux.HandleFunc("/audit/verify", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.requireScope(auth.ScopeAdmin, handler.VerifyAudit)(w, r)
+	}))
+
+	mux.HandleFunc("/admin/restore", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.audited(audit.ActionBackupRestore, handler.requireScope(auth.ScopeAdmin, handler.RestoreBackup))(w, r)
+	}))
+
+	// 入站 Webhook 以 URL 中的令牌鉴权，不需要 API 密钥
+	mux.HandleFunc("/hooks/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.auditedAnonymous(audit.ActionHookTrigger, handler.TriggerHook)(w, r)
+	})
+
 	mux.HandleFunc("/health", handler.Health)
+	mux.Handle("/metrics", metrics.Handler())
+
+	return corsMiddleware(loggingMiddleware(logger)(readOnlyMiddleware(handler)(mux)))
+}
 
-	return corsMiddleware(loggingMiddleware(logger)(mux))
+// readOnlyMiddleware 在存储因损坏进入只读模式时拒绝所有修改类请求；
+// 从备份恢复正是只读模式下的修复手段，不受限制
+func readOnlyMiddleware(handler *JobHandler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions:
+			case r.URL.Path == "/admin/restore":
+			default:
+				if handler.store.Status().ReadOnly {
+					handler.writeError(w, http.StatusServiceUnavailable, "Store is read-only", store.ErrReadOnly.Error())
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 func loggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
@@ -97,8 +336,9 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// 设置 CORS 头
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Ksana-Run-Token, X-Ksana-Signature, If-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, X-Ksana-Watch-Epoch, X-Ksana-Watch-Seq, X-Total-Count, X-Next-Cursor")
 		w.Header().Set("Access-Control-Max-Age", "86400") // 24小时
 
 		// 处理预检请求
@@ -112,7 +352,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func apiKeyMiddleware(authManager *auth.Manager, logger *slog.Logger) func(http.HandlerFunc) http.HandlerFunc {
+func apiKeyMiddleware(authManager *auth.Manager, jwtVerifier *auth.JWTVerifier, logger *slog.Logger) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			var apiKey string
@@ -123,6 +363,24 @@ func apiKeyMiddleware(authManager *auth.Manager, logger *slog.Logger) func(http.
 				if strings.HasPrefix(authHeader, prefix) {
 					apiKey = strings.TrimPrefix(authHeader, prefix)
 				}
+
+				const bearerPrefix = "Bearer "
+				if jwtVerifier != nil && strings.HasPrefix(authHeader, bearerPrefix) {
+					identity, err := jwtVerifier.Authenticate(strings.TrimPrefix(authHeader, bearerPrefix))
+					if err != nil {
+						logger.Warn("Bearer token authentication failed",
+							"error", err,
+							"client_ip", getClientIP(r),
+							"path", r.URL.Path,
+							"method", r.Method,
+						)
+						w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+						http.Error(w, "Invalid bearer token", http.StatusUnauthorized)
+						return
+					}
+					next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+					return
+				}
 			}
 
 			if apiKey == "" {
@@ -140,7 +398,19 @@ func apiKeyMiddleware(authManager *auth.Manager, logger *slog.Logger) func(http.
 				return
 			}
 
-			if !authManager.Validate(apiKey) {
+			identity, err := authManager.Authenticate(apiKey)
+			if errors.Is(err, auth.ErrKeyExpired) {
+				logger.Warn("API key authentication failed: expired key",
+					"key_id", identity.KeyID,
+					"client_ip", getClientIP(r),
+					"path", r.URL.Path,
+					"method", r.Method,
+				)
+				w.Header().Set("WWW-Authenticate", "ApiKey")
+				http.Error(w, "API key expired", http.StatusForbidden)
+				return
+			}
+			if err != nil {
 				logger.Warn("API key authentication failed: invalid key",
 					"client_ip", getClientIP(r),
 					"path", r.URL.Path,
@@ -151,11 +421,20 @@ func apiKeyMiddleware(authManager *auth.Manager, logger *slog.Logger) func(http.
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
 		})
 	}
 }
 
+// remoteHost 返回连接的对端地址（不含端口），不受客户端请求头影响
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func getClientIP(r *http.Request) string {
 	ip := r.Header.Get("X-Forwarded-For")
 	if ip == "" {
@@ -165,4 +444,4 @@ func getClientIP(r *http.Request) string {
 		ip = r.RemoteAddr
 	}
 	return ip
-}
\ No newline at end of file
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/executor"
+	"ksana-service/internal/model"
+	"net/http"
+	"strings"
+)
+
+// maxCompletionPayload 限制执行完成回调的请求体大小；该接口无需 API 密钥，在校验令牌前就会读取请求体
+const maxCompletionPayload = 64 << 10
+
+func (h *JobHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	selector, err := parseSelector(r.URL.Query())
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid query", err.Error())
+		return
+	}
+
+	runs := h.runs.ListRuns(r.URL.Query().Get("state"))
+
+	if !unrestricted(r) {
+		var accessible []model.Run
+		for _, run := range runs {
+			if canAccess(r, run.Namespace) {
+				accessible = append(accessible, run)
+			}
+		}
+		runs = accessible
+	}
+
+	// 按任务标签过滤执行记录
+	if !selector.Empty() {
+		jobs, err := h.store.ListBySelector(r.Context(), selector)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to list jobs", err.Error())
+			return
+		}
+
+		selected := make(map[string]bool, len(jobs))
+		for _, job := range jobs {
+			selected[job.ID] = true
+		}
+
+		var filtered []model.Run
+		for _, run := range runs {
+			if selected[run.JobID] {
+				filtered = append(filtered, run)
+			}
+		}
+		runs = filtered
+	}
+
+	if runs == nil {
+		runs = []model.Run{}
+	}
+
+	h.writeJSON(w, http.StatusOK, runs)
+}
+
+func (h *JobHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	runID := h.extractRunID(r)
+	if runID == "" {
+		h.writeError(w, http.StatusBadRequest, "Invalid run ID", "")
+		return
+	}
+
+	run, ok := h.lookupRun(w, r, runID)
+	if !ok {
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, run)
+}
+
+func (h *JobHandler) CancelRun(w http.ResponseWriter, r *http.Request) {
+	runID := h.extractRunID(r)
+	if runID == "" {
+		h.writeError(w, http.StatusBadRequest, "Invalid run ID", "")
+		return
+	}
+
+	run, ok := h.lookupRun(w, r, runID)
+	if !ok {
+		return
+	}
+	noteAudit(r).jobID = run.JobID
+
+	if err := h.runs.CancelRun(runID); err != nil {
+		switch {
+		case errors.Is(err, executor.ErrRunFinished):
+			h.writeError(w, http.StatusConflict, "Run already finished", err.Error())
+		default:
+			h.writeError(w, http.StatusNotFound, "Run not found", err.Error())
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusAccepted, map[string]string{"message": "Run cancellation requested"})
+}
+
+func (h *JobHandler) CompleteRun(w http.ResponseWriter, r *http.Request) {
+	runID := h.extractRunID(r)
+	if runID == "" {
+		h.writeError(w, http.StatusBadRequest, "Invalid run ID", "")
+		return
+	}
+
+	token := r.Header.Get("X-Ksana-Run-Token")
+	if token == "" {
+		h.writeError(w, http.StatusUnauthorized, "Run token required", "")
+		return
+	}
+
+	var req CompleteRunRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCompletionPayload)).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.writeError(w, http.StatusRequestEntityTooLarge, "Payload too large", fmt.Sprintf("request body exceeds %d bytes", maxCompletionPayload))
+			return
+		}
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+
+	err := h.runs.CompleteRun(runID, token, req.Status, req.Output, req.Error)
+	// 令牌校验通过后才记入审计日志
+	if err == nil || errors.Is(err, executor.ErrRunNotAwaiting) {
+		noteAudit(r).actor = "run:" + runID
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, executor.ErrInvalidCompletion):
+			h.writeError(w, http.StatusBadRequest, "Validation failed", err.Error())
+		case errors.Is(err, executor.ErrInvalidRunToken):
+			h.logger.Warn("Run completion rejected: invalid token",
+				"run_id", runID,
+				"client_ip", getClientIP(r))
+			h.writeError(w, http.StatusForbidden, "Invalid run token", "")
+		case errors.Is(err, executor.ErrRunFinished), errors.Is(err, executor.ErrRunNotAwaiting):
+			h.writeError(w, http.StatusConflict, "Run is not awaiting completion", err.Error())
+		default:
+			h.writeError(w, http.StatusNotFound, "Run not found", err.Error())
+		}
+		return
+	}
+
+	if run, err := h.runs.GetRun(runID); err == nil {
+		noteAudit(r).jobID = run.JobID
+	}
+	h.writeJSON(w, http.StatusOK, map[string]string{"message": "Run completed"})
+}
+
+// lookupRun 读取调用方可访问的执行记录，其它命名空间的记录视为不存在
+func (h *JobHandler) lookupRun(w http.ResponseWriter, r *http.Request, runID string) (*model.Run, bool) {
+	run, err := h.runs.GetRun(runID)
+	if err == nil && !canAccess(r, run.Namespace) {
+		err = executor.ErrRunNotFound
+	}
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "Run not found", err.Error())
+		return nil, false
+	}
+	return run, true
+}
+
+func (h *JobHandler) extractRunID(r *http.Request) string {
+	parts := strings.Split(r.URL.Path, "/")
+	for i, part := range parts {
+		if part == "runs" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"fmt"
+	"ksana-service/internal/auth"
+	"net/http"
+)
+
+// requireScope 在调用处理函数前检查调用方的权限，缺少时返回 403 并指明缺少的权限
+func (h *JobHandler) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.checkScope(w, r, scope) {
+			next(w, r)
+		}
+	}
+}
+
+func (h *JobHandler) checkScope(w http.ResponseWriter, r *http.Request, scope string) bool {
+	identity := auth.IdentityFromContext(r.Context())
+	if identity == nil || identity.HasScope(scope) {
+		return true
+	}
+
+	h.logger.Warn("Request rejected: missing scope",
+		"key_id", identity.KeyID,
+		"scope", scope,
+		"path", r.URL.Path,
+		"method", r.Method)
+	h.writeError(w, http.StatusForbidden, "Insufficient scope", fmt.Sprintf("the caller lacks the %q scope required for %s %s", scope, r.Method, r.URL.Path))
+	return false
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/auth"
+	"ksana-service/internal/model"
+	"ksana-service/internal/secrets"
+	"net/http"
+	"strings"
+)
+
+func (h *JobHandler) ListSecrets(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnrestricted(w, r) {
+		return
+	}
+
+	responses := []SecretResponse{}
+	for _, info := range h.secrets.List() {
+		responses = append(responses, SecretToResponse(&info))
+	}
+
+	h.writeJSON(w, http.StatusOK, responses)
+}
+
+// PutSecret 创建或更新密文；响应只包含元数据，密文值写入后不再通过 API 返回
+func (h *JobHandler) PutSecret(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnrestricted(w, r) {
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/secrets/")
+
+	var req PutSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON", err.Error())
+		return
+	}
+	if req.Value == "" {
+		h.writeError(w, http.StatusBadRequest, "Validation failed", "value is required")
+		return
+	}
+
+	// 收紧可用命名空间时，已在其它命名空间中引用该密文的任务会在执行时失败，需先修改这些任务
+	if len(req.Namespaces) > 0 {
+		users, err := h.secretUsers(r.Context(), name, func(def *model.JobDefinition) bool {
+			return !namespaceAllowed(req.Namespaces, def.Namespace)
+		})
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to list jobs", err.Error())
+			return
+		}
+		if len(users) > 0 {
+			h.writeError(w, http.StatusConflict, "Secret in use",
+				fmt.Sprintf("secret is referenced by jobs outside the given namespaces: %s", strings.Join(users, ", ")))
+			return
+		}
+	}
+
+	info, created, err := h.secrets.Put(name, req.Value, req.Namespaces)
+	if err != nil {
+		if errors.Is(err, secrets.ErrInvalidName) {
+			h.writeError(w, http.StatusBadRequest, "Invalid secret name", err.Error())
+			return
+		}
+		if errors.Is(err, secrets.ErrInvalidNamespace) {
+			h.writeError(w, http.StatusBadRequest, "Invalid namespace", err.Error())
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Failed to save secret", err.Error())
+		return
+	}
+
+	h.logger.Info("Secret saved", "name", name, "namespaces", info.Namespaces, "created", created, "key_id", callerKeyID(r))
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	h.writeJSON(w, status, SecretToResponse(info))
+}
+
+// DeleteSecret 删除密文；仍被任务引用时返回 409，避免任务在下次执行时失败
+func (h *JobHandler) DeleteSecret(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnrestricted(w, r) {
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/secrets/")
+
+	users, err := h.secretUsers(r.Context(), name, nil)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list jobs", err.Error())
+		return
+	}
+	if len(users) > 0 {
+		h.writeError(w, http.StatusConflict, "Secret in use",
+			fmt.Sprintf("secret is referenced by jobs: %s", strings.Join(users, ", ")))
+		return
+	}
+
+	if err := h.secrets.Delete(name); err != nil {
+		if errors.Is(err, secrets.ErrNotFound) {
+			h.writeError(w, http.StatusNotFound, "Secret not found", "")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Failed to delete secret", err.Error())
+		return
+	}
+
+	h.logger.Info("Secret deleted", "name", name, "key_id", callerKeyID(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *JobHandler) RotateSecretsKey(w http.ResponseWriter, r *http.Request) {
+	if !h.requireUnrestricted(w, r) {
+		return
+	}
+
+	keyID, count, err := h.secrets.Rotate()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to rotate master key", err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, RotateKeyResponse{KeyID: keyID, Secrets: count})
+}
+
+// secretUsers 返回引用了密文且满足 filter（为 nil 时不过滤）的任务 ID
+func (h *JobHandler) secretUsers(ctx context.Context, name string, filter func(*model.JobDefinition) bool) ([]string, error) {
+	defs, err := h.store.ListDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []string
+	for i := range defs {
+		if referencesSecret(&defs[i], name) && (filter == nil || filter(&defs[i])) {
+			users = append(users, defs[i].ID)
+		}
+	}
+	return users, nil
+}
+
+func referencesSecret(def *model.JobDefinition, name string) bool {
+	refs, err := def.HTTP.SecretRefs()
+	if err != nil {
+		return false
+	}
+	for _, ref := range refs {
+		if ref == name {
+			return true
+		}
+	}
+	return false
+}
+
+func namespaceAllowed(allowed []string, namespace string) bool {
+	for _, ns := range allowed {
+		if ns == auth.AllNamespaces || ns == namespace {
+			return true
+		}
+	}
+	return false
+}
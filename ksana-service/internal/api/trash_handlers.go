@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ksana-service/internal/model"
+	"ksana-service/internal/revision"
+	"ksana-service/internal/trash"
+	"net/http"
+	"strings"
+)
+
+func (h *JobHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	selector, err := parseSelector(r.URL.Query())
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid query", err.Error())
+		return
+	}
+
+	entries, err := h.trash.List()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list trash", err.Error())
+		return
+	}
+
+	responses := []TrashEntryResponse{}
+	for i := range entries {
+		if selector.Matches(entries[i].Job.Labels) && canAccess(r, entries[i].Job.Namespace) {
+			responses = append(responses, TrashEntryToResponse(&entries[i]))
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, responses)
+}
+
+func (h *JobHandler) RestoreTrash(w http.ResponseWriter, r *http.Request) {
+	id := h.extractTrashID(r)
+	entry, ok := h.lookupTrash(w, r, id)
+	if !ok {
+		return
+	}
+
+	// 下次运行时间由调度器按当前时间重新计算
+	job := entry.Job
+	job.NextRunAt = nil
+	if job.Namespace == "" {
+		job.Namespace = model.DefaultNamespace
+	}
+
+	// ID 与清单 key 的占用检查和写入都在 createMu 内完成，避免与并发的创建、清单应用或恢复交错
+	h.createMu.Lock()
+	defer h.createMu.Unlock()
+
+	if _, err := h.store.Get(r.Context(), id); err == nil {
+		h.writeError(w, http.StatusConflict, "Job already exists", "a job with this id exists, delete it before restoring")
+		return
+	}
+	if job.Key != "" {
+		owner, err := h.keyOwner(r.Context(), job.Key)
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "Failed to list jobs", err.Error())
+			return
+		}
+		if owner != "" {
+			h.writeError(w, http.StatusConflict, "Key already in use",
+				fmt.Sprintf("manifest key %q is used by job %s, delete it or change its key before restoring", job.Key, owner))
+			return
+		}
+	}
+
+	if err := h.checkJobCount(r.Context(), job.Namespace, 1); err != nil {
+		h.writeValidationError(w, err)
+		return
+	}
+
+	// 删除后密文的可用命名空间可能已经收紧
+	if err := h.checkSecretRefs(&job.JobDefinition); err != nil {
+		h.writeValidationError(w, err)
+		return
+	}
+
+	if err := h.store.Put(r.Context(), &job); err != nil {
+		h.writeStoreError(w, err, "Failed to restore job")
+		return
+	}
+
+	if err := h.scheduler.AddJob(&job); err != nil {
+		h.logger.Error("Failed to add job to scheduler", "job_id", job.ID, "error", err)
+	}
+
+	if err := h.trash.Remove(id); err != nil {
+		h.logger.Error("Failed to remove restored job from trash", "job_id", id, "error", err)
+	}
+
+	h.recordRevision(r, revision.ActionRestore, &job.JobDefinition, 0)
+	h.logger.Info("Job restored from trash", "job_id", id)
+
+	setETag(w, &job.JobDefinition)
+	h.writeJSON(w, http.StatusOK, JobToResponse(&job))
+}
+
+// keyOwner 返回使用该清单 key 的任务 ID，没有时为空；key 在所有命名空间中唯一
+func (h *JobHandler) keyOwner(ctx context.Context, key string) (string, error) {
+	defs, err := h.store.ListDefinitions(ctx)
+	if err != nil {
+		return "", err
+	}
+	for i := range defs {
+		if defs[i].Key == key {
+			return defs[i].ID, nil
+		}
+	}
+	return "", nil
+}
+
+func (h *JobHandler) PurgeTrash(w http.ResponseWriter, r *http.Request) {
+	id := h.extractTrashID(r)
+	if _, ok := h.lookupTrash(w, r, id); !ok {
+		return
+	}
+
+	if err := h.trash.Remove(id); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to purge job", err.Error())
+		return
+	}
+
+	h.logger.Info("Job purged from trash", "job_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *JobHandler) lookupTrash(w http.ResponseWriter, r *http.Request, id string) (*trash.Entry, bool) {
+	entry, err := h.trash.Get(id)
+	if err == nil && !canAccess(r, entry.Job.Namespace) {
+		err = fmt.Errorf("%w: %s", trash.ErrNotFound, id)
+	}
+	if errors.Is(err, trash.ErrNotFound) {
+		h.writeError(w, http.StatusNotFound, "Job not in trash", err.Error())
+		return nil, false
+	}
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to read trash", err.Error())
+		return nil, false
+	}
+	return entry, true
+}
+
+func (h *JobHandler) extractTrashID(r *http.Request) string {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/trash/"), "/")
+	return parts[0]
+}
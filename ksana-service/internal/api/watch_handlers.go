@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ksana-service/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultWatchTimeout = 30 * time.Second
+	maxWatchTimeout     = 5 * time.Minute
+	defaultWatchLimit   = 500
+	maxWatchLimit       = 5000
+)
+
+// Watch 以长轮询方式返回 since 之后的存储变更事件；没有新事件时等待到 timeout 后返回空列表。
+// 序号已不在缓冲区或 epoch 不一致（服务重启）时返回 410，调用方应重新拉取全量任务后从新的序号继续
+func (h *JobHandler) Watch(w http.ResponseWriter, r *http.Request) {
+	feed := h.store.Feed()
+	query := r.URL.Query()
+
+	if epoch := query.Get("epoch"); epoch != "" && epoch != feed.Epoch() {
+		h.writeWatchGone(w, feed, "epoch changed, the service has restarted")
+		return
+	}
+
+	since := feed.LastSeq()
+	if raw := query.Get("since"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			h.writeError(w, http.StatusBadRequest, "Invalid since", "since must be a non-negative sequence number")
+			return
+		}
+		since = parsed
+	}
+
+	timeout := defaultWatchTimeout
+	if raw := query.Get("timeout"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 || parsed > maxWatchTimeout {
+			h.writeError(w, http.StatusBadRequest, "Invalid timeout", fmt.Sprintf("timeout must be a duration between 0 and %s", maxWatchTimeout))
+			return
+		}
+		timeout = parsed
+	}
+
+	limit := defaultWatchLimit
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxWatchLimit {
+			h.writeError(w, http.StatusBadRequest, "Invalid limit", fmt.Sprintf("limit must be between 1 and %d", maxWatchLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	selector, err := parseSelector(query)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid query", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	resp := WatchResponse{
+		Epoch:   feed.Epoch(),
+		LastSeq: since,
+		Events:  []WatchEventResponse{},
+	}
+
+	// 带选择器时跳过不匹配的事件并继续等待，直到有匹配的事件或超时
+	for {
+		feed.Wait(ctx, resp.LastSeq)
+
+		events, lastSeq, err := feed.Since(resp.LastSeq, limit)
+		if err != nil {
+			if errors.Is(err, store.ErrSequenceOutOfRange) {
+				h.writeWatchGone(w, feed, err.Error())
+				return
+			}
+			h.writeError(w, http.StatusInternalServerError, "Failed to read changes", err.Error())
+			return
+		}
+
+		// 分批返回时 last_seq 为本批最后一条事件的序号，调用方以此作为下一次的 since
+		resp.LastSeq = lastSeq
+		if len(events) > 0 {
+			resp.LastSeq = events[len(events)-1].Seq
+		}
+
+		for i := range events {
+			if events[i].Job == nil || (selector.Matches(events[i].Job.Labels) && canAccess(r, events[i].Job.Namespace)) {
+				resp.Events = append(resp.Events, EventToResponse(&events[i]))
+			}
+		}
+
+		if len(resp.Events) > 0 || ctx.Err() != nil {
+			break
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *JobHandler) writeWatchGone(w http.ResponseWriter, feed *store.Feed, message string) {
+	setWatchHeaders(w, feed)
+	h.writeError(w, http.StatusGone, "Relist required", message)
+}
+
+// setWatchHeaders 返回当前的 epoch 与最新序号，配合全量列表使用时在列表之前读取，保证不会漏掉事件
+func setWatchHeaders(w http.ResponseWriter, feed *store.Feed) {
+	w.Header().Set("X-Ksana-Watch-Epoch", feed.Epoch())
+	w.Header().Set("X-Ksana-Watch-Seq", strconv.FormatInt(feed.LastSeq(), 10))
+}
@@ -0,0 +1,555 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/diff"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+
+	ActionJobCreate        = "job.create"
+	ActionJobUpdate        = "job.update"
+	ActionJobDelete        = "job.delete"
+	ActionJobPause         = "job.pause"
+	ActionJobResume        = "job.resume"
+	ActionJobRun           = "job.run"
+	ActionJobBatch         = "job.batch"
+	ActionRevisionRollback = "revision.rollback"
+	ActionHookCreate       = "hook.create"
+	ActionHookRevoke       = "hook.revoke"
+	ActionHookRotate       = "hook.rotate"
+	ActionHookTrigger      = "hook.trigger"
+	ActionRunCancel        = "run.cancel"
+	ActionRunComplete      = "run.complete"
+	ActionApply            = "apply"
+	ActionTrashRestore     = "trash.restore"
+	ActionTrashPurge       = "trash.purge"
+	ActionSecretPut        = "secret.put"
+	ActionSecretDelete     = "secret.delete"
+	ActionSecretRotate     = "secret.rotate"
+	ActionBackupCreate     = "backup.create"
+	ActionBackupRestore    = "backup.restore"
+	ActionKeyCreate        = "key.create"
+	ActionKeyRevoke        = "key.revoke"
+
+	segmentPrefix = "audit-"
+	segmentSuffix = ".jsonl"
+	segmentLayout = "2006-01-02"
+	anchorFile    = "anchor.json"
+)
+
+// JobChange 为批量操作或清单应用中单个任务的变更
+type JobChange struct {
+	JobID   string        `json:"job_id"`
+	Op      string        `json:"op,omitempty"`
+	Changes []diff.Change `json:"changes,omitempty"`
+}
+
+// Entry 为一条审计记录。Hash 为本条记录（不含 hash 字段）的原始 JSON 的 SHA-256，
+// 记录中包含上一条的 Hash，修改或删除任意一条都会使其后的链校验失败
+type Entry struct {
+	Seq      int64         `json:"seq"`
+	At       time.Time     `json:"at"`
+	Actor    string        `json:"actor"`
+	ClientIP string        `json:"client_ip"`
+	Method   string        `json:"method"`
+	Path     string        `json:"path"`
+	Action   string        `json:"action"`
+	JobID    string        `json:"job_id,omitempty"`
+	Target   string        `json:"target,omitempty"`
+	DryRun   bool          `json:"dry_run,omitempty"`
+	Changes  []diff.Change `json:"changes,omitempty"`
+	Jobs     []JobChange   `json:"jobs,omitempty"`
+	Status   int           `json:"status"`
+	Outcome  string        `json:"outcome"`
+	Error    string        `json:"error,omitempty"`
+	PrevHash string        `json:"prev_hash"`
+	Hash     string        `json:"hash,omitempty"`
+}
+
+type Filter struct {
+	From   time.Time
+	To     time.Time
+	Actor  string
+	Action string
+	JobID  string
+	Limit  int
+}
+
+func (f *Filter) match(entry *Entry) bool {
+	if !f.From.IsZero() && entry.At.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !entry.At.Before(f.To) {
+		return false
+	}
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if f.JobID != "" && entry.JobID != f.JobID {
+		found := false
+		for _, job := range entry.Jobs {
+			if job.JobID == f.JobID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// anchor 记录按保留期清理掉的最后一条记录，剩余日志的第一条必须与之相连
+type anchor struct {
+	Seq      int64     `json:"seq"`
+	Hash     string    `json:"hash"`
+	PrunedAt time.Time `json:"pruned_at"`
+}
+
+type VerifyResult struct {
+	Valid    bool   `json:"valid"`
+	Entries  int    `json:"entries"`
+	FirstSeq int64  `json:"first_seq,omitempty"`
+	LastSeq  int64  `json:"last_seq,omitempty"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	File     string `json:"file,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Log 将审计记录按 UTC 日期追加到 audit/audit-YYYY-MM-DD.jsonl，保留期外的整天文件定期清理
+type Log struct {
+	dir       string
+	retention time.Duration
+	logger    *slog.Logger
+
+	mu       sync.Mutex
+	lastSeq  int64
+	lastHash string
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func New(dataDir string, retention time.Duration, logger *slog.Logger) *Log {
+	return &Log{
+		dir:       filepath.Join(dataDir, "audit"),
+		retention: retention,
+		logger:    logger,
+	}
+}
+
+// Open 读取最后一条记录以继续哈希链，并校验整条链；链断裂时只记录错误，不阻止启动
+func (l *Log) Open() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	result, last, err := l.verifyLocked()
+	if err != nil {
+		return err
+	}
+	if last != nil {
+		l.lastSeq = last.Seq
+		l.lastHash = last.Hash
+	} else if a, err := l.readAnchor(); err != nil {
+		return err
+	} else if a != nil {
+		l.lastSeq = a.Seq
+		l.lastHash = a.Hash
+	}
+
+	if !result.Valid {
+		l.logger.Error("Audit log hash chain verification failed",
+			"broken_at", result.BrokenAt, "file", result.File, "reason", result.Reason)
+	} else {
+		l.logger.Info("Audit log verified", "entries", result.Entries, "last_seq", result.LastSeq)
+	}
+	return nil
+}
+
+// Append 为记录分配序号与时间并写入哈希链
+func (l *Log) Append(entry *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Seq = l.lastSeq + 1
+	entry.At = time.Now().UTC()
+	entry.PrevHash = l.lastHash
+	entry.Hash = ""
+
+	line, hash, err := encode(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	path := l.segmentPath(entry.At)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	// 上次写入中断留下的半行需要先补换行，否则会与本条记录粘连
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		tail := make([]byte, 1)
+		if _, err := file.ReadAt(tail, info.Size()-1); err == nil && tail[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	entry.Hash = hash
+	l.lastSeq = entry.Seq
+	l.lastHash = hash
+	return nil
+}
+
+// encode 返回写入文件的行与哈希：哈希覆盖不含 hash 字段的 JSON，
+// 再把 hash 作为最后一个字段拼接上去，校验时去掉该字段即可还原出原始字节
+func encode(entry *Entry) ([]byte, string, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	line := append(data[:len(data)-1:len(data)-1], []byte(`,"hash":"`+hash+`"}`)...)
+	return line, hash, nil
+}
+
+// decode 解析一行记录并校验其哈希
+func decode(line []byte) (*Entry, error) {
+	var entry Entry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return nil, err
+	}
+
+	suffix := []byte(`,"hash":"` + entry.Hash + `"}`)
+	if len(entry.Hash) != sha256.Size*2 || !bytes.HasSuffix(line, suffix) {
+		return &entry, errors.New("missing or misplaced hash")
+	}
+	original := append(line[:len(line)-len(suffix):len(line)-len(suffix)], '}')
+	sum := sha256.Sum256(original)
+	if hex.EncodeToString(sum[:]) != entry.Hash {
+		return &entry, errors.New("hash mismatch")
+	}
+	return &entry, nil
+}
+
+// Query 返回符合条件的记录，按时间倒序，最多 Limit 条
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	segments, err := l.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for i := len(segments) - 1; i >= 0; i-- {
+		day := segments[i].day
+		if !filter.From.IsZero() && day.Add(24*time.Hour).Before(filter.From) {
+			break
+		}
+		if !filter.To.IsZero() && !day.Before(filter.To) {
+			continue
+		}
+
+		var matched []Entry
+		err := readSegment(segments[i].path, func(entry *Entry, _ error) bool {
+			if entry != nil && filter.match(entry) {
+				matched = append(matched, *entry)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for j := len(matched) - 1; j >= 0; j-- {
+			entries = append(entries, matched[j])
+			if filter.Limit > 0 && len(entries) >= filter.Limit {
+				return entries, nil
+			}
+		}
+	}
+	return entries, nil
+}
+
+func (l *Log) Verify() (VerifyResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result, _, err := l.verifyLocked()
+	return result, err
+}
+
+// verifyLocked 依次校验所有记录的哈希、序号连续性与链接关系，返回最后一条完好的记录
+func (l *Log) verifyLocked() (VerifyResult, *Entry, error) {
+	result := VerifyResult{Valid: true}
+
+	a, err := l.readAnchor()
+	if err != nil {
+		return result, nil, err
+	}
+	prevHash, prevSeq := "", int64(0)
+	if a != nil {
+		prevHash, prevSeq = a.Hash, a.Seq
+	}
+
+	segments, err := l.segments()
+	if err != nil {
+		return result, nil, err
+	}
+
+	var last *Entry
+	for _, segment := range segments {
+		err := readSegment(segment.path, func(entry *Entry, decodeErr error) bool {
+			reason := ""
+			switch {
+			case decodeErr != nil:
+				reason = decodeErr.Error()
+			case entry.Seq != prevSeq+1:
+				reason = fmt.Sprintf("expected seq %d, found %d", prevSeq+1, entry.Seq)
+			case entry.PrevHash != prevHash:
+				reason = "prev_hash does not match the previous entry"
+			}
+			if reason != "" {
+				result.Valid = false
+				result.BrokenAt = prevSeq + 1
+				result.File = filepath.Base(segment.path)
+				result.Reason = reason
+				return false
+			}
+
+			if result.FirstSeq == 0 {
+				result.FirstSeq = entry.Seq
+			}
+			result.Entries++
+			result.LastSeq = entry.Seq
+			prevSeq, prevHash = entry.Seq, entry.Hash
+			last = entry
+			return true
+		})
+		if err != nil {
+			return result, nil, err
+		}
+		if !result.Valid {
+			break
+		}
+	}
+
+	// 链断裂时从最后一个文件的最后一条记录继续，新记录不会掩盖断裂处
+	if !result.Valid && len(segments) > 0 {
+		readSegment(segments[len(segments)-1].path, func(entry *Entry, decodeErr error) bool {
+			if entry != nil && entry.Hash != "" {
+				last = entry
+			}
+			return true
+		})
+	}
+	return result, last, nil
+}
+
+type segment struct {
+	path string
+	day  time.Time
+}
+
+func (l *Log) segments() ([]segment, error) {
+	names, err := os.ReadDir(l.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read audit directory: %w", err)
+	}
+
+	var segments []segment
+	for _, name := range names {
+		base := name.Name()
+		if !strings.HasPrefix(base, segmentPrefix) || !strings.HasSuffix(base, segmentSuffix) {
+			continue
+		}
+		day, err := time.Parse(segmentLayout, strings.TrimSuffix(strings.TrimPrefix(base, segmentPrefix), segmentSuffix))
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{path: filepath.Join(l.dir, base), day: day})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].day.Before(segments[j].day)
+	})
+	return segments, nil
+}
+
+func (l *Log) segmentPath(at time.Time) string {
+	return filepath.Join(l.dir, segmentPrefix+at.UTC().Format(segmentLayout)+segmentSuffix)
+}
+
+// readSegment 逐行回调，visit 返回 false 时停止；哈希不符的行同时传入记录与错误，
+// 无法解析的行记录为 nil
+func readSegment(path string, visit func(entry *Entry, decodeErr error) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if !visit(decode(line)) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+func (l *Log) readAnchor() (*anchor, error) {
+	data, err := os.ReadFile(filepath.Join(l.dir, anchorFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read audit anchor: %w", err)
+	}
+
+	var a anchor
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("failed to parse audit anchor: %w", err)
+	}
+	return &a, nil
+}
+
+func (l *Log) Start() {
+	if l.retention <= 0 || l.stopCh != nil {
+		return
+	}
+
+	l.stopCh = make(chan struct{})
+	l.wg.Add(1)
+	go l.pruneLoop()
+}
+
+func (l *Log) Stop() {
+	if l.stopCh == nil {
+		return
+	}
+	close(l.stopCh)
+	l.wg.Wait()
+	l.stopCh = nil
+}
+
+func (l *Log) pruneLoop() {
+	defer l.wg.Done()
+
+	l.prune()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stopCh:
+			return
+		case <-ticker.C:
+			l.prune()
+		}
+	}
+}
+
+// prune 删除整天都在保留期之外的文件；删除前把其中最后一条记录写入锚点，
+// 剩余日志仍可从锚点开始完整校验
+func (l *Log) prune() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	segments, err := l.segments()
+	if err != nil {
+		l.logger.Error("Failed to list audit log files", "error", err)
+		return
+	}
+
+	cutoff := time.Now().UTC().Add(-l.retention)
+	var expired []segment
+	for _, segment := range segments {
+		if !segment.day.Add(24 * time.Hour).Before(cutoff) {
+			break
+		}
+		expired = append(expired, segment)
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	var last *Entry
+	for i := len(expired) - 1; i >= 0 && last == nil; i-- {
+		readSegment(expired[i].path, func(entry *Entry, decodeErr error) bool {
+			if decodeErr == nil {
+				last = entry
+			}
+			return true
+		})
+	}
+
+	if last != nil {
+		data, _ := json.Marshal(anchor{Seq: last.Seq, Hash: last.Hash, PrunedAt: time.Now().UTC()})
+		tmp := filepath.Join(l.dir, anchorFile+".tmp")
+		if err := os.WriteFile(tmp, data, 0600); err != nil {
+			l.logger.Error("Failed to write audit anchor", "error", err)
+			return
+		}
+		if err := os.Rename(tmp, filepath.Join(l.dir, anchorFile)); err != nil {
+			l.logger.Error("Failed to write audit anchor", "error", err)
+			return
+		}
+	}
+
+	for _, segment := range expired {
+		if err := os.Remove(segment.path); err != nil {
+			l.logger.Error("Failed to remove expired audit log", "file", segment.path, "error", err)
+			continue
+		}
+		l.logger.Info("Removed expired audit log", "file", filepath.Base(segment.path))
+	}
+}
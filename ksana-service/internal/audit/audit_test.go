@@ -0,0 +1,241 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLog(t *testing.T, dir string, retention time.Duration) *Log {
+	t.Helper()
+
+	l := New(dir, retention, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := l.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return l
+}
+
+func appendEntries(t *testing.T, l *Log, actors ...string) {
+	t.Helper()
+
+	for _, actor := range actors {
+		if err := l.Append(&Entry{Actor: actor, Action: ActionJobRun, Status: 200, Outcome: OutcomeSuccess}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+}
+
+func verify(t *testing.T, l *Log) VerifyResult {
+	t.Helper()
+
+	result, err := l.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	return result
+}
+
+// ageSegments 把当天的日志文件改名为 days 天前的文件
+func ageSegments(t *testing.T, l *Log, days int) {
+	t.Helper()
+
+	now := time.Now()
+	if err := os.Rename(l.segmentPath(now), l.segmentPath(now.AddDate(0, 0, -days))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppendContinuesChainAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	l := newTestLog(t, dir, 0)
+	appendEntries(t, l, "a", "b")
+
+	l = newTestLog(t, dir, 0)
+	appendEntries(t, l, "c")
+
+	result := verify(t, l)
+	if !result.Valid || result.Entries != 3 || result.FirstSeq != 1 || result.LastSeq != 3 {
+		t.Errorf("verify = %+v, want 3 valid entries", result)
+	}
+
+	entries, err := l.Query(Filter{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Actor != "c" || entries[1].Actor != "b" {
+		t.Errorf("query = %+v, want c and b", entries)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name       string
+		tamper     func(lines [][]byte) [][]byte
+		wantBroken int64
+		wantReason string
+	}{
+		{
+			name: "edited field",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte(`"actor":"b"`), []byte(`"actor":"x"`), 1)
+				return lines
+			},
+			wantBroken: 2,
+			wantReason: "hash mismatch",
+		},
+		{
+			name: "removed hash",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = append(lines[1][:bytes.LastIndex(lines[1], []byte(`,"hash"`))], '}')
+				return lines
+			},
+			wantBroken: 2,
+			wantReason: "missing or misplaced hash",
+		},
+		{
+			name: "deleted entry",
+			tamper: func(lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+			wantBroken: 2,
+			wantReason: "expected seq 2, found 3",
+		},
+		{
+			name: "forged entry with a fresh hash",
+			tamper: func(lines [][]byte) [][]byte {
+				entry, _ := decode(lines[1])
+				entry.Actor, entry.PrevHash, entry.Hash = "x", strings.Repeat("0", 64), ""
+				lines[1], _, _ = encode(entry)
+				return lines
+			},
+			wantBroken: 2,
+			wantReason: "prev_hash does not match",
+		},
+		{
+			name: "torn line",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[2] = lines[2][:len(lines[2])/2]
+				return lines
+			},
+			wantBroken: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			l := newTestLog(t, dir, 0)
+			appendEntries(t, l, "a", "b", "c")
+
+			path := l.segmentPath(time.Now())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := tt.tamper(bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")))
+			if err := os.WriteFile(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			result := verify(t, l)
+			if result.Valid || result.BrokenAt != tt.wantBroken || !strings.Contains(result.Reason, tt.wantReason) {
+				t.Errorf("verify = %+v, want broken at %d with %q", result, tt.wantBroken, tt.wantReason)
+			}
+			if result.File != filepath.Base(path) {
+				t.Errorf("file = %q, want %q", result.File, filepath.Base(path))
+			}
+
+			// 断裂后重新打开仍可追加，断裂处保持可见
+			l = newTestLog(t, dir, 0)
+			appendEntries(t, l, "d")
+			if result := verify(t, l); result.Valid || result.BrokenAt != tt.wantBroken {
+				t.Errorf("verify after append = %+v, want still broken at %d", result, tt.wantBroken)
+			}
+		})
+	}
+}
+
+func TestPruneWritesAnchor(t *testing.T) {
+	dir := t.TempDir()
+
+	l := newTestLog(t, dir, 48*time.Hour)
+	appendEntries(t, l, "a", "b")
+	ageSegments(t, l, 10)
+	appendEntries(t, l, "c")
+
+	l.prune()
+
+	if _, err := os.Stat(l.segmentPath(time.Now().AddDate(0, 0, -10))); !os.IsNotExist(err) {
+		t.Errorf("expired segment still exists: %v", err)
+	}
+	a, err := l.readAnchor()
+	if err != nil || a == nil || a.Seq != 2 {
+		t.Fatalf("anchor = %+v, %v; want seq 2", a, err)
+	}
+
+	result := verify(t, l)
+	if !result.Valid || result.Entries != 1 || result.FirstSeq != 3 {
+		t.Errorf("verify after prune = %+v, want 1 valid entry starting at seq 3", result)
+	}
+
+	// 锚点被篡改后剩余日志无法与之相连
+	tampered := *a
+	tampered.Hash = strings.Repeat("0", 64)
+	writeAnchor(t, l, tampered)
+	if result := verify(t, l); result.Valid || result.BrokenAt != 3 {
+		t.Errorf("verify with tampered anchor = %+v, want broken at 3", result)
+	}
+}
+
+func TestOpenContinuesFromAnchor(t *testing.T) {
+	dir := t.TempDir()
+
+	l := newTestLog(t, dir, 48*time.Hour)
+	appendEntries(t, l, "a", "b")
+	ageSegments(t, l, 10)
+	l.prune()
+
+	// 所有文件都已清理，重新打开后从锚点继续编号与链接
+	l = newTestLog(t, dir, 48*time.Hour)
+	appendEntries(t, l, "c")
+
+	result := verify(t, l)
+	if !result.Valid || result.Entries != 1 || result.FirstSeq != 3 {
+		t.Errorf("verify = %+v, want 1 valid entry starting at seq 3", result)
+	}
+}
+
+func TestPruneKeepsSegmentsWithinRetention(t *testing.T) {
+	dir := t.TempDir()
+
+	l := newTestLog(t, dir, 48*time.Hour)
+	appendEntries(t, l, "a")
+	ageSegments(t, l, 1)
+	appendEntries(t, l, "b")
+
+	l.prune()
+
+	if a, err := l.readAnchor(); err != nil || a != nil {
+		t.Errorf("anchor = %+v, %v; want none", a, err)
+	}
+	if result := verify(t, l); !result.Valid || result.Entries != 2 {
+		t.Errorf("verify = %+v, want 2 valid entries", result)
+	}
+}
+
+func writeAnchor(t *testing.T, l *Log, a anchor) {
+	t.Helper()
+
+	data, _ := json.Marshal(a)
+	if err := os.WriteFile(filepath.Join(l.dir, anchorFile), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// AllNamespaces 表示密钥可以访问所有命名空间
+const AllNamespaces = "*"
+
+const (
+	ScopeJobsRead  = "jobs:read"
+	ScopeJobsWrite = "jobs:write"
+	ScopeJobsRun   = "jobs:run"
+
+	// ScopeAdmin 包含所有权限，另外可以管理密文与备份
+	ScopeAdmin = "admin"
+)
+
+// AllScopes 为未声明权限的密钥所拥有的权限，与旧格式兼容
+var AllScopes = []string{ScopeAdmin}
+
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeJobsRead, ScopeJobsWrite, ScopeJobsRun, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+type Identity struct {
+	KeyID      string   `json:"key_id"`
+	Namespaces []string `json:"namespaces"`
+	Scopes     []string `json:"scopes"`
+}
+
+func (i *Identity) HasScope(scope string) bool {
+	for _, granted := range i.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Identity) CanAccess(namespace string) bool {
+	for _, allowed := range i.Namespaces {
+		if allowed == AllNamespaces || allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// Unrestricted 表示密钥绑定了所有命名空间，可以执行密文、备份等全局操作
+func (i *Identity) Unrestricted() bool {
+	for _, allowed := range i.Namespaces {
+		if allowed == AllNamespaces {
+			return true
+		}
+	}
+	return false
+}
+
+type identityContextKey struct{}
+
+// KeyID 返回密钥的短指纹，用于日志与审计记录中标识调用方而不暴露密钥本身
+func KeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityContextKey{}).(*Identity)
+	return identity
+}
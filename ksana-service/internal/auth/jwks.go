@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+
+	minRSABits  = 2048
+	minHMACSize = 32
+)
+
+// jwk 为 JWKS 中的一个密钥（RFC 7517），支持 oct、RSA 与 EC P-256
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// verificationKey 为解析后的公钥或对称密钥，alg 为该密钥唯一允许的签名算法
+type verificationKey struct {
+	kid   string
+	alg   string
+	hmac  []byte
+	rsa   *rsa.PublicKey
+	ecdsa *ecdsa.PublicKey
+}
+
+func parseJWKS(data []byte) ([]verificationKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	if len(set.Keys) == 0 {
+		return nil, errors.New("no keys")
+	}
+
+	keys := make([]verificationKey, 0, len(set.Keys))
+	kids := make(map[string]bool)
+	for i, raw := range set.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+		key, err := raw.parse()
+		if err != nil {
+			return nil, fmt.Errorf("key %d (kid %q): %w", i, raw.Kid, err)
+		}
+		if key.kid != "" {
+			if kids[key.kid] {
+				return nil, fmt.Errorf("duplicate kid %q", key.kid)
+			}
+			kids[key.kid] = true
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	return keys, nil
+}
+
+func (k *jwk) parse() (verificationKey, error) {
+	key := verificationKey{kid: k.Kid}
+
+	switch k.Kty {
+	case "oct":
+		key.alg = AlgHS256
+		secret, err := decodeSegment(k.K)
+		if err != nil {
+			return key, fmt.Errorf("k: %w", err)
+		}
+		if len(secret) < minHMACSize {
+			return key, fmt.Errorf("HMAC key must be at least %d bytes", minHMACSize)
+		}
+		key.hmac = secret
+
+	case "RSA":
+		key.alg = AlgRS256
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return key, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return key, fmt.Errorf("e: %w", err)
+		}
+		if n.BitLen() < minRSABits {
+			return key, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return key, errors.New("invalid RSA exponent")
+		}
+		key.rsa = &rsa.PublicKey{N: n, E: int(e.Int64())}
+
+	case "EC":
+		key.alg = AlgES256
+		if k.Crv != "P-256" {
+			return key, fmt.Errorf("unsupported curve %q (only P-256)", k.Crv)
+		}
+		x, err := decodeSegment(k.X)
+		if err != nil {
+			return key, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeSegment(k.Y)
+		if err != nil {
+			return key, fmt.Errorf("y: %w", err)
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return key, errors.New("P-256 coordinates must be 32 bytes")
+		}
+		// 借助 crypto/ecdh 校验点位于曲线上
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return key, fmt.Errorf("invalid P-256 point: %w", err)
+		}
+		key.ecdsa = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+
+	default:
+		return key, fmt.Errorf("unsupported kty %q", k.Kty)
+	}
+
+	if k.Alg != "" && k.Alg != key.alg {
+		return key, fmt.Errorf("alg %q does not match kty %s", k.Alg, k.Kty)
+	}
+	return key, nil
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := decodeSegment(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,405 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/model"
+	"log/slog"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// JWTConfig 为 Bearer 令牌鉴权的配置。RoleScopes 将角色映射为权限，为空时角色名本身
+// 必须是权限名（如 jobs:read）；声明名称支持以 . 分隔的嵌套路径（如 realm_access.roles）
+type JWTConfig struct {
+	JWKSFile        string
+	Issuer          string
+	Audience        string
+	RolesClaim      string
+	NamespacesClaim string
+	RoleScopes      map[string][]string
+	Leeway          time.Duration
+	ReloadInterval  time.Duration
+}
+
+// JWTVerifier 使用本地 JWKS 文件校验 HS256/RS256/ES256 签名的令牌，文件变化时自动重新加载
+type JWTVerifier struct {
+	config JWTConfig
+	logger *slog.Logger
+
+	mu   sync.RWMutex
+	keys []verificationKey
+
+	fileMu sync.Mutex
+	file   fileState
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewJWTVerifier(config JWTConfig, logger *slog.Logger) (*JWTVerifier, error) {
+	if config.RolesClaim == "" {
+		config.RolesClaim = "roles"
+	}
+	if config.NamespacesClaim == "" {
+		config.NamespacesClaim = "namespaces"
+	}
+
+	v := &JWTVerifier{config: config, logger: logger}
+
+	v.fileMu.Lock()
+	defer v.fileMu.Unlock()
+	if err := v.loadKeys(); err != nil {
+		return nil, fmt.Errorf("failed to load JWKS: %w", err)
+	}
+
+	if config.Issuer == "" || config.Audience == "" {
+		logger.Warn("JWT issuer or audience not configured, tokens from any issuer signed by the JWKS keys are accepted",
+			"issuer", config.Issuer, "audience", config.Audience)
+	}
+	return v, nil
+}
+
+// loadKeys 解析并校验 JWKS 文件后才替换当前密钥，调用方需持有 fileMu
+func (v *JWTVerifier) loadKeys() error {
+	data, state, err := readKeyFile(v.config.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS file %s: %w", v.config.JWKSFile, err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("JWKS file %s: %w", v.config.JWKSFile, err)
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+	v.file = state
+
+	v.logger.Info("Loaded JWKS", "keys", len(keys), "file", v.config.JWKSFile)
+	return nil
+}
+
+func (v *JWTVerifier) Reload() error {
+	v.fileMu.Lock()
+	defer v.fileMu.Unlock()
+
+	if err := v.loadKeys(); err != nil {
+		v.logger.Error("Failed to reload JWKS, keeping current keys", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (v *JWTVerifier) Start() {
+	if v.config.ReloadInterval <= 0 || v.stopCh != nil {
+		return
+	}
+
+	v.stopCh = make(chan struct{})
+	v.wg.Add(1)
+	go v.watchLoop()
+}
+
+func (v *JWTVerifier) Stop() {
+	if v.stopCh == nil {
+		return
+	}
+	close(v.stopCh)
+	v.wg.Wait()
+	v.stopCh = nil
+}
+
+func (v *JWTVerifier) watchLoop() {
+	defer v.wg.Done()
+
+	ticker := time.NewTicker(v.config.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-v.stopCh:
+			return
+		case <-ticker.C:
+			v.fileMu.Lock()
+			changed := v.file.refresh(v.config.JWKSFile)
+			v.fileMu.Unlock()
+			if changed {
+				v.Reload()
+			}
+		}
+	}
+}
+
+type jwtHeader struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid"`
+	Typ  string   `json:"typ"`
+	Crit []string `json:"crit"`
+}
+
+// Authenticate 校验令牌签名与 exp/nbf/iss/aud，并按配置的声明映射出权限与命名空间。
+// 身份的 KeyID 为 jwt:<sub>
+func (v *JWTVerifier) Authenticate(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header jwtHeader
+	if err := decodeJSONSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	if len(header.Crit) > 0 {
+		return nil, fmt.Errorf("%w: unsupported critical header %v", ErrInvalidToken, header.Crit)
+	}
+	switch header.Alg {
+	case AlgHS256, AlgRS256, AlgES256:
+	default:
+		return nil, fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, header.Alg)
+	}
+
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	if !v.verifySignature(&header, parts[0]+"."+parts[1], signature) {
+		return nil, fmt.Errorf("%w: signature verification failed", ErrInvalidToken)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJSONSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+	identity := &Identity{KeyID: "jwt:" + subject}
+
+	identity.Scopes = v.mapScopes(claimValues(claims, v.config.RolesClaim))
+	if len(identity.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: no recognized roles in claim %s", ErrInvalidToken, v.config.RolesClaim)
+	}
+
+	for _, namespace := range claimValues(claims, v.config.NamespacesClaim) {
+		if namespace != AllNamespaces && !model.ValidNamespace(namespace) {
+			return nil, fmt.Errorf("%w: invalid namespace %q in claim %s", ErrInvalidToken, namespace, v.config.NamespacesClaim)
+		}
+		identity.Namespaces = append(identity.Namespaces, namespace)
+	}
+	if len(identity.Namespaces) == 0 {
+		return nil, fmt.Errorf("%w: missing claim %s", ErrInvalidToken, v.config.NamespacesClaim)
+	}
+
+	return identity, nil
+}
+
+// verifySignature 只使用与 alg 匹配的密钥；令牌指定 kid 时只使用该密钥
+func (v *JWTVerifier) verifySignature(header *jwtHeader, signingInput string, signature []byte) bool {
+	v.mu.RLock()
+	keys := v.keys
+	v.mu.RUnlock()
+
+	digest := sha256.Sum256([]byte(signingInput))
+	for i := range keys {
+		key := &keys[i]
+		if key.alg != header.Alg || (header.Kid != "" && key.kid != header.Kid) {
+			continue
+		}
+
+		switch key.alg {
+		case AlgHS256:
+			mac := hmac.New(sha256.New, key.hmac)
+			mac.Write([]byte(signingInput))
+			if hmac.Equal(mac.Sum(nil), signature) {
+				return true
+			}
+		case AlgRS256:
+			if rsa.VerifyPKCS1v15(key.rsa, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		case AlgES256:
+			// JWS 中的 ES256 签名为定长的 R||S，而不是 ASN.1 编码
+			if len(signature) != 64 {
+				continue
+			}
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			if ecdsa.Verify(key.ecdsa, digest[:], r, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *JWTVerifier) checkClaims(claims map[string]interface{}, now time.Time) error {
+	leeway := v.config.Leeway
+
+	exp, ok, err := numericDate(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("missing exp claim")
+	}
+	if !now.Before(exp.Add(leeway)) {
+		return errors.New("token expired")
+	}
+
+	nbf, ok, err := numericDate(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(leeway).Before(nbf) {
+		return errors.New("token not yet valid")
+	}
+
+	if v.config.Issuer != "" {
+		if issuer, _ := claims["iss"].(string); issuer != v.config.Issuer {
+			return fmt.Errorf("unexpected issuer %q", issuer)
+		}
+	}
+
+	if v.config.Audience != "" {
+		// aud 可以是单个字符串或字符串数组（RFC 7519 4.1.3）
+		var audiences []interface{}
+		switch aud := claims["aud"].(type) {
+		case string:
+			audiences = []interface{}{aud}
+		case []interface{}:
+			audiences = aud
+		}
+
+		found := false
+		for _, audience := range audiences {
+			if audience == v.config.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.New("audience does not match")
+		}
+	}
+	return nil
+}
+
+func (v *JWTVerifier) mapScopes(roles []string) []string {
+	var scopes []string
+	seen := make(map[string]bool)
+	add := func(scope string) {
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	for _, role := range roles {
+		if len(v.config.RoleScopes) == 0 {
+			if ValidScope(role) {
+				add(role)
+			}
+			continue
+		}
+		for _, scope := range v.config.RoleScopes[role] {
+			add(scope)
+		}
+	}
+	return scopes
+}
+
+// ParseRoleScopes 解析 `role=scope,scope;role2=scope` 形式的角色映射
+func ParseRoleScopes(spec string) (map[string][]string, error) {
+	mapping := make(map[string][]string)
+	for _, item := range strings.Split(spec, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		role, value, ok := strings.Cut(item, "=")
+		role = strings.TrimSpace(role)
+		if !ok || role == "" || value == "" {
+			return nil, fmt.Errorf("role mapping %q must be role=scope[,scope]", item)
+		}
+		for _, scope := range strings.Split(value, ",") {
+			scope = strings.TrimSpace(scope)
+			if !ValidScope(scope) {
+				return nil, fmt.Errorf("role %s: invalid scope %q (expected jobs:read, jobs:write, jobs:run or admin)", role, scope)
+			}
+			mapping[role] = append(mapping[role], scope)
+		}
+	}
+	return mapping, nil
+}
+
+func decodeJSONSegment(segment string, target interface{}) error {
+	data, err := decodeSegment(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(target)
+}
+
+func numericDate(claims map[string]interface{}, name string) (time.Time, bool, error) {
+	raw, exists := claims[name]
+	if !exists {
+		return time.Time{}, false, nil
+	}
+	number, ok := raw.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%s claim must be a number", name)
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s claim: %v", name, err)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true, nil
+}
+
+// claimValues 按 . 分隔的路径读取声明，字符串按空白与逗号拆分，数组取其中的字符串
+func claimValues(claims map[string]interface{}, path string) []string {
+	var current interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = object[name]
+	}
+
+	var values []string
+	switch value := current.(type) {
+	case string:
+		values = strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	case []interface{}:
+		for _, item := range value {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
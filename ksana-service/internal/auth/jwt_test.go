@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testKeys struct {
+	hmac []byte
+	rsa  *rsa.PrivateKey
+	ec   *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKeys{hmac: []byte(strings.Repeat("k", 32)), rsa: rsaKey, ec: ecKey}
+}
+
+func (k *testKeys) jwks() []byte {
+	set := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "oct", "kid": "hs", "k": b64(k.hmac)},
+		{"kty": "RSA", "kid": "rs", "n": b64(k.rsa.N.Bytes()), "e": b64(big.NewInt(int64(k.rsa.E)).Bytes())},
+		{"kty": "EC", "kid": "es", "crv": "P-256", "x": b64(k.ec.X.FillBytes(make([]byte, 32))), "y": b64(k.ec.Y.FillBytes(make([]byte, 32)))},
+	}}
+	data, _ := json.Marshal(set)
+	return data
+}
+
+// sign 按 header 中的 alg 签名；alg 不是 HS256/RS256/ES256 时签名为空
+func (k *testKeys) sign(t *testing.T, header, claims map[string]interface{}) string {
+	t.Helper()
+
+	headerData, _ := json.Marshal(header)
+	claimsData, _ := json.Marshal(claims)
+	input := b64(headerData) + "." + b64(claimsData)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch header["alg"] {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, k.hmac)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case AlgRS256:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case AlgES256:
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + b64(signature)
+}
+
+func newTestVerifier(t *testing.T, keys *testKeys, config JWTConfig) *JWTVerifier {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, keys.jwks(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config.JWKSFile = path
+
+	v, err := NewJWTVerifier(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+	return v
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	keys := newTestKeys(t)
+	v := newTestVerifier(t, keys, JWTConfig{Issuer: "https://idp", Audience: "ksana", Leeway: 30 * time.Second})
+
+	now := time.Now().Unix()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":        "alice",
+			"iss":        "https://idp",
+			"aud":        "ksana",
+			"exp":        now + 300,
+			"roles":      []string{"jobs:read", "jobs:run", "unknown-role"},
+			"namespaces": "billing, ops",
+		}
+		for name, value := range overrides {
+			if value == nil {
+				delete(c, name)
+				continue
+			}
+			c[name] = value
+		}
+		return c
+	}
+	header := func(alg, kid string) map[string]interface{} {
+		return map[string]interface{}{"alg": alg, "kid": kid, "typ": "JWT"}
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "HS256", token: keys.sign(t, header(AlgHS256, "hs"), claims(nil))},
+		{name: "RS256", token: keys.sign(t, header(AlgRS256, "rs"), claims(nil))},
+		{name: "ES256", token: keys.sign(t, header(AlgES256, ""), claims(nil))},
+		{name: "audience array", token: keys.sign(t, header(AlgHS256, "hs"), claims(map[string]interface{}{"aud": []string{"other", "ksana"}}))},
+		{name: "expired within leeway", token: keys.sign(t, header(AlgHS256, "hs"), claims(map[string]interface{}{"exp": now - 10}))},
+
+		{name: "malformed", token: "a.b", wantErr: "malformed token"},
+		{name: "alg none", token: keys.sign(t, header("none", ""), claims(nil)), wantErr: `unsupported alg "none"`},
+		{name: "alg HS512", token: keys.sign(t, header("HS512", ""), claims(nil)), wantErr: `unsupported alg "HS512"`},
+		{name: "critical header", token: keys.sign(t, map[string]interface{}{"alg": AlgHS256, "crit": []string{"b64"}}, claims(nil)), wantErr: "unsupported critical header"},
+		{name: "kid of a key with another alg", token: keys.sign(t, header(AlgHS256, "rs"), claims(nil)), wantErr: "signature verification failed"},
+		{name: "unknown kid", token: keys.sign(t, header(AlgHS256, "missing"), claims(nil)), wantErr: "signature verification failed"},
+		{name: "tampered claims", token: tamper(keys.sign(t, header(AlgHS256, "hs"), claims(nil))), wantErr: "signature verification failed"},
+		{name: "expired", token: keys.sign(t, header(AlgHS256, "hs"), claims(map[string]interface{}{"exp": now - 60})), wantErr: "token expired"},
+		{name: "missing exp", token: keys.sign(t, header(AlgHS256, "hs"), claims(map[string]interface{}{"exp": nil})), wantErr: "missing exp claim"},
+		{name: "exp not a number", token: keys.sign(t, header(AlgHS256, "hs"), claims(map[string]interface{}{"exp": "tomorrow"})), wantErr: "exp claim must be a number"},
+		{name: "not yet valid", token: keys.sign(t, header(AlgHS256, "hs"), claims(map[string]interface{}{"nbf": now + 120})), wantErr: "token not yet valid"},
+		{name: "wrong issuer", token: keys.sign(t, header(AlgHS256, "hs"), claims(map[string]interface{}{"iss": "https://evil"})), wantErr: "unexpected issuer"},
+		{name: "wrong audience", token: keys.sign(t, header(AlgHS256, "hs"), claims(map[string]interface{}{"aud": []string{"other"}})), wantErr: "audience does not match"},
+		{name: "missing sub", token: keys.sign(t, header(AlgHS256, "hs"), claims(map[string]interface{}{"sub": nil})), wantErr: "missing sub claim"},
+		{name: "no recognized roles", token: keys.sign(t, header(AlgHS256, "hs"), claims(map[string]interface{}{"roles": []string{"viewer"}})), wantErr: "no recognized roles"},
+		{name: "invalid namespace", token: keys.sign(t, header(AlgHS256, "hs"), claims(map[string]interface{}{"namespaces": []string{"Bad_NS"}})), wantErr: "invalid namespace"},
+		{name: "missing namespaces", token: keys.sign(t, header(AlgHS256, "hs"), claims(map[string]interface{}{"namespaces": nil})), wantErr: "missing claim namespaces"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := v.Authenticate(tt.token)
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrInvalidToken) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want ErrInvalidToken containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+
+			want := &Identity{
+				KeyID:      "jwt:alice",
+				Scopes:     []string{"jobs:read", "jobs:run"},
+				Namespaces: []string{"billing", "ops"},
+			}
+			if !reflect.DeepEqual(identity, want) {
+				t.Errorf("identity = %+v, want %+v", identity, want)
+			}
+		})
+	}
+}
+
+func TestJWTRoleMapping(t *testing.T) {
+	keys := newTestKeys(t)
+	v := newTestVerifier(t, keys, JWTConfig{
+		RolesClaim:      "realm_access.roles",
+		NamespacesClaim: "tenant",
+		RoleScopes:      map[string][]string{"operator": {"jobs:read", "jobs:run"}, "editor": {"jobs:read", "jobs:write"}},
+	})
+
+	token := keys.sign(t, map[string]interface{}{"alg": AlgES256}, map[string]interface{}{
+		"sub":          "bob",
+		"exp":          time.Now().Add(time.Minute).Unix(),
+		"realm_access": map[string]interface{}{"roles": []string{"operator", "editor", "jobs:write-is-not-a-role"}},
+		"tenant":       "*",
+	})
+
+	identity, err := v.Authenticate(token)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if want := []string{"jobs:read", "jobs:run", "jobs:write"}; !reflect.DeepEqual(identity.Scopes, want) {
+		t.Errorf("scopes = %v, want %v", identity.Scopes, want)
+	}
+	if want := []string{AllNamespaces}; !reflect.DeepEqual(identity.Namespaces, want) {
+		t.Errorf("namespaces = %v, want %v", identity.Namespaces, want)
+	}
+}
+
+func TestParseJWKSRejects(t *testing.T) {
+	keys := newTestKeys(t)
+	smallRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		jwks    string
+		wantErr string
+	}{
+		{name: "no keys", jwks: `{"keys": []}`, wantErr: "no keys"},
+		{name: "only encryption keys", jwks: `{"keys": [{"kty": "oct", "use": "enc", "k": "` + b64(keys.hmac) + `"}]}`, wantErr: "no signing keys"},
+		{name: "short HMAC key", jwks: `{"keys": [{"kty": "oct", "k": "` + b64([]byte("short")) + `"}]}`, wantErr: "at least 32 bytes"},
+		{name: "small RSA key", jwks: `{"keys": [{"kty": "RSA", "n": "` + b64(smallRSA.N.Bytes()) + `", "e": "AQAB"}]}`, wantErr: "at least 2048 bits"},
+		{name: "alg does not match kty", jwks: `{"keys": [{"kty": "oct", "alg": "RS256", "k": "` + b64(keys.hmac) + `"}]}`, wantErr: "does not match kty"},
+		{name: "unsupported curve", jwks: `{"keys": [{"kty": "EC", "crv": "P-384", "x": "AA", "y": "AA"}]}`, wantErr: "unsupported curve"},
+		{name: "point not on curve", jwks: `{"keys": [{"kty": "EC", "crv": "P-256", "x": "` + b64(make([]byte, 32)) + `", "y": "` + b64(make([]byte, 32)) + `"}]}`, wantErr: "invalid P-256 point"},
+		{name: "duplicate kid", jwks: `{"keys": [{"kty": "oct", "kid": "a", "k": "` + b64(keys.hmac) + `"}, {"kty": "oct", "kid": "a", "k": "` + b64(keys.hmac) + `"}]}`, wantErr: "duplicate kid"},
+		{name: "unsupported kty", jwks: `{"keys": [{"kty": "OKP"}]}`, wantErr: "unsupported kty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseJWKS([]byte(tt.jwks))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseRoleScopes(t *testing.T) {
+	mapping, err := ParseRoleScopes(" operator = jobs:read, jobs:run ; admin=admin;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"operator": {"jobs:read", "jobs:run"}, "admin": {"admin"}}
+	if !reflect.DeepEqual(mapping, want) {
+		t.Errorf("mapping = %v, want %v", mapping, want)
+	}
+
+	for _, spec := range []string{"operator", "=jobs:read", "operator=", "operator=jobs:delete"} {
+		if _, err := ParseRoleScopes(spec); err == nil {
+			t.Errorf("ParseRoleScopes(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// tamper 把声明中的 alice 换成 mallory，保留原签名
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	parts[1] = b64([]byte(strings.Replace(string(claims), "alice", "mallory", 1)))
+	return strings.Join(parts, ".")
+}
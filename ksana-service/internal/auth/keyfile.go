@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrKeyNotFound  = errors.New("API key not found")
+	ErrLastAdminKey = errors.New("refusing to remove the last unexpired admin key bound to all namespaces")
+)
+
+// fileState 记录密钥文件的修改时间、大小与内容哈希；轮询时先比较修改时间与大小，
+// 变化后再比较哈希，避免只是 touch 文件就重新加载
+type fileState struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+func readKeyFile(path string) ([]byte, fileState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fileState{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fileState{}, err
+	}
+	return data, fileState{modTime: info.ModTime(), size: info.Size(), hash: sha256.Sum256(data)}, nil
+}
+
+// Start 开始轮询密钥文件，文件内容变化时重新加载
+func (m *Manager) Start() {
+	if m.reloadInterval <= 0 || m.stopCh != nil {
+		return
+	}
+
+	m.stopCh = make(chan struct{})
+	m.wg.Add(1)
+	go m.watchLoop()
+}
+
+func (m *Manager) Stop() {
+	if m.stopCh == nil {
+		return
+	}
+	close(m.stopCh)
+	m.wg.Wait()
+	m.stopCh = nil
+}
+
+func (m *Manager) watchLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+			if m.fileChanged() {
+				m.Reload()
+			}
+		}
+	}
+}
+
+func (m *Manager) fileChanged() bool {
+	m.fileMu.Lock()
+	defer m.fileMu.Unlock()
+	return m.file.refresh(m.keyFile)
+}
+
+// refresh 检查文件内容是否与上次记录的不同。无论随后加载成功与否都记录本次状态，
+// 校验失败的文件不会在每次轮询时重复加载
+func (s *fileState) refresh(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	previous := s.hash
+	*s = fileState{modTime: info.ModTime(), size: info.Size(), hash: sha256.Sum256(data)}
+	return s.hash != previous
+}
+
+// CreateKey 生成新密钥并追加到密钥文件，返回密钥本身（只在此时可见）与其元数据
+func (m *Manager) CreateKey(spec KeySpec) (string, *KeyInfo, error) {
+	key, line, err := GenerateKey(spec)
+	if err != nil {
+		return "", nil, err
+	}
+
+	m.fileMu.Lock()
+	defer m.fileMu.Unlock()
+
+	data, _, err := readKeyFile(m.keyFile)
+	if err != nil && !os.IsNotExist(err) {
+		return "", nil, fmt.Errorf("failed to read API keys file: %w", err)
+	}
+
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += line + "\n"
+
+	if err := m.writeKeyFile(content); err != nil {
+		return "", nil, err
+	}
+
+	info, _ := m.keyInfo(KeyID(key))
+	return key, info, nil
+}
+
+// RevokeKey 从密钥文件中移除密钥，原行替换为注释以便追溯；
+// 拒绝撤销最后一个可用的管理员密钥
+func (m *Manager) RevokeKey(id, revokedBy string) (*KeyInfo, error) {
+	m.fileMu.Lock()
+	defer m.fileMu.Unlock()
+
+	info, ok := m.keyInfo(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+
+	data, _, err := readKeyFile(m.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys file: %w", err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	found := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		entry, err := parseKeyLine(trimmed)
+		if err != nil || entry.id != id {
+			continue
+		}
+		// 明文密钥不能保留在注释中，只记录 id；revokedBy 可能来自 JWT 的 sub，需转义后才能写入注释
+		lines[i] = fmt.Sprintf("# revoked %s by %s: id=%s", time.Now().UTC().Format(time.RFC3339), commentSafe(revokedBy), id)
+		found = true
+	}
+	if !found {
+		// 内存中的密钥与文件不一致（文件已被修改但尚未重新加载）
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+
+	if err := m.writeKeyFile(strings.Join(lines, "\n")); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// commentSafe 返回可以安全写入单行注释的文本：包含控制字符或非 ASCII 字符时按 Go 字符串字面量转义
+func commentSafe(text string) string {
+	for _, r := range text {
+		if r < 0x20 || r > 0x7e {
+			return strconv.QuoteToASCII(text)
+		}
+	}
+	return text
+}
+
+// writeKeyFile 校验新内容后原子替换密钥文件并重新加载，调用方需持有 fileMu
+func (m *Manager) writeKeyFile(content string) error {
+	keys, err := parseKeyFile([]byte(content))
+	if err != nil {
+		return fmt.Errorf("API keys file %s %w", m.keyFile, err)
+	}
+
+	m.mu.RLock()
+	lockout := hasAdmin(m.keys) && !hasAdmin(keys)
+	m.mu.RUnlock()
+	if lockout {
+		return ErrLastAdminKey
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.keyFile), 0755); err != nil {
+		return fmt.Errorf("failed to create API keys directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.keyFile), ".api_keys-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.keyFile); err != nil {
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+
+	return m.loadKeys(false)
+}
+
+func (m *Manager) keyInfo(id string) (*KeyInfo, bool) {
+	for _, info := range m.Keys() {
+		if info.ID == id {
+			return &info, true
+		}
+	}
+	return nil, false
+}
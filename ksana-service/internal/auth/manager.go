@@ -1,96 +1,349 @@
 package auth
 
 import (
-	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"ksana-service/internal/model"
 	"log/slog"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var (
+	ErrInvalidKey = errors.New("invalid API key")
+	ErrKeyExpired = errors.New("API key expired")
+)
+
+// keyEntry 为密钥文件中的一行，支持两种格式：
+//
+//	id=<id> sha256=<hex> [name=..] [owner=..] [created=..] [expires=..] [namespaces=..] [scopes=..]
+//	<key> [namespaces=..] [scopes=..]   （明文密钥，仅为兼容旧文件）
+//
+// 未声明的 namespaces/scopes 不做限制，与旧格式兼容
+type keyEntry struct {
+	id         string
+	hash       [sha256.Size]byte
+	name       string
+	owner      string
+	created    time.Time
+	expires    time.Time
+	namespaces []string
+	scopes     []string
+	plaintext  bool
+
+	// lastUsed 为最近一次鉴权成功的时间（UnixNano），重新加载时按 id 保留
+	lastUsed atomic.Int64
+}
+
+// KeyInfo 为密钥的元数据，不包含密钥与哈希
+type KeyInfo struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name,omitempty"`
+	Owner      string     `json:"owner,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Expired    bool       `json:"expired"`
+	Plaintext  bool       `json:"plaintext"`
+	Namespaces []string   `json:"namespaces"`
+	Scopes     []string   `json:"scopes"`
+}
+
 type Manager struct {
-	keyFile string
-	keys    map[string]struct{}
-	mu      sync.RWMutex
-	logger  *slog.Logger
+	keyFile        string
+	reloadInterval time.Duration
+	keys           []*keyEntry
+	mu             sync.RWMutex
+	logger         *slog.Logger
+
+	// fileMu 串行化重新加载与管理接口对密钥文件的读写，file 为最近一次检查的文件状态
+	fileMu sync.Mutex
+	file   fileState
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
 }
 
-func NewManager(keyFile string, logger *slog.Logger) (*Manager, error) {
+// NewManager 加载密钥文件；reloadInterval 大于 0 时 Start 之后按该间隔检查文件变化并自动重新加载
+func NewManager(keyFile string, reloadInterval time.Duration, logger *slog.Logger) (*Manager, error) {
 	m := &Manager{
-		keyFile: keyFile,
-		keys:    make(map[string]struct{}),
-		logger:  logger,
+		keyFile:        keyFile,
+		reloadInterval: reloadInterval,
+		logger:         logger,
 	}
 
-	if err := m.loadKeys(); err != nil {
+	m.fileMu.Lock()
+	defer m.fileMu.Unlock()
+	if err := m.loadKeys(false); err != nil {
 		return nil, fmt.Errorf("failed to load API keys: %w", err)
 	}
 
 	return m, nil
 }
 
-func (m *Manager) loadKeys() error {
-	file, err := os.Open(m.keyFile)
+// loadKeys 读取并完整校验密钥文件后才替换内存中的密钥，文件有误时保留原有密钥。
+// guard 为 true 时（运行中重新加载）拒绝会移除所有可用管理员密钥的文件，调用方需持有 fileMu
+func (m *Manager) loadKeys(guard bool) error {
+	data, state, err := readKeyFile(m.keyFile)
 	if err != nil {
 		if os.IsNotExist(err) {
+			if guard {
+				return fmt.Errorf("API keys file %s not found, keeping current keys", m.keyFile)
+			}
 			m.logger.Warn("API keys file not found, authentication will reject all requests", "file", m.keyFile)
 			return nil
 		}
-		return fmt.Errorf("failed to open API keys file %s: %w", m.keyFile, err)
+		return fmt.Errorf("failed to read API keys file %s: %w", m.keyFile, err)
+	}
+
+	newKeys, err := parseKeyFile(data)
+	if err != nil {
+		return fmt.Errorf("API keys file %s %w", m.keyFile, err)
 	}
-	defer file.Close()
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	newKeys := make(map[string]struct{})
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
+	if guard && hasAdmin(m.keys) && !hasAdmin(newKeys) {
+		return fmt.Errorf("API keys file %s has no unexpired admin key bound to all namespaces, keeping current keys", m.keyFile)
+	}
+
+	previous := make(map[string]*keyEntry, len(m.keys))
+	for _, entry := range m.keys {
+		previous[entry.id] = entry
+	}
+	plaintext := 0
+	for _, entry := range newKeys {
+		if old, exists := previous[entry.id]; exists {
+			entry.lastUsed.Store(old.lastUsed.Load())
+		}
+		if entry.plaintext {
+			plaintext++
+		}
+	}
+
+	m.keys = newKeys
+	m.file = state
+	m.logger.Info("Loaded API keys", "count", len(m.keys), "file", m.keyFile)
+	if plaintext > 0 {
+		m.logger.Warn("API keys file contains plaintext keys, replace them with hashed entries generated by `keygen`", "count", plaintext)
+	}
 
-	for scanner.Scan() {
-		lineNum++
-		line := strings.TrimSpace(scanner.Text())
+	return nil
+}
+
+func parseKeyFile(data []byte) ([]*keyEntry, error) {
+	var keys []*keyEntry
+	ids := make(map[string]bool)
 
+	for lineNum, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
 
-		if line != "" {
-			newKeys[line] = struct{}{}
+		entry, err := parseKeyLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum+1, err)
 		}
+		if ids[entry.id] {
+			return nil, fmt.Errorf("line %d: duplicate key id %s", lineNum+1, entry.id)
+		}
+		ids[entry.id] = true
+		keys = append(keys, entry)
 	}
+	return keys, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading API keys file %s: %w", m.keyFile, err)
+// hasAdmin 判断是否存在未过期、拥有 admin 权限且不限命名空间的密钥
+func hasAdmin(keys []*keyEntry) bool {
+	now := time.Now()
+	for _, entry := range keys {
+		if entry.expired(now) {
+			continue
+		}
+		identity := Identity{Namespaces: entry.namespaces, Scopes: entry.scopes}
+		if identity.HasScope(ScopeAdmin) && identity.Unrestricted() {
+			return true
+		}
 	}
+	return false
+}
 
-	m.keys = newKeys
-	m.logger.Info("Loaded API keys", "count", len(m.keys), "file", m.keyFile)
+func (e *keyEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
 
-	return nil
+func parseKeyLine(line string) (*keyEntry, error) {
+	fields := strings.Fields(line)
+	entry := &keyEntry{namespaces: []string{AllNamespaces}, scopes: AllScopes}
+
+	attributes := fields
+	if !strings.Contains(fields[0], "=") {
+		entry.id = KeyID(fields[0])
+		entry.hash = sha256.Sum256([]byte(fields[0]))
+		entry.plaintext = true
+		attributes = fields[1:]
+	}
+
+	hasHash := false
+	for _, field := range attributes {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("attribute %q must be name=value", field)
+		}
+
+		var err error
+		switch name {
+		case "id", "sha256", "name", "owner", "created", "expires":
+			if entry.plaintext {
+				return nil, fmt.Errorf("attribute %q is only allowed on hashed entries", name)
+			}
+		}
+
+		switch name {
+		case "id":
+			entry.id = value
+		case "sha256":
+			var sum []byte
+			sum, err = hex.DecodeString(value)
+			if err != nil || len(sum) != sha256.Size {
+				return nil, errors.New("sha256 must be 64 hex characters")
+			}
+			copy(entry.hash[:], sum)
+			hasHash = true
+		case "name":
+			entry.name = value
+		case "owner":
+			entry.owner = value
+		case "created":
+			entry.created, err = parseKeyTime(value)
+		case "expires":
+			entry.expires, err = parseKeyTime(value)
+		case "namespaces":
+			entry.namespaces = nil
+			for _, namespace := range strings.Split(value, ",") {
+				if namespace != AllNamespaces && !model.ValidNamespace(namespace) {
+					return nil, fmt.Errorf("invalid namespace %q", namespace)
+				}
+				entry.namespaces = append(entry.namespaces, namespace)
+			}
+		case "scopes":
+			entry.scopes = nil
+			for _, scope := range strings.Split(value, ",") {
+				if !ValidScope(scope) {
+					return nil, fmt.Errorf("invalid scope %q (expected jobs:read, jobs:write, jobs:run or admin)", scope)
+				}
+				entry.scopes = append(entry.scopes, scope)
+			}
+		default:
+			return nil, fmt.Errorf("unknown attribute %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", name, err)
+		}
+	}
+
+	if !entry.plaintext && (entry.id == "" || !hasHash) {
+		return nil, errors.New("hashed entries require both id and sha256")
+	}
+	return entry, nil
+}
+
+// parseKeyTime 接受 RFC 3339 时间或 YYYY-MM-DD 日期（UTC 零点）
+func parseKeyTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
 }
 
 func (m *Manager) Validate(key string) bool {
+	_, err := m.Authenticate(key)
+	return err == nil
+}
+
+// Authenticate 校验密钥并返回调用方身份。密钥的 SHA-256 与所有条目逐一做常数时间比较，
+// 比较次数与匹配位置无关；过期的密钥返回 ErrKeyExpired
+func (m *Manager) Authenticate(key string) (*Identity, error) {
 	if key == "" {
-		return false
+		return nil, ErrInvalidKey
 	}
+	sum := sha256.Sum256([]byte(key))
 
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	_, exists := m.keys[key]
-	return exists
+	var match *keyEntry
+	for _, entry := range m.keys {
+		if subtle.ConstantTimeCompare(sum[:], entry.hash[:]) == 1 {
+			match = entry
+		}
+	}
+	if match == nil {
+		return nil, ErrInvalidKey
+	}
+
+	now := time.Now()
+	if match.expired(now) {
+		return &Identity{KeyID: match.id}, ErrKeyExpired
+	}
+
+	match.lastUsed.Store(now.UnixNano())
+	return &Identity{KeyID: match.id, Namespaces: match.namespaces, Scopes: match.scopes}, nil
+}
+
+// Keys 返回所有密钥的元数据，按密钥文件中的顺序
+func (m *Manager) Keys() []KeyInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	infos := make([]KeyInfo, 0, len(m.keys))
+	for _, entry := range m.keys {
+		info := KeyInfo{
+			ID:         entry.id,
+			Name:       entry.name,
+			Owner:      entry.owner,
+			CreatedAt:  optionalTime(entry.created),
+			ExpiresAt:  optionalTime(entry.expires),
+			Expired:    entry.expired(now),
+			Plaintext:  entry.plaintext,
+			Namespaces: entry.namespaces,
+			Scopes:     entry.scopes,
+		}
+		if lastUsed := entry.lastUsed.Load(); lastUsed != 0 {
+			info.LastUsedAt = optionalTime(time.Unix(0, lastUsed))
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
 }
 
 func (m *Manager) Reload() error {
 	m.logger.Info("Reloading API keys", "file", m.keyFile)
 
-	if err := m.loadKeys(); err != nil {
+	m.fileMu.Lock()
+	defer m.fileMu.Unlock()
+
+	if err := m.loadKeys(true); err != nil {
 		m.logger.Error("Failed to reload API keys", "error", err)
 		return err
 	}
 
 	m.logger.Info("Successfully reloaded API keys")
 	return nil
-}
\ No newline at end of file
+}
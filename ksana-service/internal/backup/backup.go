@@ -0,0 +1,380 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"ksana-service/internal/metrics"
+	"ksana-service/internal/model"
+	"ksana-service/internal/store"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TriggerManual     = "manual"
+	TriggerScheduled  = "scheduled"
+	TriggerPreRestore = "pre-restore"
+
+	manifestFile = "manifest.json"
+	jobsFile     = "jobs.json"
+)
+
+var (
+	ErrNotFound = errors.New("backup not found")
+	ErrInvalid  = errors.New("invalid backup")
+)
+
+var (
+	backupsCreated = metrics.NewCounter("ksana_backups_created_total", "Backups written to the backups directory.")
+	backupFailures = metrics.NewCounter("ksana_backup_failures_total", "Backup or restore attempts that failed.")
+)
+
+type Manifest struct {
+	ID           string    `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Trigger      string    `json:"trigger"`
+	StoreVersion int       `json:"store_version"`
+	Jobs         int       `json:"jobs"`
+	Files        []File    `json:"files"`
+
+	ResourceVersion int64 `json:"resource_version"`
+}
+
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type Reloader interface {
+	Reload() error
+}
+
+type Manager struct {
+	dir      string
+	store    store.Store
+	reloader Reloader
+	interval time.Duration
+	retain   int
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewManager(dataDir string, store store.Store, reloader Reloader, interval time.Duration, retain int, logger *slog.Logger) *Manager {
+	return &Manager{
+		dir:      filepath.Join(dataDir, "backups"),
+		store:    store,
+		reloader: reloader,
+		interval: interval,
+		retain:   retain,
+		logger:   logger,
+	}
+}
+
+func (m *Manager) Start() {
+	if m.interval <= 0 || m.stopCh != nil {
+		return
+	}
+
+	m.stopCh = make(chan struct{})
+	m.wg.Add(1)
+	go m.scheduleLoop()
+}
+
+func (m *Manager) Stop() {
+	if m.stopCh == nil {
+		return
+	}
+	close(m.stopCh)
+	m.wg.Wait()
+	m.stopCh = nil
+}
+
+func (m *Manager) Create(ctx context.Context, trigger string) (*Manifest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	manifest, err := m.createLocked(ctx, trigger)
+	if err != nil {
+		backupFailures.Inc()
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func (m *Manager) List() ([]Manifest, error) {
+	entries, err := os.ReadDir(m.dir)
+	if os.IsNotExist(err) {
+		return []Manifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backups directory: %w", err)
+	}
+
+	manifests := []Manifest{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		manifest, err := m.readManifest(entry.Name())
+		if err != nil {
+			m.logger.Warn("Skipping unreadable backup", "backup_id", entry.Name(), "error", err)
+			continue
+		}
+		manifests = append(manifests, *manifest)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].CreatedAt.After(manifests[j].CreatedAt)
+	})
+	return manifests, nil
+}
+
+// Restore 校验备份后整体替换存储内容并重建调度计划；替换前会自动保存一份 pre-restore 备份。
+// 备份只包含 jobs.json（任务定义与运行状态），执行记录、修订历史、回收站与审计日志不在备份范围内，恢复时保持不变，
+// 恢复后的 restore 修订由 API 层追加；
+// 存储因损坏处于只读模式时同样可以恢复，恢复成功后解除只读
+func (m *Manager) Restore(ctx context.Context, id string) (*Manifest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	manifest, jobStore, err := m.load(id)
+	if err != nil {
+		backupFailures.Inc()
+		return nil, err
+	}
+
+	current, err := m.createLocked(ctx, TriggerPreRestore)
+	if err != nil {
+		backupFailures.Inc()
+		return nil, fmt.Errorf("failed to back up current state before restore: %w", err)
+	}
+
+	// 恢复后的定义统一取新的资源版本号，避免旧 ETag 与恢复后的内容意外匹配
+	next := max(current.ResourceVersion, jobStore.ResourceVersion) + 1
+	jobStore.ResourceVersion = next
+	for i := range jobStore.Jobs {
+		jobStore.Jobs[i].ResourceVersion = next
+	}
+
+	if err := m.store.Save(ctx, jobStore); err != nil {
+		backupFailures.Inc()
+		return nil, fmt.Errorf("failed to replace store: %w", err)
+	}
+
+	if err := m.reloader.Reload(); err != nil {
+		return nil, fmt.Errorf("restored store but failed to reload scheduler: %w", err)
+	}
+
+	m.logger.Info("Restored backup", "backup_id", manifest.ID, "jobs", manifest.Jobs)
+	return manifest, nil
+}
+
+func (m *Manager) createLocked(ctx context.Context, trigger string) (*Manifest, error) {
+	data, err := m.store.Export(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export store: %w", err)
+	}
+
+	var jobStore model.JobStore
+	if err := json.Unmarshal(data, &jobStore); err != nil {
+		return nil, fmt.Errorf("failed to inspect exported store: %w", err)
+	}
+
+	now := time.Now().UTC()
+	manifest := &Manifest{
+		ID:           now.Format("20060102T150405Z") + "-" + randomSuffix(),
+		CreatedAt:    now,
+		Trigger:      trigger,
+		StoreVersion: jobStore.Version,
+		Jobs:         len(jobStore.Jobs),
+		Files:        []File{fileEntry(jobsFile, data)},
+
+		ResourceVersion: jobStore.ResourceVersion,
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	// 先写入临时目录再整体改名，列表中不会出现写了一半的备份
+	tmpDir := filepath.Join(m.dir, ".tmp-"+manifest.ID)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if err := writeSynced(filepath.Join(tmpDir, jobsFile), data); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	if err := writeSynced(filepath.Join(tmpDir, manifestFile), manifestData); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	if err := os.Rename(tmpDir, filepath.Join(m.dir, manifest.ID)); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, fmt.Errorf("failed to finalize backup: %w", err)
+	}
+
+	backupsCreated.Inc()
+	m.logger.Info("Created backup", "backup_id", manifest.ID, "trigger", trigger, "jobs", manifest.Jobs)
+	return manifest, nil
+}
+
+func (m *Manager) load(id string) (*Manifest, *model.JobStore, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return nil, nil, fmt.Errorf("%w: %q", ErrNotFound, id)
+	}
+
+	manifest, err := m.readManifest(id)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	var data []byte
+	for _, file := range manifest.Files {
+		content, err := os.ReadFile(filepath.Join(m.dir, id, file.Name))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		if actual := fileEntry(file.Name, content); actual != file {
+			return nil, nil, fmt.Errorf("%w: checksum mismatch for %s", ErrInvalid, file.Name)
+		}
+		if file.Name == jobsFile {
+			data = content
+		}
+	}
+	if data == nil {
+		return nil, nil, fmt.Errorf("%w: manifest does not list %s", ErrInvalid, jobsFile)
+	}
+
+	jobStore, err := store.DecodeJobStore(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	seen := make(map[string]bool, len(jobStore.Jobs))
+	for i := range jobStore.Jobs {
+		def := &jobStore.Jobs[i]
+		if def.ID == "" || seen[def.ID] {
+			return nil, nil, fmt.Errorf("%w: missing or duplicate job id %q", ErrInvalid, def.ID)
+		}
+		seen[def.ID] = true
+
+		if err := def.ValidateStructure(); err != nil {
+			return nil, nil, fmt.Errorf("%w: job %s: %v", ErrInvalid, def.ID, err)
+		}
+	}
+
+	return manifest, jobStore, nil
+}
+
+func (m *Manager) readManifest(id string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(m.dir, id, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func (m *Manager) scheduleLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := m.Create(context.Background(), TriggerScheduled); err != nil {
+			m.logger.Error("Scheduled backup failed", "error", err)
+			continue
+		}
+		m.prune()
+	}
+}
+
+// prune 只清理自动备份，手动与恢复前备份需要人工删除
+func (m *Manager) prune() {
+	if m.retain <= 0 {
+		return
+	}
+
+	manifests, err := m.List()
+	if err != nil {
+		m.logger.Error("Failed to list backups for retention", "error", err)
+		return
+	}
+
+	kept := 0
+	for _, manifest := range manifests {
+		if manifest.Trigger != TriggerScheduled {
+			continue
+		}
+		kept++
+		if kept <= m.retain {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(m.dir, manifest.ID)); err != nil {
+			m.logger.Error("Failed to remove expired backup", "backup_id", manifest.ID, "error", err)
+			continue
+		}
+		m.logger.Info("Removed expired backup", "backup_id", manifest.ID)
+	}
+}
+
+func fileEntry(name string, data []byte) File {
+	sum := sha256.Sum256(data)
+	return File{
+		Name:   name,
+		Size:   int64(len(data)),
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+}
+
+func writeSynced(path string, data []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Base(path), err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return file.Sync()
+}
+
+func randomSuffix() string {
+	bytes := make([]byte, 3)
+	io.ReadFull(rand.Reader, bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,90 @@
+package diff
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+type Change struct {
+	Path   string      `json:"path"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Compute 比较两个值的 JSON 表示，按 a.b[0].c 形式的路径列出发生变化的叶子字段
+func Compute(before, after interface{}) ([]Change, error) {
+	beforeFields, err := flatten(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := flatten(after)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(beforeFields)+len(afterFields))
+	for path := range beforeFields {
+		paths = append(paths, path)
+	}
+	for path := range afterFields {
+		if _, exists := beforeFields[path]; !exists {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	changes := []Change{}
+	for _, path := range paths {
+		if !reflect.DeepEqual(beforeFields[path], afterFields[path]) {
+			changes = append(changes, Change{
+				Path:   path,
+				Before: beforeFields[path],
+				After:  afterFields[path],
+			})
+		}
+	}
+	return changes, nil
+}
+
+func flatten(value interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if value == nil {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+
+	walk("", generic, fields)
+	return fields, nil
+}
+
+func walk(prefix string, value interface{}, fields map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			walk(path, child, fields)
+		}
+	case []interface{}:
+		for i, child := range v {
+			walk(prefix+"["+strconv.Itoa(i)+"]", child, fields)
+		}
+	case nil:
+		// 缺失与 null 视为相同，不产生差异
+	default:
+		fields[prefix] = v
+	}
+}
@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"ksana-service/internal/model"
+	"time"
+)
+
+const defaultCompletionDeadline = time.Hour
+
+var (
+	ErrInvalidRunToken   = errors.New("invalid run token")
+	ErrRunNotAwaiting    = errors.New("run is not awaiting completion")
+	ErrInvalidCompletion = errors.New("completion status must be 'success' or 'failed'")
+)
+
+type completionResult struct {
+	status string
+	output string
+	errMsg string
+}
+
+func (e *HTTPExecutor) completionDeadline(job *model.Job) time.Duration {
+	if deadline := job.Completion.Deadline.ToDuration(); deadline > 0 {
+		return deadline
+	}
+	return defaultCompletionDeadline
+}
+
+func (e *HTTPExecutor) callbackURL(runID string) string {
+	if e.callbackBaseURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/runs/%s/complete", e.callbackBaseURL, runID)
+}
+
+func (e *HTTPExecutor) awaitCompletion(ctx context.Context, job *model.Job, runID string) (completionResult, error) {
+	deadline := time.Now().UTC().Add(e.completionDeadline(job))
+	completion := e.markAwaiting(runID, deadline)
+
+	e.logger.Info("Awaiting job completion callback",
+		"job_id", job.ID,
+		"run_id", runID,
+		"deadline", deadline)
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case result := <-completion:
+		return result, nil
+	case <-timer.C:
+		return completionResult{}, fmt.Errorf("%w: no completion callback within %s", errCompletionTimeout, e.completionDeadline(job))
+	case <-ctx.Done():
+		return completionResult{}, ctx.Err()
+	}
+}
+
+func (e *HTTPExecutor) markAwaiting(runID string, deadline time.Time) <-chan completionResult {
+	e.runsMu.Lock()
+	defer e.runsMu.Unlock()
+
+	active, exists := e.runs[runID]
+	if !exists {
+		return nil
+	}
+
+	active.run.CompletionDeadline = &deadline
+	active.completion = make(chan completionResult, 1)
+	return active.completion
+}
+
+func (e *HTTPExecutor) CompleteRun(runID, token, status, output, errorMsg string) error {
+	if status != model.JobStatusSuccess && status != model.JobStatusFailed {
+		return ErrInvalidCompletion
+	}
+
+	e.runsMu.Lock()
+	defer e.runsMu.Unlock()
+
+	active, exists := e.runs[runID]
+	if !exists {
+		for _, run := range e.history {
+			if run.ID == runID {
+				return ErrRunFinished
+			}
+		}
+		return ErrRunNotFound
+	}
+
+	if active.token == "" || subtle.ConstantTimeCompare([]byte(active.token), []byte(token)) != 1 {
+		return ErrInvalidRunToken
+	}
+
+	if active.completion == nil {
+		return ErrRunNotAwaiting
+	}
+
+	// 回调上报的输出与 HTTP 响应输出使用同样的长度上限
+	result := completionResult{
+		status: status,
+		output: truncateOutput([]byte(output)),
+		errMsg: truncateOutput([]byte(errorMsg)),
+	}
+
+	select {
+	case active.completion <- result:
+		return nil
+	default:
+		return ErrRunFinished
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"ksana-service/internal/model"
@@ -15,15 +16,38 @@ import (
 	"time"
 )
 
+const maxResponseBody = 1 << 20
+
+// SecretResolver 在执行时解析密文引用，并对写入执行记录的文本做脱敏；
+// Resolve 只返回允许任务所属命名空间使用的密文
+type SecretResolver interface {
+	Resolve(namespace, name string) (string, error)
+	Redact(text string) string
+}
+
+// ConcurrencyLimits 返回命名空间允许的最大并发执行数，0 表示不限制
+type ConcurrencyLimits interface {
+	MaxConcurrentRuns(namespace string) int
+}
+
 type HTTPExecutor struct {
-	client     *http.Client
-	store      store.Store
-	workerPool chan struct{}
-	wg         sync.WaitGroup
-	logger     *slog.Logger
+	client          *http.Client
+	store           store.Store
+	secrets         SecretResolver
+	limits          ConcurrencyLimits
+	workerPool      chan struct{}
+	wg              sync.WaitGroup
+	logger          *slog.Logger
+	callbackBaseURL string
+	runsMu          sync.RWMutex
+	runs            map[string]*activeRun
+	history         []model.Run
+	runLog          *runLog
+	waiters         map[string][]chan model.Run
 }
 
-func NewHTTPExecutor(workers int, timeout time.Duration, store store.Store, logger *slog.Logger) *HTTPExecutor {
+// NewHTTPExecutor 中 dataDir 为空时执行记录只保存在内存中
+func NewHTTPExecutor(workers int, timeout time.Duration, callbackBaseURL, dataDir string, store store.Store, secrets SecretResolver, limits ConcurrencyLimits, logger *slog.Logger) *HTTPExecutor {
 	return &HTTPExecutor{
 		client: &http.Client{
 			Timeout: timeout,
@@ -34,22 +58,67 @@ func NewHTTPExecutor(workers int, timeout time.Duration, store store.Store, logg
 				ExpectContinueTimeout: 1 * time.Second,
 			},
 		},
-		store:      store,
-		workerPool: make(chan struct{}, workers),
-		logger:     logger,
+		store:           store,
+		secrets:         secrets,
+		limits:          limits,
+		workerPool:      make(chan struct{}, workers),
+		logger:          logger,
+		callbackBaseURL: strings.TrimSuffix(callbackBaseURL, "/"),
+		runs:            make(map[string]*activeRun),
+		runLog:          newRunLog(dataDir),
+		waiters:         make(map[string][]chan model.Run),
 	}
 }
 
-func (e *HTTPExecutor) Execute(ctx context.Context, job *model.Job) error {
-	e.workerPool <- struct{}{}
-	defer func() { <-e.workerPool }()
-
+func (e *HTTPExecutor) Execute(ctx context.Context, job *model.Job, runReq *model.RunRequest) error {
 	e.wg.Add(1)
 	defer e.wg.Done()
 
-	runID := e.generateRunID()
+	if runReq == nil {
+		runReq = &model.RunRequest{Trigger: model.RunTriggerSchedule}
+	}
+	runID := runReq.RunID
+	if runID == "" {
+		runID = model.NewRunID()
+	}
 	startTime := time.Now().UTC()
 
+	var token string
+	if job.Completion.Mode == model.CompletionModeCallback {
+		token = e.generateToken()
+	}
+
+	runCtx, cancelRun := context.WithCancelCause(ctx)
+	defer cancelRun(nil)
+	if err := e.startRun(runID, job, runReq.Trigger, token, cancelRun); err != nil {
+		e.updateJobStatus(job, model.JobStatusSkipped, err.Error(), startTime)
+		e.finishRun(runID, model.JobStatusSkipped, err.Error(), "")
+		e.logger.Warn("Job run skipped", "job_id", job.ID, "run_id", runID, "error", err)
+		return err
+	}
+
+	httpCfg, err := e.buildRequestConfig(job, runID, runReq.Overrides)
+	if err != nil {
+		e.updateJobStatus(job, model.JobStatusFailed, err.Error(), startTime)
+		e.finishRun(runID, model.JobStatusFailed, err.Error(), "")
+		e.logger.Error("Failed to prepare job request", "job_id", job.ID, "run_id", runID, "error", err)
+		return err
+	}
+
+	select {
+	case e.workerPool <- struct{}{}:
+	case <-runCtx.Done():
+		return e.abortRun(runCtx, job, runID, startTime)
+	}
+	released := false
+	releaseWorker := func() {
+		if !released {
+			released = true
+			<-e.workerPool
+		}
+	}
+	defer releaseWorker()
+
 	e.logger.Info("Starting job execution",
 		"job_id", job.ID,
 		"job_name", job.Name,
@@ -65,19 +134,41 @@ func (e *HTTPExecutor) Execute(ctx context.Context, job *model.Job) error {
 				"backoff", backoff)
 
 			select {
-			case <-ctx.Done():
-				return ctx.Err()
+			case <-runCtx.Done():
+				return e.abortRun(runCtx, job, runID, startTime)
 			case <-time.After(backoff):
 			}
 		}
 
-		execCtx, cancel := context.WithTimeout(ctx, job.Timeout.ToDuration())
+		e.setRunAttempts(runID, attempt+1)
+		execCtx, cancel := context.WithTimeout(runCtx, job.Timeout.ToDuration())
 
-		err := e.executeHTTPRequest(execCtx, job, runID, startTime)
+		resp, err := e.executeHTTPRequest(execCtx, &httpCfg, runID, token, startTime)
 		cancel()
 
+		if err == nil && job.Completion.Mode == model.CompletionModeCallback {
+			releaseWorker()
+			if runReq.Accepted != nil {
+				runReq.Accepted()
+			}
+			return e.finishAsync(runCtx, job, runID, startTime, func() (completionResult, error) {
+				return e.awaitCompletion(runCtx, job, runID)
+			})
+		}
+
+		if err == nil && job.Completion.Mode == model.CompletionModePoll {
+			releaseWorker()
+			if runReq.Accepted != nil {
+				runReq.Accepted()
+			}
+			return e.finishAsync(runCtx, job, runID, startTime, func() (completionResult, error) {
+				return e.pollCompletion(runCtx, job, &httpCfg, runID, resp)
+			})
+		}
+
 		if err == nil {
 			e.updateJobStatus(job, model.JobStatusSuccess, "", startTime)
+			e.finishRun(runID, model.JobStatusSuccess, "", "")
 			e.logger.Info("Job executed successfully",
 				"job_id", job.ID,
 				"run_id", runID,
@@ -85,6 +176,10 @@ func (e *HTTPExecutor) Execute(ctx context.Context, job *model.Job) error {
 			return nil
 		}
 
+		if runCtx.Err() != nil {
+			return e.abortRun(runCtx, job, runID, startTime)
+		}
+
 		lastErr = err
 
 		if !e.isRetryableError(err) {
@@ -98,6 +193,7 @@ func (e *HTTPExecutor) Execute(ctx context.Context, job *model.Job) error {
 	}
 
 	e.updateJobStatus(job, status, lastErr.Error(), startTime)
+	e.finishRun(runID, status, lastErr.Error(), "")
 	e.logger.Error("Job execution failed",
 		"job_id", job.ID,
 		"run_id", runID,
@@ -107,47 +203,136 @@ func (e *HTTPExecutor) Execute(ctx context.Context, job *model.Job) error {
 	return lastErr
 }
 
-func (e *HTTPExecutor) executeHTTPRequest(ctx context.Context, job *model.Job, runID string, triggeredAt time.Time) error {
-	req, err := http.NewRequestWithContext(ctx, job.HTTP.Method, job.HTTP.URL, strings.NewReader(job.HTTP.Body))
+func (e *HTTPExecutor) abortRun(ctx context.Context, job *model.Job, runID string, startTime time.Time) error {
+	if !e.isCancelled(ctx) {
+		// 服务停止等原因中断，没有取消原因，按失败记录
+		e.updateJobStatus(job, model.JobStatusFailed, ctx.Err().Error(), startTime)
+		e.finishRun(runID, model.JobStatusFailed, ctx.Err().Error(), "")
+		e.logger.Warn("Job execution interrupted",
+			"job_id", job.ID,
+			"run_id", runID,
+			"error", ctx.Err(),
+			"latency_ms", time.Since(startTime).Milliseconds())
+		return ctx.Err()
+	}
+
+	e.updateJobStatus(job, model.JobStatusCancelled, errRunCancelled.Error(), startTime)
+	e.finishRun(runID, model.JobStatusCancelled, errRunCancelled.Error(), "")
+	e.logger.Info("Job execution cancelled",
+		"job_id", job.ID,
+		"run_id", runID,
+		"latency_ms", time.Since(startTime).Milliseconds())
+
+	return errRunCancelled
+}
+
+func (e *HTTPExecutor) finishAsync(ctx context.Context, job *model.Job, runID string, startTime time.Time, wait func() (completionResult, error)) error {
+	result, err := wait()
+	if err != nil {
+		if ctx.Err() != nil {
+			return e.abortRun(ctx, job, runID, startTime)
+		}
+
+		status := model.JobStatusFailed
+		if errors.Is(err, errCompletionTimeout) {
+			status = model.JobStatusTimeout
+		}
+
+		e.updateJobStatus(job, status, err.Error(), startTime)
+		e.finishRun(runID, status, err.Error(), "")
+		e.logger.Error("Job completion failed",
+			"job_id", job.ID,
+			"run_id", runID,
+			"status", status,
+			"error", err,
+			"latency_ms", time.Since(startTime).Milliseconds())
+		return err
+	}
+
+	e.updateJobStatus(job, result.status, result.errMsg, startTime)
+	e.finishRun(runID, result.status, result.errMsg, result.output)
+	e.logger.Info("Job completed asynchronously",
+		"job_id", job.ID,
+		"run_id", runID,
+		"status", result.status,
+		"latency_ms", time.Since(startTime).Milliseconds())
+
+	if result.status != model.JobStatusSuccess {
+		return fmt.Errorf("job reported failure: %s", result.errMsg)
+	}
+	return nil
+}
+
+func (e *HTTPExecutor) executeHTTPRequest(ctx context.Context, httpCfg *model.HTTPConfig, runID, token string, triggeredAt time.Time) (*httpResult, error) {
+	req, err := http.NewRequestWithContext(ctx, httpCfg.Method, httpCfg.URL, strings.NewReader(httpCfg.Body))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	for key, value := range job.HTTP.Headers {
+	for key, value := range httpCfg.Headers {
 		req.Header.Set(key, value)
 	}
 
 	req.Header.Set("X-Ksana-Run-Id", runID)
+	if token != "" {
+		req.Header.Set("X-Ksana-Run-Token", token)
+		if callbackURL := e.callbackURL(runID); callbackURL != "" {
+			req.Header.Set("X-Ksana-Callback-Url", callbackURL)
+		}
+	}
 
-	if job.HTTP.Method == "POST" && req.Header.Get("Content-Type") == "" {
-		if job.HTTP.Body != "" && (strings.HasPrefix(strings.TrimSpace(job.HTTP.Body), "{") || strings.HasPrefix(strings.TrimSpace(job.HTTP.Body), "[")) {
+	if httpCfg.Method == "POST" && req.Header.Get("Content-Type") == "" {
+		if httpCfg.Body != "" && (strings.HasPrefix(strings.TrimSpace(httpCfg.Body), "{") || strings.HasPrefix(strings.TrimSpace(httpCfg.Body), "[")) {
 			req.Header.Set("Content-Type", "application/json")
 		} else {
 			req.Header.Set("Content-Type", "text/plain")
 		}
 	}
 
-	resp, err := e.client.Do(req)
+	result, err := e.doRequest(req)
 	if err != nil {
-		return fmt.Errorf("HTTP request failed: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	io.ReadAll(resp.Body)
+	if result.statusCode >= 200 && result.statusCode < 300 {
+		return result, nil
+	}
 
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return nil
+	return nil, fmt.Errorf("HTTP request failed with status %d: %s", result.statusCode, result.status)
+}
+
+type httpResult struct {
+	statusCode int
+	status     string
+	header     http.Header
+	body       []byte
+}
+
+func (e *HTTPExecutor) doRequest(req *http.Request) (*httpResult, error) {
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
+	defer resp.Body.Close()
 
-	return fmt.Errorf("HTTP request failed with status %d: %s", resp.StatusCode, resp.Status)
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	io.Copy(io.Discard, resp.Body)
+
+	return &httpResult{
+		statusCode: resp.StatusCode,
+		status:     resp.Status,
+		header:     resp.Header,
+		body:       body,
+	}, nil
 }
 
 func (e *HTTPExecutor) updateJobStatus(job *model.Job, status, errorMsg string, runTime time.Time) {
-	job.LastRunAt = &runTime
-	job.LastStatus = status
-	job.LastError = errorMsg
-
-	if err := e.store.Put(context.Background(), job); err != nil {
+	_, err := e.store.UpdateState(context.Background(), job.ID, func(state *model.JobState) {
+		state.LastRunAt = &runTime
+		state.LastStatus = status
+		state.LastError = e.secrets.Redact(errorMsg)
+	})
+	if err != nil {
 		e.logger.Error("Failed to update job status", "job_id", job.ID, "error", err)
 	}
 }
@@ -183,7 +368,7 @@ func (e *HTTPExecutor) isTimeoutError(err error) bool {
 	return strings.Contains(errStr, "timeout") || strings.Contains(errStr, "context deadline exceeded")
 }
 
-func (e *HTTPExecutor) generateRunID() string {
+func (e *HTTPExecutor) generateToken() string {
 	bytes := make([]byte, 16)
 	io.ReadFull(rand.Reader, bytes)
 	return hex.EncodeToString(bytes)
@@ -203,4 +388,4 @@ func (e *HTTPExecutor) Shutdown(ctx context.Context) error {
 	case <-ctx.Done():
 		return ctx.Err()
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,268 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/model"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPollInterval = 10 * time.Second
+	defaultPollSuccess  = "done"
+	defaultPollFailure  = "failed"
+	maxRunOutput        = 4096
+
+	// maxRunPolls 为执行记录中保留的最近轮询次数
+	maxRunPolls = 100
+)
+
+var errCompletionTimeout = errors.New("completion deadline exceeded")
+
+func (e *HTTPExecutor) pollCompletion(ctx context.Context, job *model.Job, httpCfg *model.HTTPConfig, runID string, initial *httpResult) (completionResult, error) {
+	poll := job.Completion.Poll
+
+	statusURL, err := e.resolveStatusURL(job, httpCfg, initial)
+	if err != nil {
+		return completionResult{}, err
+	}
+
+	deadline := time.Now().UTC().Add(e.completionDeadline(job))
+	e.markPolling(runID, statusURL, deadline)
+
+	// 状态地址由对方返回，可能指向其它主机；只有与任务 URL 同源时才携带任务的请求头（含密文请求头）
+	forwardHeaders := sameOrigin(httpCfg.URL, statusURL)
+	if !forwardHeaders {
+		e.logger.Warn("Status URL has a different origin than the job URL, job headers are not forwarded",
+			"job_id", job.ID,
+			"run_id", runID)
+	}
+
+	interval := poll.Interval.ToDuration()
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	successValue := poll.SuccessValue
+	if successValue == "" {
+		successValue = defaultPollSuccess
+	}
+	failureValue := poll.FailureValue
+	if failureValue == "" {
+		failureValue = defaultPollFailure
+	}
+
+	e.logger.Info("Polling job status",
+		"job_id", job.ID,
+		"run_id", runID,
+		"status_url", statusURL,
+		"deadline", deadline)
+
+	deadlineTimer := time.NewTimer(time.Until(deadline))
+	defer deadlineTimer.Stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return completionResult{}, ctx.Err()
+		case <-deadlineTimer.C:
+			return completionResult{}, fmt.Errorf("%w: status polling did not finish within %s", errCompletionTimeout, e.completionDeadline(job))
+		case <-ticker.C:
+		}
+
+		record, body := e.pollOnce(ctx, job, httpCfg, forwardHeaders, runID, statusURL, poll.StatusPath)
+		e.recordPoll(runID, record)
+
+		switch record.Value {
+		case successValue:
+			return completionResult{status: model.JobStatusSuccess, output: truncateOutput(body)}, nil
+		case failureValue:
+			return completionResult{
+				status: model.JobStatusFailed,
+				output: truncateOutput(body),
+				errMsg: fmt.Sprintf("remote job reported %s", failureValue),
+			}, nil
+		}
+	}
+}
+
+func (e *HTTPExecutor) pollOnce(ctx context.Context, job *model.Job, httpCfg *model.HTTPConfig, forwardHeaders bool, runID, statusURL, statusPath string) (model.Poll, []byte) {
+	record := model.Poll{At: time.Now().UTC()}
+
+	reqCtx, cancel := context.WithTimeout(ctx, job.Timeout.ToDuration())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, statusURL, nil)
+	if err != nil {
+		record.Error = err.Error()
+		return record, nil
+	}
+
+	if forwardHeaders {
+		for key, value := range httpCfg.Headers {
+			req.Header.Set(key, value)
+		}
+	}
+	req.Header.Set("X-Ksana-Run-Id", runID)
+
+	result, err := e.doRequest(req)
+	if err != nil {
+		record.Error = err.Error()
+		return record, nil
+	}
+
+	record.StatusCode = result.statusCode
+	if result.statusCode < 200 || result.statusCode >= 300 {
+		record.Error = fmt.Sprintf("unexpected status %s", result.status)
+		return record, result.body
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(result.body, &doc); err != nil {
+		record.Error = fmt.Sprintf("invalid JSON response: %v", err)
+		return record, result.body
+	}
+
+	value, ok := lookupJSONPath(doc, statusPath)
+	if !ok {
+		record.Error = fmt.Sprintf("status path %q not found", statusPath)
+		return record, result.body
+	}
+
+	record.Value = jsonValueString(value)
+	return record, result.body
+}
+
+func (e *HTTPExecutor) resolveStatusURL(job *model.Job, httpCfg *model.HTTPConfig, initial *httpResult) (string, error) {
+	var raw string
+	if field := job.Completion.Poll.URLField; field != "" {
+		var doc interface{}
+		if err := json.Unmarshal(initial.body, &doc); err != nil {
+			return "", fmt.Errorf("failed to parse initial response for status URL: %w", err)
+		}
+		value, ok := lookupJSONPath(doc, field)
+		if !ok {
+			return "", fmt.Errorf("status URL field %q not found in initial response", field)
+		}
+		raw = jsonValueString(value)
+	} else {
+		raw = initial.header.Get("Location")
+	}
+
+	if raw == "" {
+		return "", errors.New("initial response did not provide a status URL")
+	}
+
+	base, err := url.Parse(httpCfg.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid job URL: %w", err)
+	}
+	ref, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid status URL %q: %w", raw, err)
+	}
+
+	return base.ResolveReference(ref).String(), nil
+}
+
+func (e *HTTPExecutor) markPolling(runID, statusURL string, deadline time.Time) {
+	e.runsMu.Lock()
+	defer e.runsMu.Unlock()
+
+	if active, exists := e.runs[runID]; exists {
+		active.run.StatusURL = e.secrets.Redact(statusURL)
+		active.run.CompletionDeadline = &deadline
+	}
+}
+
+func (e *HTTPExecutor) recordPoll(runID string, record model.Poll) {
+	e.runsMu.Lock()
+	defer e.runsMu.Unlock()
+
+	record.Value = e.secrets.Redact(record.Value)
+	record.Error = e.secrets.Redact(record.Error)
+	if active, exists := e.runs[runID]; exists {
+		active.run.Polls = append(active.run.Polls, record)
+		if len(active.run.Polls) > maxRunPolls {
+			active.run.Polls = active.run.Polls[len(active.run.Polls)-maxRunPolls:]
+		}
+	}
+}
+
+// sameOrigin 比较两个地址的协议、主机与端口（省略的端口按协议默认值）
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) &&
+		strings.EqualFold(ua.Hostname(), ub.Hostname()) &&
+		originPort(ua) == originPort(ub)
+}
+
+func originPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return "443"
+	case "http":
+		return "80"
+	}
+	return ""
+}
+
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool) {
+	current := doc
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, exists := node[segment]
+			if !exists {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func jsonValueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+func truncateOutput(body []byte) string {
+	if len(body) > maxRunOutput {
+		return string(body[:maxRunOutput])
+	}
+	return string(body)
+}
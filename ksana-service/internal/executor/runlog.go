@@ -0,0 +1,226 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"ksana-service/internal/model"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	runLogFile = "runs.jsonl"
+
+	// 日志行数超过保留条数的 runLogCompactFactor 倍时重写文件
+	runLogCompactFactor = 4
+
+	runInterruptedMessage = "interrupted by service restart"
+)
+
+// runLog 将执行记录追加到 DATA_DIR/runs.jsonl：开始时写一条 running，结束时写最终状态，
+// 同一 ID 以最后一条为准。重启时仍为 running 的执行无法继续（回调令牌与等待者只在内存中），
+// 统一记为 failed，迟到的完成回调返回 409 而不是 404
+type runLog struct {
+	path  string
+	mu    sync.Mutex
+	lines int
+}
+
+func newRunLog(dataDir string) *runLog {
+	if dataDir == "" {
+		return nil
+	}
+	return &runLog{path: filepath.Join(dataDir, runLogFile)}
+}
+
+func (l *runLog) append(run *model.Run) error {
+	line, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open run log: %w", err)
+	}
+	defer file.Close()
+
+	// 上次写入中断留下的半行需要先补换行，否则会与本条记录粘连
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		tail := make([]byte, 1)
+		if _, err := file.ReadAt(tail, info.Size()-1); err == nil && tail[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append run: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync run log: %w", err)
+	}
+
+	l.lines++
+	return nil
+}
+
+// load 返回按开始顺序排列的执行记录，无法解析的行被跳过
+func (l *runLog) load() ([]model.Run, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open run log: %w", err)
+	}
+	defer file.Close()
+
+	var runs []model.Run
+	index := make(map[string]int)
+	l.lines = 0
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		l.lines++
+
+		var run model.Run
+		if err := json.Unmarshal(line, &run); err != nil || run.ID == "" {
+			continue
+		}
+		if i, exists := index[run.ID]; exists {
+			runs[i] = run
+			continue
+		}
+		index[run.ID] = len(runs)
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read run log: %w", err)
+	}
+	return runs, nil
+}
+
+// rewrite 用给定记录替换日志内容，调用方需持有 mu
+func (l *runLog) rewrite(runs []model.Run) error {
+	var buf bytes.Buffer
+	for i := range runs {
+		line, err := json.Marshal(&runs[i])
+		if err != nil {
+			return fmt.Errorf("failed to marshal run: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	tmp := l.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write run log: %w", err)
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write run log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync run log: %w", err)
+	}
+	file.Close()
+
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to replace run log: %w", err)
+	}
+	l.lines = len(runs)
+	return nil
+}
+
+// LoadRuns 从执行日志恢复历史执行记录；上次退出时仍在进行的执行记为 failed
+func (e *HTTPExecutor) LoadRuns() error {
+	if e.runLog == nil {
+		return nil
+	}
+
+	runs, err := e.runLog.load()
+	if err != nil {
+		return err
+	}
+
+	interrupted := 0
+	now := time.Now().UTC()
+	for i := range runs {
+		if runs[i].Finished() {
+			continue
+		}
+		runs[i].State = model.JobStatusFailed
+		runs[i].Error = runInterruptedMessage
+		runs[i].FinishedAt = &now
+		runs[i].CompletionDeadline = nil
+		interrupted++
+	}
+	if len(runs) > runHistorySize {
+		runs = runs[len(runs)-runHistorySize:]
+	}
+
+	e.runsMu.Lock()
+	e.history = runs
+	e.runsMu.Unlock()
+
+	if interrupted > 0 {
+		e.logger.Warn("Runs interrupted by restart marked as failed", "runs", interrupted)
+	}
+
+	// 重写一次日志，中断的执行以最终状态落盘，同时丢弃超出保留条数的旧记录
+	e.runLog.mu.Lock()
+	defer e.runLog.mu.Unlock()
+	return e.runLog.rewrite(runs)
+}
+
+// persistRun 追加执行记录；写入失败只记录日志，不影响执行本身
+func (e *HTTPExecutor) persistRun(run *model.Run) {
+	if e.runLog == nil {
+		return
+	}
+	if err := e.runLog.append(run); err != nil {
+		e.logger.Error("Failed to persist run", "run_id", run.ID, "error", err)
+		return
+	}
+	e.compactRunLog()
+}
+
+// compactRunLog 在日志行数过多时用当前的历史与进行中的执行重写日志。
+// 持有 runLog.mu 期间读取内存状态，此前的追加都已反映在内存中，不会丢失
+func (e *HTTPExecutor) compactRunLog() {
+	e.runLog.mu.Lock()
+	defer e.runLog.mu.Unlock()
+
+	if e.runLog.lines <= runHistorySize*runLogCompactFactor {
+		return
+	}
+
+	e.runsMu.RLock()
+	runs := make([]model.Run, 0, len(e.history)+len(e.runs))
+	runs = append(runs, e.history...)
+	for _, active := range e.runs {
+		runs = append(runs, active.run)
+	}
+	e.runsMu.RUnlock()
+
+	if err := e.runLog.rewrite(runs); err != nil {
+		e.logger.Error("Failed to compact run log", "error", err)
+	}
+}
@@ -0,0 +1,234 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ksana-service/internal/model"
+	"sort"
+	"time"
+)
+
+const runHistorySize = 500
+
+var (
+	ErrRunNotFound = errors.New("run not found")
+	ErrRunFinished = errors.New("run already finished")
+
+	ErrConcurrencyLimit = errors.New("concurrent run limit reached")
+	errRunCancelled     = errors.New("run cancelled")
+)
+
+type activeRun struct {
+	run        model.Run
+	cancel     context.CancelCauseFunc
+	token      string
+	completion chan completionResult
+}
+
+// startRun 登记执行记录；命名空间的并发执行数已达上限时仍会登记，但返回错误，由调用方以 skipped 结束
+func (e *HTTPExecutor) startRun(runID string, job *model.Job, trigger, token string, cancel context.CancelCauseFunc) error {
+	e.runsMu.Lock()
+
+	var err error
+	if limit := e.limits.MaxConcurrentRuns(job.Namespace); limit > 0 {
+		running := 0
+		for _, active := range e.runs {
+			if active.run.Namespace == job.Namespace {
+				running++
+			}
+		}
+		if running >= limit {
+			err = fmt.Errorf("%w: namespace %s allows at most %d concurrent runs", ErrConcurrencyLimit, job.Namespace, limit)
+		}
+	}
+
+	active := &activeRun{
+		run: model.Run{
+			ID:        runID,
+			JobID:     job.ID,
+			Namespace: job.Namespace,
+			JobName:   job.Name,
+			State:     model.RunStateRunning,
+			Trigger:   trigger,
+			StartedAt: time.Now().UTC(),
+		},
+		cancel: cancel,
+		token:  token,
+	}
+	e.runs[runID] = active
+	run := active.run
+	e.runsMu.Unlock()
+
+	e.persistRun(&run)
+	return err
+}
+
+func (e *HTTPExecutor) setRunAttempts(runID string, attempts int) {
+	e.runsMu.Lock()
+	defer e.runsMu.Unlock()
+
+	if active, exists := e.runs[runID]; exists {
+		active.run.Attempts = attempts
+	}
+}
+
+func (e *HTTPExecutor) finishRun(runID, state, errorMsg, output string) {
+	e.runsMu.Lock()
+
+	active, exists := e.runs[runID]
+	if !exists {
+		e.runsMu.Unlock()
+		return
+	}
+	delete(e.runs, runID)
+
+	finishedAt := time.Now().UTC()
+	active.run.State = state
+	active.run.Error = e.secrets.Redact(errorMsg)
+	active.run.Output = e.secrets.Redact(output)
+	active.run.FinishedAt = &finishedAt
+
+	e.history = append(e.history, active.run)
+	if len(e.history) > runHistorySize {
+		e.history = e.history[len(e.history)-runHistorySize:]
+	}
+
+	for _, waiter := range e.waiters[runID] {
+		waiter <- active.run
+	}
+	delete(e.waiters, runID)
+	e.runsMu.Unlock()
+
+	e.persistRun(&active.run)
+}
+
+func (e *HTTPExecutor) WaitRun(ctx context.Context, runID string) (*model.Run, error) {
+	e.runsMu.Lock()
+	for i := len(e.history) - 1; i >= 0; i-- {
+		if e.history[i].ID == runID {
+			run := e.history[i]
+			e.runsMu.Unlock()
+			return &run, nil
+		}
+	}
+
+	waiter := make(chan model.Run, 1)
+	e.waiters[runID] = append(e.waiters[runID], waiter)
+	e.runsMu.Unlock()
+
+	select {
+	case run := <-waiter:
+		return &run, nil
+	case <-ctx.Done():
+	}
+
+	e.runsMu.Lock()
+	waiters := e.waiters[runID]
+	for i, w := range waiters {
+		if w == waiter {
+			e.waiters[runID] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(e.waiters[runID]) == 0 {
+		delete(e.waiters, runID)
+	}
+	e.runsMu.Unlock()
+
+	select {
+	case run := <-waiter:
+		return &run, nil
+	default:
+	}
+
+	run, err := e.GetRun(runID)
+	if err != nil {
+		return nil, err
+	}
+	return run, ctx.Err()
+}
+
+func (e *HTTPExecutor) ListRuns(state string) []model.Run {
+	e.runsMu.RLock()
+	defer e.runsMu.RUnlock()
+
+	var runs []model.Run
+	if state == "" || state == model.RunStateRunning {
+		for _, active := range e.runs {
+			runs = append(runs, active.run)
+		}
+	}
+
+	if state != model.RunStateRunning {
+		for _, run := range e.history {
+			if state == "" || run.State == state {
+				runs = append(runs, run)
+			}
+		}
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+
+	return runs
+}
+
+func (e *HTTPExecutor) GetRun(runID string) (*model.Run, error) {
+	e.runsMu.RLock()
+	defer e.runsMu.RUnlock()
+
+	if active, exists := e.runs[runID]; exists {
+		run := active.run
+		return &run, nil
+	}
+
+	for i := len(e.history) - 1; i >= 0; i-- {
+		if e.history[i].ID == runID {
+			run := e.history[i]
+			return &run, nil
+		}
+	}
+
+	return nil, ErrRunNotFound
+}
+
+func (e *HTTPExecutor) CancelRun(runID string) error {
+	e.runsMu.RLock()
+	active, exists := e.runs[runID]
+	e.runsMu.RUnlock()
+
+	if !exists {
+		if _, err := e.GetRun(runID); err == nil {
+			return ErrRunFinished
+		}
+		return ErrRunNotFound
+	}
+
+	e.logger.Info("Cancelling job run", "job_id", active.run.JobID, "run_id", runID)
+	active.cancel(errRunCancelled)
+	return nil
+}
+
+func (e *HTTPExecutor) CancelJobRuns(jobID string) int {
+	e.runsMu.RLock()
+	var targets []*activeRun
+	for _, active := range e.runs {
+		if active.run.JobID == jobID {
+			targets = append(targets, active)
+		}
+	}
+	e.runsMu.RUnlock()
+
+	for _, active := range targets {
+		e.logger.Info("Cancelling job run", "job_id", jobID, "run_id", active.run.ID)
+		active.cancel(errRunCancelled)
+	}
+
+	return len(targets)
+}
+
+func (e *HTTPExecutor) isCancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errRunCancelled)
+}
@@ -0,0 +1,125 @@
+package executor
+
+import (
+	"fmt"
+	"ksana-service/internal/model"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type templateJob struct {
+	ID   string
+	Name string
+}
+
+type templateContext struct {
+	Job     templateJob
+	RunID   string
+	Now     time.Time
+	Vars    map[string]string
+	Payload interface{}
+}
+
+func (e *HTTPExecutor) buildRequestConfig(job *model.Job, runID string, overrides *model.RunOverrides) (model.HTTPConfig, error) {
+	cfg := model.HTTPConfig{
+		Method:  job.HTTP.Method,
+		URL:     job.HTTP.URL,
+		Body:    job.HTTP.Body,
+		Headers: make(map[string]string, len(job.HTTP.Headers)),
+	}
+	for key, value := range job.HTTP.Headers {
+		cfg.Headers[key] = value
+	}
+
+	tctx := templateContext{
+		Job:   templateJob{ID: job.ID, Name: job.Name},
+		RunID: runID,
+		Now:   time.Now().UTC(),
+		Vars:  map[string]string{},
+	}
+
+	if overrides != nil {
+		for key, value := range overrides.Vars {
+			tctx.Vars[key] = value
+		}
+		tctx.Payload = overrides.Payload
+	}
+
+	// 只渲染任务定义中的模板，且需要任务显式开启 http.template；
+	// 单次执行覆盖的请求体与请求头由调用方提供，按原样使用，不能借此调用 secret 等模板函数
+	if job.HTTP.Template {
+		funcs := template.FuncMap{"secret": func(name string) (string, error) {
+			return e.secrets.Resolve(job.Namespace, name)
+		}}
+
+		var err error
+		if cfg.URL, err = renderTemplate("url", cfg.URL, tctx, funcs); err != nil {
+			return cfg, err
+		}
+		if cfg.Body, err = renderTemplate("body", cfg.Body, tctx, funcs); err != nil {
+			return cfg, err
+		}
+		for key, value := range cfg.Headers {
+			if cfg.Headers[key], err = renderTemplate("header "+key, value, tctx, funcs); err != nil {
+				return cfg, err
+			}
+		}
+	}
+
+	if overrides != nil {
+		if overrides.Body != nil {
+			cfg.Body = *overrides.Body
+		}
+		for key, value := range overrides.Headers {
+			cfg.Headers[key] = value
+		}
+	}
+
+	// 密文请求头在模板渲染之后填充，避免密文内容被当作模板解析；单次执行覆盖的同名请求头优先
+	for header, name := range job.HTTP.SecretHeaders {
+		if overrides != nil {
+			if _, overridden := overrides.Headers[header]; overridden {
+				continue
+			}
+		}
+		value, err := e.secrets.Resolve(job.Namespace, name)
+		if err != nil {
+			return cfg, fmt.Errorf("secret header %s: %w", header, err)
+		}
+		cfg.Headers[header] = value
+	}
+
+	if overrides != nil && len(overrides.Query) > 0 {
+		parsed, err := url.Parse(cfg.URL)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid HTTP URL: %w", err)
+		}
+		query := parsed.Query()
+		for key, value := range overrides.Query {
+			query.Set(key, value)
+		}
+		parsed.RawQuery = query.Encode()
+		cfg.URL = parsed.String()
+	}
+
+	return cfg, nil
+}
+
+func renderTemplate(name, text string, tctx templateContext, funcs template.FuncMap) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=zero").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, tctx); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+	return out.String(), nil
+}
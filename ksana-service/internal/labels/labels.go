@@ -0,0 +1,71 @@
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	maxLabels      = 64
+	maxNameLength  = 63
+	maxPrefixLen   = 253
+	maxValueLength = 63
+)
+
+var (
+	namePattern   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_.-]*[A-Za-z0-9])?$`)
+	prefixPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+)
+
+// ValidateKey 按 Kubernetes 规则校验标签键：可选的 DNS 子域名前缀加 '/'，名称最长 63 个字符，
+// 以字母或数字开头和结尾，中间可包含 '-'、'_'、'.'
+func ValidateKey(key string) error {
+	name := key
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		prefix := key[:i]
+		name = key[i+1:]
+		if prefix == "" || len(prefix) > maxPrefixLen || !prefixPattern.MatchString(prefix) {
+			return fmt.Errorf("label key %q: prefix must be a lowercase DNS subdomain of at most %d characters", key, maxPrefixLen)
+		}
+	}
+
+	if name == "" || len(name) > maxNameLength || !namePattern.MatchString(name) {
+		return fmt.Errorf("label key %q: name must be 1-%d alphanumeric characters, '-', '_' or '.', starting and ending with an alphanumeric character", key, maxNameLength)
+	}
+	return nil
+}
+
+// ValidateValue 校验标签值：可以为空，否则规则与键名相同
+func ValidateValue(value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) > maxValueLength || !namePattern.MatchString(value) {
+		return fmt.Errorf("label value %q must be at most %d alphanumeric characters, '-', '_' or '.', starting and ending with an alphanumeric character", value, maxValueLength)
+	}
+	return nil
+}
+
+func Validate(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("at most %d labels are allowed", maxLabels)
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := ValidateKey(key); err != nil {
+			return err
+		}
+		if err := ValidateValue(labels[key]); err != nil {
+			return fmt.Errorf("label %q: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	OpEquals       = "="
+	OpNotEquals    = "!="
+	OpIn           = "in"
+	OpNotIn        = "notin"
+	OpExists       = "exists"
+	OpDoesNotExist = "!"
+)
+
+var setPattern = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+type Requirement struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+// Selector 为多个条件的与关系，空选择器匹配所有任务
+type Selector []Requirement
+
+// Parse 解析 Kubernetes 风格的标签选择器，例如 `team=billing,env!=dev,tier in (a,b),!legacy`
+func Parse(text string) (Selector, error) {
+	var selector Selector
+
+	for _, part := range splitTopLevel(text) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		req, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, req)
+	}
+
+	return selector, nil
+}
+
+func parseRequirement(part string) (Requirement, error) {
+	var req Requirement
+
+	switch {
+	case strings.HasPrefix(part, "!"):
+		req = Requirement{Key: strings.TrimSpace(part[1:]), Operator: OpDoesNotExist}
+
+	case setPattern.MatchString(part):
+		match := setPattern.FindStringSubmatch(part)
+		req = Requirement{Key: match[1], Operator: match[2]}
+		for _, value := range strings.Split(match[3], ",") {
+			value = strings.TrimSpace(value)
+			if err := ValidateValue(value); err != nil {
+				return req, fmt.Errorf("selector %q: %w", part, err)
+			}
+			req.Values = append(req.Values, value)
+		}
+		sort.Strings(req.Values)
+
+	case strings.Contains(part, "!="):
+		key, value, _ := strings.Cut(part, "!=")
+		req = Requirement{Key: strings.TrimSpace(key), Operator: OpNotEquals, Values: []string{strings.TrimSpace(value)}}
+
+	case strings.Contains(part, "="):
+		key, value, _ := strings.Cut(part, "=")
+		value = strings.TrimPrefix(value, "=")
+		req = Requirement{Key: strings.TrimSpace(key), Operator: OpEquals, Values: []string{strings.TrimSpace(value)}}
+
+	default:
+		req = Requirement{Key: part, Operator: OpExists}
+	}
+
+	if err := ValidateKey(req.Key); err != nil {
+		return req, fmt.Errorf("selector %q: %w", part, err)
+	}
+	if req.Operator == OpEquals || req.Operator == OpNotEquals {
+		if err := ValidateValue(req.Values[0]); err != nil {
+			return req, fmt.Errorf("selector %q: %w", part, err)
+		}
+	}
+	return req, nil
+}
+
+// splitTopLevel 按逗号切分条件，括号内的逗号属于集合取值
+func splitTopLevel(text string) []string {
+	var parts []string
+	depth, start := 0, 0
+
+	for i, ch := range text {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, text[start:])
+}
+
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches 与 Kubernetes 语义一致：!= 与 notin 在标签不存在时也视为匹配
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, exists := labels[r.Key]
+
+	switch r.Operator {
+	case OpEquals:
+		return exists && value == r.Values[0]
+	case OpNotEquals:
+		return !exists || value != r.Values[0]
+	case OpIn:
+		return exists && contains(r.Values, value)
+	case OpNotIn:
+		return !exists || !contains(r.Values, value)
+	case OpExists:
+		return exists
+	case OpDoesNotExist:
+		return !exists
+	}
+	return false
+}
+
+// Indexable 表示该条件可以直接通过标签索引得到候选集合
+func (r Requirement) Indexable() bool {
+	switch r.Operator {
+	case OpEquals, OpIn, OpExists:
+		return true
+	}
+	return false
+}
+
+func (s Selector) String() string {
+	parts := make([]string, 0, len(s))
+	for _, req := range s {
+		switch req.Operator {
+		case OpEquals, OpNotEquals:
+			parts = append(parts, req.Key+req.Operator+req.Values[0])
+		case OpIn, OpNotIn:
+			parts = append(parts, fmt.Sprintf("%s %s (%s)", req.Key, req.Operator, strings.Join(req.Values, ",")))
+		case OpExists:
+			parts = append(parts, req.Key)
+		case OpDoesNotExist:
+			parts = append(parts, "!"+req.Key)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package labels
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   Selector
+		string string
+	}{
+		{name: "empty", text: " , ", want: nil, string: ""},
+		{name: "equals", text: "team=billing", want: Selector{{Key: "team", Operator: OpEquals, Values: []string{"billing"}}}, string: "team=billing"},
+		{name: "double equals", text: "team==billing", want: Selector{{Key: "team", Operator: OpEquals, Values: []string{"billing"}}}, string: "team=billing"},
+		{name: "empty value", text: "team=", want: Selector{{Key: "team", Operator: OpEquals, Values: []string{""}}}, string: "team="},
+		{name: "not equals", text: " env != dev ", want: Selector{{Key: "env", Operator: OpNotEquals, Values: []string{"dev"}}}, string: "env!=dev"},
+		{
+			name:   "set values are sorted",
+			text:   "tier in (web, api),zone notin(b,a)",
+			want:   Selector{{Key: "tier", Operator: OpIn, Values: []string{"api", "web"}}, {Key: "zone", Operator: OpNotIn, Values: []string{"a", "b"}}},
+			string: "tier in (api,web),zone notin (a,b)",
+		},
+		{
+			name:   "exists and does not exist",
+			text:   "example.com/owner,! legacy",
+			want:   Selector{{Key: "example.com/owner", Operator: OpExists}, {Key: "legacy", Operator: OpDoesNotExist}},
+			string: "example.com/owner,!legacy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.text)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.text, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+			if s := got.String(); s != tt.string {
+				t.Errorf("String() = %q, want %q", s, tt.string)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, text := range []string{
+		"-team=billing",
+		"team=bad value",
+		"=billing",
+		"tier in (a,-b)",
+		"!",
+		"/name",
+		"example.com/",
+		"team,env.=dev",
+	} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", text)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"team": "billing", "env": "prod", "tier": "api"}
+
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{selector: "", want: true},
+		{selector: "team=billing", want: true},
+		{selector: "team=ops", want: false},
+		{selector: "env!=dev", want: true},
+		{selector: "owner!=alice", want: true},
+		{selector: "env!=prod", want: false},
+		{selector: "tier in (api,web)", want: true},
+		{selector: "owner in (alice)", want: false},
+		{selector: "tier notin (web)", want: true},
+		{selector: "owner notin (alice)", want: true},
+		{selector: "tier notin (api)", want: false},
+		{selector: "team", want: true},
+		{selector: "owner", want: false},
+		{selector: "!owner", want: true},
+		{selector: "!team", want: false},
+		{selector: "team=billing,env=prod,!legacy", want: true},
+		{selector: "team=billing,env=dev", want: false},
+	}
+
+	for _, tt := range tests {
+		selector, err := Parse(tt.selector)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.selector, err)
+		}
+		if got := selector.Matches(labels); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/diff"
+	"ksana-service/internal/model"
+	"ksana-service/internal/namespace"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+const (
+	OpCreate    = "create"
+	OpUpdate    = "update"
+	OpDelete    = "delete"
+	OpUnchanged = "unchanged"
+
+	DefaultOwner = "manifest"
+)
+
+// ErrOwnershipConflict 表示清单中的 key 已被其他 owner 或手动创建的任务占用
+var ErrOwnershipConflict = errors.New("key is owned by another manager")
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,126}[a-z0-9])?$`)
+
+// Job 是清单文件中的单个任务，key 在同一 owner 下唯一且长期稳定，用于与已有任务对应
+type Job struct {
+	Key          string            `json:"key"`
+	Namespace    string            `json:"namespace,omitempty"`
+	Name         string            `json:"name"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Enabled      *bool             `json:"enabled,omitempty"`
+	Type         string            `json:"type,omitempty"`
+	HTTP         model.HTTPConfig  `json:"http"`
+	Schedule     model.Schedule    `json:"schedule"`
+	Timeout      model.Duration    `json:"timeout,omitempty"`
+	MaxRetries   *int              `json:"max_retries,omitempty"`
+	RetryBackoff model.Duration    `json:"retry_backoff,omitempty"`
+	Completion   model.Completion  `json:"completion,omitempty"`
+}
+
+type Action struct {
+	Op        string        `json:"op"`
+	Key       string        `json:"key"`
+	Namespace string        `json:"namespace"`
+	JobID     string        `json:"job_id,omitempty"`
+	Name      string        `json:"name"`
+	Changes   []diff.Change `json:"changes,omitempty"`
+
+	// Definition 为 create/update 时应写入的完整定义，expectedVersion 用于写入时检测并发修改
+	Definition      *model.JobDefinition `json:"-"`
+	ExpectedVersion int64                `json:"-"`
+}
+
+type Plan struct {
+	Owner   string   `json:"owner"`
+	Prune   bool     `json:"prune"`
+	Actions []Action `json:"actions"`
+
+	// Orphaned 为未开启 prune 时清单中已不存在、但仍归属该 owner 的任务
+	Orphaned []string `json:"orphaned,omitempty"`
+}
+
+// spec 仅包含清单可声明的字段，用于判断任务是否需要更新
+type spec struct {
+	Name         string            `json:"name"`
+	Labels       map[string]string `json:"labels"`
+	Enabled      bool              `json:"enabled"`
+	Type         string            `json:"type"`
+	HTTP         model.HTTPConfig  `json:"http"`
+	Schedule     model.Schedule    `json:"schedule"`
+	Timeout      model.Duration    `json:"timeout"`
+	MaxRetries   int               `json:"max_retries"`
+	RetryBackoff model.Duration    `json:"retry_backoff"`
+	Completion   model.Completion  `json:"completion"`
+}
+
+// Definition 生成完整定义，未声明的字段依次使用命名空间默认值与全局默认值
+func (j *Job) Definition(owner string, defaults *namespace.Defaults) model.JobDefinition {
+	def := model.JobDefinition{
+		Namespace:    j.Namespace,
+		Key:          j.Key,
+		ManagedBy:    owner,
+		Name:         j.Name,
+		Labels:       j.Labels,
+		Enabled:      true,
+		Type:         j.Type,
+		HTTP:         j.HTTP,
+		Schedule:     j.Schedule,
+		Timeout:      j.Timeout,
+		RetryBackoff: j.RetryBackoff,
+		Completion:   j.Completion,
+	}
+	if j.Enabled != nil {
+		def.Enabled = *j.Enabled
+	}
+	if j.MaxRetries != nil {
+		def.MaxRetries = *j.MaxRetries
+	}
+
+	defaults.Apply(&def, j.MaxRetries != nil)
+	def.SetDefaults()
+	return def
+}
+
+func specOf(def *model.JobDefinition) spec {
+	return spec{
+		Name:         def.Name,
+		Labels:       def.Labels,
+		Enabled:      def.Enabled,
+		Type:         def.Type,
+		HTTP:         def.HTTP,
+		Schedule:     def.Schedule,
+		Timeout:      def.Timeout,
+		MaxRetries:   def.MaxRetries,
+		RetryBackoff: def.RetryBackoff,
+		Completion:   def.Completion,
+	}
+}
+
+// LoadDir 读取目录下所有 .json 文件，每个文件可以是单个任务对象或任务数组
+func LoadDir(dir string) ([]Job, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var jobs []Job
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		parsed, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		jobs = append(jobs, parsed...)
+	}
+
+	return jobs, nil
+}
+
+func Parse(data []byte) ([]Job, error) {
+	data = bytes.TrimSpace(data)
+
+	decode := func(target interface{}) error {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(target)
+	}
+
+	if len(data) > 0 && data[0] == '[' {
+		var jobs []Job
+		if err := decode(&jobs); err != nil {
+			return nil, err
+		}
+		return jobs, nil
+	}
+
+	var job Job
+	if err := decode(&job); err != nil {
+		return nil, err
+	}
+	return []Job{job}, nil
+}
+
+// BuildPlan 对比清单与存储中的任务定义；只有 managed_by 等于 owner 的任务会被更新或删除
+func BuildPlan(jobs []Job, current []model.JobDefinition, owner string, prune bool, namespaces *namespace.Registry) (*Plan, error) {
+	if owner == "" {
+		owner = DefaultOwner
+	}
+
+	existing := make(map[string]*model.JobDefinition)
+	for i := range current {
+		if current[i].Key != "" {
+			existing[current[i].Key] = &current[i]
+		}
+	}
+
+	plan := &Plan{Owner: owner, Prune: prune, Actions: []Action{}}
+	seen := make(map[string]bool, len(jobs))
+
+	for i := range jobs {
+		job := &jobs[i]
+		if !keyPattern.MatchString(job.Key) {
+			return nil, fmt.Errorf("job %q: key must be lowercase alphanumerics, '.', '_' or '-' (max 128 characters)", job.Key)
+		}
+		if seen[job.Key] {
+			return nil, fmt.Errorf("duplicate key %q", job.Key)
+		}
+		seen[job.Key] = true
+
+		ns := job.Namespace
+		if ns == "" {
+			ns = model.DefaultNamespace
+		}
+		config := namespaces.Get(ns)
+
+		def := job.Definition(owner, &config.Defaults)
+		if err := def.Validate(); err != nil {
+			return nil, fmt.Errorf("job %q: %w", job.Key, err)
+		}
+		if err := config.Quotas.CheckDefinition(&def); err != nil {
+			return nil, fmt.Errorf("job %q: %w", job.Key, err)
+		}
+
+		current, exists := existing[job.Key]
+		if !exists {
+			changes, err := diff.Compute(nil, specOf(&def))
+			if err != nil {
+				return nil, err
+			}
+			plan.Actions = append(plan.Actions, Action{
+				Op:         OpCreate,
+				Key:        job.Key,
+				Namespace:  def.Namespace,
+				Name:       def.Name,
+				Changes:    changes,
+				Definition: &def,
+			})
+			continue
+		}
+
+		if current.ManagedBy != owner {
+			return nil, fmt.Errorf("job %q: %w (job %s, managed_by %q)", job.Key, ErrOwnershipConflict, current.ID, current.ManagedBy)
+		}
+
+		// 命名空间在创建后不可修改，迁移需要删除后在新命名空间重新创建
+		if current.Namespace != def.Namespace {
+			return nil, fmt.Errorf("job %q: namespace cannot change from %q to %q", job.Key, current.Namespace, def.Namespace)
+		}
+
+		// 保留清单无法声明的字段（ID、Webhook 等）
+		def.ID = current.ID
+		def.Hooks = current.Hooks
+
+		changes, err := diff.Compute(specOf(current), specOf(&def))
+		if err != nil {
+			return nil, err
+		}
+
+		action := Action{
+			Op:              OpUnchanged,
+			Key:             job.Key,
+			Namespace:       def.Namespace,
+			JobID:           current.ID,
+			Name:            def.Name,
+			ExpectedVersion: current.ResourceVersion,
+		}
+		if len(changes) > 0 {
+			action.Op = OpUpdate
+			action.Changes = changes
+			action.Definition = &def
+		}
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	for i := range current {
+		def := &current[i]
+		if def.ManagedBy != owner || seen[def.Key] {
+			continue
+		}
+
+		if !prune {
+			plan.Orphaned = append(plan.Orphaned, def.Key)
+			continue
+		}
+
+		plan.Actions = append(plan.Actions, Action{
+			Op:              OpDelete,
+			Key:             def.Key,
+			Namespace:       def.Namespace,
+			JobID:           def.ID,
+			Name:            def.Name,
+			ExpectedVersion: def.ResourceVersion,
+		})
+	}
+
+	return plan, nil
+}
+
+func (p *Plan) Count(op string) int {
+	count := 0
+	for _, action := range p.Actions {
+		if action.Op == op {
+			count++
+		}
+	}
+	return count
+}
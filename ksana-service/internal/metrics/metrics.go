@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+type metric interface {
+	write(w http.ResponseWriter)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]metric{}
+)
+
+type Counter struct {
+	name  string
+	help  string
+	value atomic.Int64
+}
+
+type Gauge struct {
+	name  string
+	help  string
+	value atomic.Int64
+}
+
+func NewCounter(name, help string) *Counter {
+	counter := &Counter{name: name, help: help}
+	register(name, counter)
+	return counter
+}
+
+func NewGauge(name, help string) *Gauge {
+	gauge := &Gauge{name: name, help: help}
+	register(name, gauge)
+	return gauge
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) Add(delta int64) {
+	c.value.Add(delta)
+}
+
+func (g *Gauge) Set(value int64) {
+	g.value.Store(value)
+}
+
+func (c *Counter) write(w http.ResponseWriter) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.value.Load())
+}
+
+func (g *Gauge) write(w http.ResponseWriter) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", g.name, g.help, g.name, g.name, g.value.Load())
+}
+
+func register(name string, m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic("metrics: duplicate metric " + name)
+	}
+	registry[name] = m
+}
+
+// Handler 以 Prometheus 文本格式输出所有已注册的指标
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registryMu.Lock()
+		names := make([]string, 0, len(registry))
+		for name := range registry {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		metrics := make([]metric, len(names))
+		for i, name := range names {
+			metrics[i] = registry[name]
+		}
+		registryMu.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		for _, m := range metrics {
+			m.write(w)
+		}
+	})
+}
@@ -52,4 +52,4 @@ func DurationFromString(s string) (Duration, error) {
 
 func DurationFromTimeDuration(d time.Duration) Duration {
 	return Duration(d)
-}
\ No newline at end of file
+}
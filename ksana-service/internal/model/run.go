@@ -0,0 +1,68 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"time"
+)
+
+type Run struct {
+	ID                 string     `json:"id"`
+	JobID              string     `json:"job_id"`
+	Namespace          string     `json:"namespace"`
+	JobName            string     `json:"job_name"`
+	State              string     `json:"state"`
+	Trigger            string     `json:"trigger,omitempty"`
+	Attempts           int        `json:"attempts"`
+	StartedAt          time.Time  `json:"started_at"`
+	FinishedAt         *time.Time `json:"finished_at,omitempty"`
+	Error              string     `json:"error,omitempty"`
+	Output             string     `json:"output,omitempty"`
+	CompletionDeadline *time.Time `json:"completion_deadline,omitempty"`
+	StatusURL          string     `json:"status_url,omitempty"`
+	Polls              []Poll     `json:"polls,omitempty"`
+}
+
+type Poll struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Value      string    `json:"value,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type RunRequest struct {
+	RunID     string
+	Trigger   string
+	Overrides *RunOverrides
+	// Accepted 在回调或轮询模式下首次请求成功、开始等待完成时调用，可为空
+	Accepted func()
+}
+
+type RunOverrides struct {
+	Body    *string           `json:"body,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Query   map[string]string `json:"query,omitempty"`
+	Vars    map[string]string `json:"vars,omitempty"`
+	Payload interface{}       `json:"-"`
+}
+
+const (
+	RunStateRunning = "running"
+)
+
+const (
+	RunTriggerSchedule = "schedule"
+	RunTriggerManual   = "manual"
+	RunTriggerHook     = "hook"
+)
+
+func NewRunID() string {
+	bytes := make([]byte, 16)
+	io.ReadFull(rand.Reader, bytes)
+	return hex.EncodeToString(bytes)
+}
+
+func (r *Run) Finished() bool {
+	return r.State != RunStateRunning
+}
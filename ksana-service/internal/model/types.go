@@ -5,19 +5,34 @@ import (
 )
 
 type Job struct {
-	ID            string     `json:"id"`
-	Name          string     `json:"name"`
-	Enabled       bool       `json:"enabled"`
-	Type          string     `json:"type"`
-	HTTP          HTTPConfig `json:"http"`
-	Schedule      Schedule   `json:"schedule"`
-	Timeout       Duration   `json:"timeout"`
-	MaxRetries    int        `json:"max_retries"`
-	RetryBackoff  Duration   `json:"retry_backoff"`
-	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
-	NextRunAt     *time.Time `json:"next_run_at,omitempty"`
-	LastStatus    string     `json:"last_status"`
-	LastError     string     `json:"last_error"`
+	JobDefinition
+	JobState
+}
+
+type JobDefinition struct {
+	ID              string            `json:"id"`
+	ResourceVersion int64             `json:"resource_version"`
+	Namespace       string            `json:"namespace"`
+	Key             string            `json:"key,omitempty"`
+	ManagedBy       string            `json:"managed_by,omitempty"`
+	Name            string            `json:"name"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Enabled         bool              `json:"enabled"`
+	Type            string            `json:"type"`
+	HTTP            HTTPConfig        `json:"http"`
+	Schedule        Schedule          `json:"schedule"`
+	Timeout         Duration          `json:"timeout"`
+	MaxRetries      int               `json:"max_retries"`
+	RetryBackoff    Duration          `json:"retry_backoff"`
+	Completion      Completion        `json:"completion"`
+	Hooks           []Hook            `json:"hooks,omitempty"`
+}
+
+type JobState struct {
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt  *time.Time `json:"next_run_at,omitempty"`
+	LastStatus string     `json:"last_status"`
+	LastError  string     `json:"last_error"`
 }
 
 type HTTPConfig struct {
@@ -25,6 +40,12 @@ type HTTPConfig struct {
 	URL     string            `json:"url"`
 	Headers map[string]string `json:"headers"`
 	Body    string            `json:"body"`
+
+	// Template 为 true 时 URL、请求头与请求体按 Go 模板渲染，默认按原样发送
+	Template bool `json:"template,omitempty"`
+
+	// SecretHeaders 为请求头名到密文名称的映射，仅在执行时解密填充
+	SecretHeaders map[string]string `json:"secret_headers,omitempty"`
 }
 
 type Schedule struct {
@@ -35,19 +56,60 @@ type Schedule struct {
 	Jitter  Duration   `json:"jitter,omitempty"`
 }
 
+type Completion struct {
+	Mode     string      `json:"mode,omitempty"`
+	Deadline Duration    `json:"deadline,omitempty"`
+	Poll     *PollConfig `json:"poll,omitempty"`
+}
+
+type PollConfig struct {
+	URLField     string   `json:"url_field,omitempty"`
+	Interval     Duration `json:"interval,omitempty"`
+	StatusPath   string   `json:"status_path"`
+	SuccessValue string   `json:"success_value,omitempty"`
+	FailureValue string   `json:"failure_value,omitempty"`
+}
+
+type Hook struct {
+	ID        string `json:"id"`
+	TokenHash string `json:"token_hash"`
+
+	// SealedSecret 为签名密钥的密文，加密密钥由触发令牌派生，存储中只有令牌的哈希，无法据此解密
+	SealedSecret string `json:"sealed_secret,omitempty"`
+
+	// Secret 为旧版本明文保存的签名密钥，下次触发或轮换时改为加密保存
+	Secret string `json:"secret,omitempty"`
+
+	RateLimit int        `json:"rate_limit"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+}
+
+func (h *Hook) Signed() bool {
+	return h.SealedSecret != "" || h.Secret != ""
+}
+
 type JobStore struct {
-	Version   int       `json:"version"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Jobs      []Job     `json:"jobs"`
+	Version         int                 `json:"version"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+	ResourceVersion int64               `json:"resource_version"`
+	Jobs            []JobDefinition     `json:"jobs"`
+	States          map[string]JobState `json:"states"`
 }
 
+const StoreVersion = 3
+
+// DefaultNamespace 为未指定命名空间的任务所属的命名空间
+const DefaultNamespace = "default"
+
 const (
-	JobStatusSuccess = "success"
-	JobStatusFailed  = "failed"
-	JobStatusTimeout = "timeout"
-	JobStatusSkipped = "skipped"
-	JobStatusPaused  = "paused"
-	JobStatusMissed  = "missed"
+	JobStatusSuccess   = "success"
+	JobStatusFailed    = "failed"
+	JobStatusTimeout   = "timeout"
+	JobStatusSkipped   = "skipped"
+	JobStatusPaused    = "paused"
+	JobStatusMissed    = "missed"
+	JobStatusCancelled = "cancelled"
 )
 
 const (
@@ -55,6 +117,12 @@ const (
 	ScheduleKindEvery = "every"
 )
 
+const (
+	CompletionModeSync     = "sync"
+	CompletionModeCallback = "callback"
+	CompletionModePoll     = "poll"
+)
+
 const (
 	JobTypeHTTP = "http"
 )
@@ -62,4 +130,4 @@ const (
 const (
 	HTTPMethodGET  = "GET"
 	HTTPMethodPOST = "POST"
-)
\ No newline at end of file
+)
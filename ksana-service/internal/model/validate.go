@@ -2,16 +2,45 @@ package model
 
 import (
 	"errors"
+	"fmt"
+	"ksana-service/internal/labels"
 	"net/url"
+	"regexp"
+	"sort"
 	"strings"
+	"text/template"
+	"text/template/parse"
 	"time"
 )
 
-func (j *Job) Validate() error {
+// Validate 校验新写入的任务定义，在 ValidateStructure 的基础上要求一次性任务的 run_at 晚于当前时间
+func (j *JobDefinition) Validate() error {
+	if err := j.ValidateStructure(); err != nil {
+		return err
+	}
+
+	if j.Schedule.Kind == ScheduleKindOnce && j.Schedule.RunAt.Before(time.Now().UTC()) {
+		return errors.New("run_at must be in the future")
+	}
+
+	return nil
+}
+
+// ValidateStructure 只校验定义本身是否完整合法，不包含与当前时间相关的规则，
+// 用于从备份恢复等场景：已触发过的一次性任务 run_at 必然早于当前时间
+func (j *JobDefinition) ValidateStructure() error {
 	if j.Name == "" {
 		return errors.New("job name is required")
 	}
 
+	if !ValidNamespace(j.Namespace) {
+		return fmt.Errorf("namespace %q must be 1-63 lowercase alphanumeric characters or '-', starting and ending with an alphanumeric character", j.Namespace)
+	}
+
+	if err := labels.Validate(j.Labels); err != nil {
+		return err
+	}
+
 	if j.Type != JobTypeHTTP {
 		return errors.New("job type must be 'http'")
 	}
@@ -36,6 +65,43 @@ func (j *Job) Validate() error {
 		return errors.New("retry_backoff must be non-negative")
 	}
 
+	if err := j.Completion.Validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Completion) Validate() error {
+	switch c.Mode {
+	case "", CompletionModeSync, CompletionModeCallback:
+	case CompletionModePoll:
+		if c.Poll == nil {
+			return errors.New("poll config is required for 'poll' completion mode")
+		}
+		if err := c.Poll.Validate(); err != nil {
+			return err
+		}
+	default:
+		return errors.New("completion mode must be 'sync', 'callback' or 'poll'")
+	}
+
+	if c.Deadline.ToDuration() < 0 {
+		return errors.New("completion deadline must be non-negative")
+	}
+
+	return nil
+}
+
+func (p *PollConfig) Validate() error {
+	if p.StatusPath == "" {
+		return errors.New("poll status_path is required")
+	}
+
+	if p.Interval.ToDuration() < 0 {
+		return errors.New("poll interval must be non-negative")
+	}
+
 	return nil
 }
 
@@ -58,9 +124,160 @@ func (h *HTTPConfig) Validate() error {
 		return errors.New("invalid HTTP URL")
 	}
 
+	for header, name := range h.SecretHeaders {
+		if strings.TrimSpace(header) == "" {
+			return errors.New("secret_headers keys must be non-empty header names")
+		}
+		if !ValidSecretName(name) {
+			return fmt.Errorf("secret_headers %q: invalid secret name %q", header, name)
+		}
+	}
+
+	if h.Template {
+		if _, err := h.SecretRefs(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// templateFuncStubs 只用于解析校验，执行时由执行器提供实际的模板函数
+var templateFuncStubs = template.FuncMap{
+	"secret": func(string) (string, error) { return "", nil },
+}
+
+// ParseTemplates 解析 URL、请求体与请求头中的模板，返回字段名到模板的映射
+func (h *HTTPConfig) ParseTemplates() (map[string]*template.Template, error) {
+	fields := map[string]string{"url": h.URL, "body": h.Body}
+	for key, value := range h.Headers {
+		fields["header "+key] = value
+	}
+
+	templates := make(map[string]*template.Template, len(fields))
+	for name, text := range fields {
+		tmpl, err := template.New(name).Funcs(templateFuncStubs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template: %w", name, err)
+		}
+		templates[name] = tmpl
+	}
+	return templates, nil
+}
+
+// SecretRefs 返回任务引用的全部密文名称（去重并排序），包括 secret_headers 与开启模板时的 {{secret "name"}}。
+// 模板中 secret 的参数必须是字符串常量，保存前才能校验引用的密文
+func (h *HTTPConfig) SecretRefs() ([]string, error) {
+	seen := make(map[string]bool)
+	for _, name := range h.SecretHeaders {
+		seen[name] = true
+	}
+
+	if h.Template {
+		templates, err := h.ParseTemplates()
+		if err != nil {
+			return nil, err
+		}
+		for field, tmpl := range templates {
+			for _, t := range tmpl.Templates() {
+				if t.Tree == nil {
+					continue
+				}
+				if err := collectSecretRefs(t.Tree.Root, seen); err != nil {
+					return nil, fmt.Errorf("invalid %s template: %w", field, err)
+				}
+			}
+		}
+	}
+
+	refs := make([]string, 0, len(seen))
+	for name := range seen {
+		refs = append(refs, name)
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+func collectSecretRefs(node parse.Node, seen map[string]bool) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := collectSecretRefs(child, seen); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return collectSecretRefs(n.Pipe, seen)
+	case *parse.TemplateNode:
+		return collectSecretRefs(n.Pipe, seen)
+	case *parse.ChainNode:
+		return collectSecretRefs(n.Node, seen)
+	case *parse.IfNode:
+		return collectBranchSecretRefs(&n.BranchNode, seen)
+	case *parse.RangeNode:
+		return collectBranchSecretRefs(&n.BranchNode, seen)
+	case *parse.WithNode:
+		return collectBranchSecretRefs(&n.BranchNode, seen)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := collectSecretRefs(cmd, seen); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for i, arg := range n.Args {
+			if ident, ok := arg.(*parse.IdentifierNode); ok && ident.Ident == "secret" {
+				if i != 0 || len(n.Args) != 2 {
+					return errors.New(`secret must be called as {{secret "name"}}`)
+				}
+				name, ok := n.Args[1].(*parse.StringNode)
+				if !ok {
+					return errors.New("secret name must be a string literal")
+				}
+				if !ValidSecretName(name.Text) {
+					return fmt.Errorf("invalid secret name %q", name.Text)
+				}
+				seen[name.Text] = true
+				return nil
+			}
+			if err := collectSecretRefs(arg, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func collectBranchSecretRefs(n *parse.BranchNode, seen map[string]bool) error {
+	if err := collectSecretRefs(n.Pipe, seen); err != nil {
+		return err
+	}
+	if err := collectSecretRefs(n.List, seen); err != nil {
+		return err
+	}
+	return collectSecretRefs(n.ElseList, seen)
+}
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// ValidSecretName 校验密文名称：字母或数字开头，可包含 '.'、'_'、'-'，最长 128 个字符
+func ValidSecretName(name string) bool {
+	return secretNamePattern.MatchString(name)
+}
+
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidNamespace 校验命名空间名称，规则与 DNS 标签一致
+func ValidNamespace(name string) bool {
+	return namespacePattern.MatchString(name)
+}
+
 func (s *Schedule) Validate() error {
 	if s.Kind != ScheduleKindOnce && s.Kind != ScheduleKindEvery {
 		return errors.New("schedule kind must be 'once' or 'every'")
@@ -71,9 +288,6 @@ func (s *Schedule) Validate() error {
 		if s.RunAt == nil {
 			return errors.New("run_at is required for 'once' schedule")
 		}
-		if s.RunAt.Before(time.Now().UTC()) {
-			return errors.New("run_at must be in the future")
-		}
 	case ScheduleKindEvery:
 		if s.Every.ToDuration() <= 0 {
 			return errors.New("every must be greater than 0 for 'every' schedule")
@@ -86,7 +300,11 @@ func (s *Schedule) Validate() error {
 	return nil
 }
 
-func (j *Job) SetDefaults() {
+func (j *JobDefinition) SetDefaults() {
+	if j.Namespace == "" {
+		j.Namespace = DefaultNamespace
+	}
+
 	if j.Type == "" {
 		j.Type = JobTypeHTTP
 	}
@@ -112,4 +330,4 @@ func (j *Job) SetDefaults() {
 	}
 
 	j.Enabled = true
-}
\ No newline at end of file
+}
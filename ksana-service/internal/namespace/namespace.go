@@ -0,0 +1,150 @@
+package namespace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/labels"
+	"ksana-service/internal/model"
+	"log/slog"
+	"os"
+	"sort"
+)
+
+// Fallback 为配置文件中的通配条目，作用于未单独配置的命名空间
+const Fallback = "*"
+
+var ErrQuotaExceeded = errors.New("namespace quota exceeded")
+
+// Defaults 为命名空间内新建任务的默认值，只填充请求中未设置的字段
+type Defaults struct {
+	Timeout      model.Duration    `json:"timeout,omitempty"`
+	MaxRetries   *int              `json:"max_retries,omitempty"`
+	RetryBackoff model.Duration    `json:"retry_backoff,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// Quotas 为命名空间的配额，零值表示不限制
+type Quotas struct {
+	MaxJobs           int            `json:"max_jobs,omitempty"`
+	MinEvery          model.Duration `json:"min_every,omitempty"`
+	MaxConcurrentRuns int            `json:"max_concurrent_runs,omitempty"`
+}
+
+type Config struct {
+	Defaults Defaults `json:"defaults"`
+	Quotas   Quotas   `json:"quotas"`
+}
+
+// Registry 保存各命名空间的默认值与配额，启动时从配置文件加载
+type Registry struct {
+	configs map[string]Config
+}
+
+// Load 读取命名空间配置文件，文件不存在时所有命名空间均无默认值与配额
+func Load(path string, logger *slog.Logger) (*Registry, error) {
+	r := &Registry{configs: map[string]Config{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		logger.Info("Namespace config not found, no defaults or quotas applied", "file", path)
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read namespace config %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &r.configs); err != nil {
+		return nil, fmt.Errorf("failed to parse namespace config %s: %w", path, err)
+	}
+
+	for _, name := range r.Names() {
+		if name != Fallback && !model.ValidNamespace(name) {
+			return nil, fmt.Errorf("namespace config %s: invalid namespace %q", path, name)
+		}
+		if err := r.configs[name].validate(); err != nil {
+			return nil, fmt.Errorf("namespace config %s: namespace %q: %w", path, name, err)
+		}
+	}
+
+	logger.Info("Loaded namespace config", "namespaces", len(r.configs), "file", path)
+	return r, nil
+}
+
+func (c Config) validate() error {
+	if c.Defaults.Timeout.ToDuration() < 0 || c.Defaults.RetryBackoff.ToDuration() < 0 {
+		return errors.New("default durations must be non-negative")
+	}
+	if c.Defaults.MaxRetries != nil && *c.Defaults.MaxRetries < 0 {
+		return errors.New("default max_retries must be non-negative")
+	}
+	if err := labels.Validate(c.Defaults.Labels); err != nil {
+		return fmt.Errorf("default labels: %w", err)
+	}
+	if c.Quotas.MaxJobs < 0 || c.Quotas.MaxConcurrentRuns < 0 || c.Quotas.MinEvery.ToDuration() < 0 {
+		return errors.New("quotas must be non-negative")
+	}
+	return nil
+}
+
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.configs))
+	for name := range r.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get 返回命名空间的配置，未单独配置时使用通配条目
+func (r *Registry) Get(name string) Config {
+	if config, exists := r.configs[name]; exists {
+		return config
+	}
+	return r.configs[Fallback]
+}
+
+func (r *Registry) MaxConcurrentRuns(name string) int {
+	return r.Get(name).Quotas.MaxConcurrentRuns
+}
+
+// Apply 在 SetDefaults 之前填充命名空间默认值；maxRetriesSet 表示请求中显式设置了 max_retries
+func (d *Defaults) Apply(def *model.JobDefinition, maxRetriesSet bool) {
+	if def.Timeout.ToDuration() == 0 {
+		def.Timeout = d.Timeout
+	}
+	if !maxRetriesSet && d.MaxRetries != nil {
+		def.MaxRetries = *d.MaxRetries
+	}
+	if def.RetryBackoff.ToDuration() == 0 {
+		def.RetryBackoff = d.RetryBackoff
+	}
+
+	if len(d.Labels) > 0 {
+		merged := make(map[string]string, len(d.Labels)+len(def.Labels))
+		for key, value := range d.Labels {
+			merged[key] = value
+		}
+		for key, value := range def.Labels {
+			merged[key] = value
+		}
+		def.Labels = merged
+	}
+}
+
+// CheckDefinition 校验任务定义是否满足命名空间配额（目前为周期任务的最小间隔）
+func (q *Quotas) CheckDefinition(def *model.JobDefinition) error {
+	minEvery := q.MinEvery.ToDuration()
+	if minEvery > 0 && def.Schedule.Kind == model.ScheduleKindEvery && def.Schedule.Every.ToDuration() < minEvery {
+		return fmt.Errorf("%w: namespace %s requires schedule.every of at least %s", ErrQuotaExceeded, def.Namespace, minEvery)
+	}
+	return nil
+}
+
+// CheckJobCount 校验新增 adding 个任务后是否超过命名空间的任务数上限
+func (q *Quotas) CheckJobCount(namespace string, current, adding int) error {
+	if q.MaxJobs > 0 && current+adding > q.MaxJobs {
+		return fmt.Errorf("%w: namespace %s allows at most %d jobs (currently %d)", ErrQuotaExceeded, namespace, q.MaxJobs, current)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package revision
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionPause    = "pause"
+	ActionResume   = "resume"
+	ActionHooks    = "hooks"
+	ActionRollback = "rollback"
+	ActionDelete   = "delete"
+	ActionRestore  = "restore"
+)
+
+var ErrNotFound = errors.New("revision not found")
+
+type Revision struct {
+	Revision        int                 `json:"revision"`
+	JobID           string              `json:"job_id"`
+	ResourceVersion int64               `json:"resource_version"`
+	Action          string              `json:"action"`
+	KeyID           string              `json:"key_id,omitempty"`
+	At              time.Time           `json:"at"`
+	RolledBackFrom  int                 `json:"rolled_back_from,omitempty"`
+	Definition      model.JobDefinition `json:"definition"`
+}
+
+// Store 按任务将每次定义变更追加到 revisions/<job_id>.jsonl，删除任务后仍保留历史
+type Store struct {
+	dir  string
+	mu   sync.Mutex
+	last map[string]int
+}
+
+func NewStore(dataDir string) *Store {
+	return &Store{
+		dir:  filepath.Join(dataDir, "revisions"),
+		last: make(map[string]int),
+	}
+}
+
+func (s *Store) Record(rev *Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.path(rev.JobID)
+	if err != nil {
+		return err
+	}
+
+	last, known := s.last[rev.JobID]
+	if !known {
+		revisions, err := s.readLocked(rev.JobID)
+		if err != nil {
+			return err
+		}
+		if len(revisions) > 0 {
+			last = revisions[len(revisions)-1].Revision
+		}
+	}
+
+	rev.Revision = last + 1
+	rev.At = time.Now().UTC()
+
+	line, err := json.Marshal(rev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revision: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create revisions directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open revision log: %w", err)
+	}
+	defer file.Close()
+
+	// 上次写入中断留下的半行需要先补换行，否则会与本条记录粘连
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		tail := make([]byte, 1)
+		if _, err := file.ReadAt(tail, info.Size()-1); err == nil && tail[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append revision: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync revision log: %w", err)
+	}
+
+	s.last[rev.JobID] = rev.Revision
+	return nil
+}
+
+func (s *Store) List(jobID string) ([]Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.readLocked(jobID)
+}
+
+func (s *Store) Get(jobID string, number int) (*Revision, error) {
+	revisions, err := s.List(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range revisions {
+		if revisions[i].Revision == number {
+			return &revisions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: job %s revision %d", ErrNotFound, jobID, number)
+}
+
+func (s *Store) readLocked(jobID string) ([]Revision, error) {
+	path, err := s.path(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []Revision{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open revision log: %w", err)
+	}
+	defer file.Close()
+
+	revisions := []Revision{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var rev Revision
+		// 崩溃时可能留下半行，跳过无法解析的行
+		if err := json.Unmarshal(scanner.Bytes(), &rev); err != nil {
+			continue
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read revision log: %w", err)
+	}
+
+	if len(revisions) > 0 {
+		s.last[jobID] = revisions[len(revisions)-1].Revision
+	}
+	return revisions, nil
+}
+
+func (s *Store) path(jobID string) (string, error) {
+	if jobID == "" || strings.ContainsAny(jobID, `/\`) || strings.HasPrefix(jobID, ".") {
+		return "", fmt.Errorf("invalid job id %q", jobID)
+	}
+	return filepath.Join(s.dir, jobID+".jsonl"), nil
+}
@@ -13,7 +13,7 @@ import (
 )
 
 type Executor interface {
-	Execute(ctx context.Context, job *model.Job) error
+	Execute(ctx context.Context, job *model.Job, runReq *model.RunRequest) error
 }
 
 type Scheduler struct {
@@ -22,40 +22,86 @@ type Scheduler struct {
 	clock    Clock
 	jobHeap  *JobHeap
 	jobIndex map[string]*JobItem
-	timer    *time.Timer
-	mu       sync.RWMutex
-	ctx      context.Context
-	cancel   context.CancelFunc
-	wg       sync.WaitGroup
-	logger   *slog.Logger
+	// generations 记录每个已调度任务当前的调度代数，任务被更新、暂停或删除后旧代数失效，
+	// 执行中的旧任务结束后不再据此安排下一次运行
+	generations map[string]uint64
+	generation  uint64
+	timer       *time.Timer
+	mu          sync.RWMutex
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	running     bool
+	logger      *slog.Logger
 }
 
 func NewScheduler(store store.Store, executor Executor, clock Clock, logger *slog.Logger) *Scheduler {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Scheduler{
-		store:    store,
-		executor: executor,
-		clock:    clock,
-		jobHeap:  NewJobHeap(),
-		jobIndex: make(map[string]*JobItem),
-		ctx:      ctx,
-		cancel:   cancel,
-		logger:   logger,
+		store:       store,
+		executor:    executor,
+		clock:       clock,
+		jobHeap:     NewJobHeap(),
+		jobIndex:    make(map[string]*JobItem),
+		generations: make(map[string]uint64),
+		ctx:         ctx,
+		cancel:      cancel,
+		logger:      logger,
 	}
 }
 
+// Start 按存储中的任务建立调度计划并启动调度循环；存储由服务在启动时加载一次，这里不再重复加载
 func (s *Scheduler) Start() error {
-	jobStore, err := s.store.Load(s.ctx)
+	jobs, err := s.store.List(s.ctx)
 	if err != nil {
-		return fmt.Errorf("failed to load jobs: %w", err)
+		return fmt.Errorf("failed to list jobs: %w", err)
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.scheduleJobs(jobs)
+	s.startLoopLocked()
+
+	return nil
+}
+
+func (s *Scheduler) startLoopLocked() {
+	if s.running {
+		return
+	}
+	s.running = true
+	s.wg.Add(1)
+	go s.schedulerLoop()
+}
+
+// Reload 丢弃当前的调度计划并按存储中的任务重建，用于整体替换存储内容之后；
+// 存储只读时调度器未启动，从备份恢复后在此启动
+func (s *Scheduler) Reload() error {
+	jobs, err := s.store.List(s.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 重建代数表：恢复前开始、仍在执行的任务结束后不会把旧定义续排进新的调度计划
+	s.jobHeap = NewJobHeap()
+	s.jobIndex = make(map[string]*JobItem)
+	s.generations = make(map[string]uint64)
+	s.scheduleJobs(jobs)
+	s.resetTimer()
+	s.startLoopLocked()
+
+	s.logger.Info("Reloaded scheduler", "jobs", len(jobs), "scheduled", s.jobHeap.Len())
+	return nil
+}
+
+func (s *Scheduler) scheduleJobs(jobs []model.Job) {
 	now := s.clock.Now()
-	for i := range jobStore.Jobs {
-		job := &jobStore.Jobs[i]
+	for i := range jobs {
+		job := &jobs[i]
 		if !job.Enabled {
 			continue
 		}
@@ -64,16 +110,16 @@ func (s *Scheduler) Start() error {
 			s.logger.Error("Failed to calculate next run for job", "job_id", job.ID, "error", err)
 			continue
 		}
+		if s.missedRun(job, now) && job.LastStatus != model.JobStatusMissed {
+			job.LastStatus = model.JobStatusMissed
+			s.persistSchedule(job, true)
+		}
 
+		s.track(job.ID)
 		if job.NextRunAt != nil {
 			s.addJobToHeap(job, *job.NextRunAt)
 		}
 	}
-
-	s.wg.Add(1)
-	go s.schedulerLoop()
-
-	return nil
 }
 
 func (s *Scheduler) Stop() {
@@ -86,6 +132,7 @@ func (s *Scheduler) AddJob(job *model.Job) error {
 	defer s.mu.Unlock()
 
 	if !job.Enabled {
+		delete(s.generations, job.ID)
 		return nil
 	}
 
@@ -93,6 +140,12 @@ func (s *Scheduler) AddJob(job *model.Job) error {
 	if err := s.calculateNextRun(job, now); err != nil {
 		return err
 	}
+	s.track(job.ID)
+	missed := s.missedRun(job, now)
+	if missed {
+		job.LastStatus = model.JobStatusMissed
+	}
+	s.persistSchedule(job, missed)
 
 	if job.NextRunAt != nil {
 		s.addJobToHeap(job, *job.NextRunAt)
@@ -112,6 +165,7 @@ func (s *Scheduler) UpdateJob(job *model.Job) error {
 	}
 
 	if !job.Enabled {
+		delete(s.generations, job.ID)
 		s.resetTimer()
 		return nil
 	}
@@ -120,6 +174,8 @@ func (s *Scheduler) UpdateJob(job *model.Job) error {
 	if err := s.calculateNextRun(job, now); err != nil {
 		return err
 	}
+	s.track(job.ID)
+	s.persistSchedule(job, false)
 
 	if job.NextRunAt != nil {
 		s.addJobToHeap(job, *job.NextRunAt)
@@ -133,6 +189,7 @@ func (s *Scheduler) RemoveJob(jobID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	delete(s.generations, jobID)
 	if item, exists := s.jobIndex[jobID]; exists {
 		s.jobHeap.remove(item)
 		delete(s.jobIndex, jobID)
@@ -140,19 +197,29 @@ func (s *Scheduler) RemoveJob(jobID string) {
 	}
 }
 
-func (s *Scheduler) RunNow(jobID string) error {
+func (s *Scheduler) RunNow(jobID string, overrides *model.RunOverrides) (string, error) {
+	return s.Trigger(jobID, model.RunTriggerManual, overrides)
+}
+
+func (s *Scheduler) Trigger(jobID, trigger string, overrides *model.RunOverrides) (string, error) {
 	job, err := s.store.Get(s.ctx, jobID)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	runReq := &model.RunRequest{
+		RunID:     model.NewRunID(),
+		Trigger:   trigger,
+		Overrides: overrides,
 	}
 
 	go func() {
-		if err := s.executor.Execute(s.ctx, job); err != nil {
-			s.logger.Error("Failed to execute job", "job_id", job.ID, "error", err)
+		if err := s.executor.Execute(s.ctx, job, runReq); err != nil {
+			s.logger.Error("Failed to execute job", "job_id", job.ID, "run_id", runReq.RunID, "error", err)
 		}
 	}()
 
-	return nil
+	return runReq.RunID, nil
 }
 
 func (s *Scheduler) schedulerLoop() {
@@ -179,6 +246,7 @@ func (s *Scheduler) processReadyJobs() {
 
 	now := s.clock.Now()
 	var readyJobs []*model.Job
+	var generations []uint64
 
 	for s.jobHeap.Len() > 0 {
 		item := (*s.jobHeap)[0]
@@ -189,31 +257,45 @@ func (s *Scheduler) processReadyJobs() {
 		item = heap.Pop(s.jobHeap).(*JobItem)
 		delete(s.jobIndex, item.Job.ID)
 		readyJobs = append(readyJobs, item.Job)
+		generations = append(generations, s.generations[item.Job.ID])
 	}
 
 	s.resetTimer()
 
-	for _, job := range readyJobs {
-		s.executeJob(job, now)
+	for i, job := range readyJobs {
+		s.executeJob(job, now, generations[i])
 	}
 }
 
-func (s *Scheduler) executeJob(job *model.Job, scheduledTime time.Time) {
+func (s *Scheduler) executeJob(job *model.Job, scheduledTime time.Time, generation uint64) {
 	go func() {
-		if err := s.executor.Execute(s.ctx, job); err != nil {
-			s.logger.Error("Failed to execute job", "job_id", job.ID, "error", err)
+		var reschedule sync.Once
+		next := func() {
+			if job.Schedule.Kind == model.ScheduleKindEvery {
+				reschedule.Do(func() { s.scheduleNextRun(job, scheduledTime, generation) })
+			}
 		}
 
-		if job.Schedule.Kind == model.ScheduleKindEvery {
-			s.scheduleNextRun(job, scheduledTime)
+		// 回调或轮询模式的任务在首次请求被接受后即安排下一次运行，不等待完成；
+		// 等待中的执行与新的执行可以重叠，受命名空间并发执行上限约束
+		runReq := &model.RunRequest{Trigger: model.RunTriggerSchedule, Accepted: next}
+		if err := s.executor.Execute(s.ctx, job, runReq); err != nil {
+			s.logger.Error("Failed to execute job", "job_id", job.ID, "error", err)
 		}
+		next()
 	}()
 }
 
-func (s *Scheduler) scheduleNextRun(job *model.Job, lastScheduled time.Time) {
+func (s *Scheduler) scheduleNextRun(job *model.Job, lastScheduled time.Time, generation uint64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// 运行期间任务被暂停、删除或更新过：调度计划已由对应操作处理，这份旧定义不再续排
+	if s.generations[job.ID] != generation {
+		s.logger.Debug("Skipping reschedule of stale job", "job_id", job.ID)
+		return
+	}
+
 	now := s.clock.Now()
 	nextRun := lastScheduled.Add(job.Schedule.Every.ToDuration())
 
@@ -230,11 +312,33 @@ func (s *Scheduler) scheduleNextRun(job *model.Job, lastScheduled time.Time) {
 	s.addJobToHeap(job, nextRun)
 	s.resetTimer()
 
-	if err := s.store.Put(s.ctx, job); err != nil {
+	s.persistSchedule(job, false)
+}
+
+// persistSchedule 保存下次运行时间，missed 为 true 时同时把状态记为 missed
+func (s *Scheduler) persistSchedule(job *model.Job, missed bool) {
+	nextRunAt := job.NextRunAt
+
+	_, err := s.store.UpdateState(s.ctx, job.ID, func(state *model.JobState) {
+		state.NextRunAt = nextRunAt
+		if missed {
+			state.LastStatus = model.JobStatusMissed
+		}
+	})
+	if err != nil {
 		s.logger.Error("Failed to update job next run time", "job_id", job.ID, "error", err)
 	}
 }
 
+// missedRun 判断一次性任务是否错过了运行时间：只有从未运行过的任务才算错过，
+// 已经运行过的任务保留真实的运行结果
+func (s *Scheduler) missedRun(job *model.Job, now time.Time) bool {
+	return job.Schedule.Kind == model.ScheduleKindOnce &&
+		job.Schedule.RunAt != nil &&
+		job.Schedule.RunAt.Before(now) &&
+		job.LastRunAt == nil
+}
+
 func (s *Scheduler) calculateNextRun(job *model.Job, now time.Time) error {
 	switch job.Schedule.Kind {
 	case model.ScheduleKindOnce:
@@ -242,7 +346,6 @@ func (s *Scheduler) calculateNextRun(job *model.Job, now time.Time) error {
 			return fmt.Errorf("run_at is required for once schedule")
 		}
 		if job.Schedule.RunAt.Before(now) {
-			job.LastStatus = model.JobStatusMissed
 			job.NextRunAt = nil
 			return nil
 		}
@@ -272,7 +375,18 @@ func (s *Scheduler) calculateNextRun(job *model.Job, now time.Time) error {
 	return nil
 }
 
+// track 为任务分配新的调度代数，使此前开始的执行结束后不再续排
+func (s *Scheduler) track(jobID string) {
+	s.generation++
+	s.generations[jobID] = s.generation
+}
+
+// addJobToHeap 将任务放入调度堆，同一任务已有的条目先移除，保证堆中每个任务至多一项且都可由 jobIndex 找到
 func (s *Scheduler) addJobToHeap(job *model.Job, runTime time.Time) {
+	if existing, exists := s.jobIndex[job.ID]; exists {
+		s.jobHeap.remove(existing)
+	}
+
 	item := &JobItem{
 		Job:     job,
 		RunTime: runTime,
@@ -306,4 +420,4 @@ func (s *Scheduler) getTimerChannel() <-chan time.Time {
 		return nil
 	}
 	return s.timer.C
-}
\ No newline at end of file
+}
@@ -0,0 +1,96 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+const (
+	Redacted = "[REDACTED]"
+
+	// 过短的值替换后误伤普通文本的概率过高，不做脱敏
+	minRedactLength = 4
+)
+
+// Redact 将文本中出现的密文明文替换为 [REDACTED]
+func (s *Store) Redact(text string) string {
+	if text == "" {
+		return text
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, value := range s.plain {
+		if len(value) >= minRedactLength && strings.Contains(text, value) {
+			text = strings.ReplaceAll(text, value, Redacted)
+		}
+	}
+	return text
+}
+
+type redactor interface {
+	Redact(text string) string
+}
+
+// RedactingHandler 在日志输出前对消息与所有字符串类字段做脱敏
+type RedactingHandler struct {
+	next     slog.Handler
+	redactor redactor
+}
+
+func NewRedactingHandler(next slog.Handler, redactor redactor) *RedactingHandler {
+	return &RedactingHandler{next: next, redactor: redactor}
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactor.Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redactAttr(attr)
+	}
+	return &RedactingHandler{next: h.next.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
+
+func (h *RedactingHandler) redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.redactor.Redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, member := range group {
+			redacted[i] = h.redactAttr(member)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case error:
+			return slog.String(attr.Key, h.redactor.Redact(v.Error()))
+		case fmt.Stringer:
+			return slog.String(attr.Key, h.redactor.Redact(v.String()))
+		}
+	}
+
+	return slog.Attr{Key: attr.Key, Value: value}
+}
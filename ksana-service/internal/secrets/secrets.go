@@ -0,0 +1,484 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"ksana-service/internal/auth"
+	"ksana-service/internal/model"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileVersion = 1
+	keySize     = 32
+)
+
+var (
+	ErrNotFound         = errors.New("secret not found")
+	ErrInvalidName      = errors.New("invalid secret name")
+	ErrInvalidNamespace = errors.New("invalid secret namespace")
+	ErrNamespaceDenied  = errors.New("secret is not available in namespace")
+	ErrKeyMismatch      = errors.New("master key does not match the secrets file")
+)
+
+type Info struct {
+	Name       string    `json:"name"`
+	Namespaces []string  `json:"namespaces"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// sealedSecret 的 Namespaces 为允许引用该密文的任务命名空间，"*" 表示全部；
+// 未记录命名空间的旧密文只对 default 命名空间可用
+type sealedSecret struct {
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	Namespaces []string  `json:"namespaces,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (s *sealedSecret) allowedNamespaces() []string {
+	if len(s.Namespaces) == 0 {
+		return []string{model.DefaultNamespace}
+	}
+	return s.Namespaces
+}
+
+func (s *sealedSecret) allows(namespace string) bool {
+	for _, allowed := range s.allowedNamespaces() {
+		if allowed == auth.AllNamespaces || allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *sealedSecret) info(name string) Info {
+	return Info{
+		Name:       name,
+		Namespaces: append([]string(nil), s.allowedNamespaces()...),
+		CreatedAt:  s.CreatedAt,
+		UpdatedAt:  s.UpdatedAt,
+	}
+}
+
+type secretsFile struct {
+	Version int                     `json:"version"`
+	KeyID   string                  `json:"key_id"`
+	Secrets map[string]sealedSecret `json:"secrets"`
+}
+
+// Store 以 AES-256-GCM 加密保存命名密文，主密钥保存在独立的密钥文件中；
+// 明文仅保存在内存中，供执行器解析引用与日志脱敏使用
+type Store struct {
+	path    string
+	keyFile string
+	logger  *slog.Logger
+
+	mu     sync.RWMutex
+	key    []byte
+	sealed map[string]sealedSecret
+	plain  map[string]string
+}
+
+func New(dataDir, keyFile string, logger *slog.Logger) *Store {
+	return &Store{
+		path:    filepath.Join(dataDir, "secrets.json"),
+		keyFile: keyFile,
+		logger:  logger,
+		sealed:  make(map[string]sealedSecret),
+		plain:   make(map[string]string),
+	}
+}
+
+// Open 读取主密钥（不存在时生成）并解密全部密文；密钥不匹配时拒绝启动
+func (s *Store) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, err := s.loadKey()
+	if err != nil {
+		return err
+	}
+
+	file, err := s.readFile()
+	if err != nil {
+		return err
+	}
+
+	// 轮换在写入新密文后、替换密钥文件前中断时，使用待生效的新密钥完成轮换
+	pending := s.keyFile + ".new"
+	if file.KeyID != "" && file.KeyID != keyID(key) {
+		newKey, err := readKeyFile(pending)
+		if err != nil || file.KeyID != keyID(newKey) {
+			return fmt.Errorf("%w: file is encrypted with key %s, %s is key %s", ErrKeyMismatch, file.KeyID, s.keyFile, keyID(key))
+		}
+		if err := os.Rename(pending, s.keyFile); err != nil {
+			return fmt.Errorf("failed to complete key rotation: %w", err)
+		}
+		s.logger.Warn("Completed interrupted master key rotation", "key_id", file.KeyID)
+		key = newKey
+	} else {
+		os.Remove(pending)
+	}
+
+	plain := make(map[string]string, len(file.Secrets))
+	legacy := 0
+	for name, sealed := range file.Secrets {
+		value, err := open(key, name, sealed)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt secret %q: %w", name, err)
+		}
+		plain[name] = value
+		if len(sealed.Namespaces) == 0 {
+			legacy++
+		}
+	}
+	if legacy > 0 {
+		s.logger.Warn("Secrets without namespaces are only available in the default namespace", "count", legacy)
+	}
+
+	s.key = key
+	s.sealed = file.Secrets
+	s.plain = plain
+
+	s.logger.Info("Secrets loaded", "count", len(plain), "key_id", keyID(key))
+	return nil
+}
+
+func (s *Store) KeyID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return keyID(s.key)
+}
+
+func (s *Store) List() []Info {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]Info, 0, len(s.sealed))
+	for name, sealed := range s.sealed {
+		infos = append(infos, sealed.info(name))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// Put 创建或更新密文，返回的 created 表示是否为新建。
+// namespaces 为空时更新保留原有的命名空间，新建则只允许 default 命名空间
+func (s *Store) Put(name, value string, namespaces []string) (*Info, bool, error) {
+	if !model.ValidSecretName(name) {
+		return nil, false, ErrInvalidName
+	}
+	for _, namespace := range namespaces {
+		if namespace != auth.AllNamespaces && !model.ValidNamespace(namespace) {
+			return nil, false, fmt.Errorf("%w: %q", ErrInvalidNamespace, namespace)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	previous, exists := s.sealed[name]
+
+	sealed, err := seal(s.key, name, value)
+	if err != nil {
+		return nil, false, err
+	}
+	sealed.Namespaces = append([]string(nil), namespaces...)
+	sealed.CreatedAt = now
+	if exists {
+		sealed.CreatedAt = previous.CreatedAt
+		if len(namespaces) == 0 {
+			sealed.Namespaces = previous.Namespaces
+		}
+	}
+	if len(sealed.Namespaces) == 0 {
+		sealed.Namespaces = []string{model.DefaultNamespace}
+	}
+	sealed.UpdatedAt = now
+
+	s.sealed[name] = sealed
+	if err := s.saveLocked(s.key, s.sealed); err != nil {
+		if exists {
+			s.sealed[name] = previous
+		} else {
+			delete(s.sealed, name)
+		}
+		return nil, false, err
+	}
+	s.plain[name] = value
+
+	info := sealed.info(name)
+	return &info, !exists, nil
+}
+
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, exists := s.sealed[name]
+	if !exists {
+		return ErrNotFound
+	}
+
+	delete(s.sealed, name)
+	if err := s.saveLocked(s.key, s.sealed); err != nil {
+		s.sealed[name] = previous
+		return err
+	}
+	delete(s.plain, name)
+	return nil
+}
+
+// Check 校验 namespace 中的任务能否引用密文
+func (s *Store) Check(namespace, name string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.checkLocked(namespace, name)
+}
+
+// Resolve 返回 namespace 中的任务引用的密文值，密文不允许该命名空间使用时返回 ErrNamespaceDenied
+func (s *Store) Resolve(namespace, name string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkLocked(namespace, name); err != nil {
+		return "", err
+	}
+	return s.plain[name], nil
+}
+
+func (s *Store) checkLocked(namespace, name string) error {
+	sealed, exists := s.sealed[name]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if !sealed.allows(namespace) {
+		return fmt.Errorf("%w %s: %s", ErrNamespaceDenied, namespace, name)
+	}
+	return nil
+}
+
+// Rotate 生成新的主密钥并用其重新加密全部密文。
+// 先写入 <密钥文件>.new 与新的密文文件，最后替换密钥文件；中途中断时 Open 会完成剩余步骤
+func (s *Store) Rotate() (string, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, newKey); err != nil {
+		return "", 0, err
+	}
+
+	resealed := make(map[string]sealedSecret, len(s.sealed))
+	for name, previous := range s.sealed {
+		sealed, err := seal(newKey, name, s.plain[name])
+		if err != nil {
+			return "", 0, err
+		}
+		sealed.Namespaces = previous.Namespaces
+		sealed.CreatedAt = previous.CreatedAt
+		sealed.UpdatedAt = previous.UpdatedAt
+		resealed[name] = sealed
+	}
+
+	pending := s.keyFile + ".new"
+	if err := writePrivateFile(pending, []byte(hex.EncodeToString(newKey)+"\n")); err != nil {
+		return "", 0, fmt.Errorf("failed to write new master key: %w", err)
+	}
+
+	if err := s.saveLocked(newKey, resealed); err != nil {
+		os.Remove(pending)
+		return "", 0, err
+	}
+
+	if err := os.Rename(pending, s.keyFile); err != nil {
+		return "", 0, fmt.Errorf("failed to replace master key file: %w", err)
+	}
+
+	oldKeyID := keyID(s.key)
+	s.key = newKey
+	s.sealed = resealed
+
+	s.logger.Info("Master key rotated", "old_key_id", oldKeyID, "key_id", keyID(newKey), "secrets", len(resealed))
+	return keyID(newKey), len(resealed), nil
+}
+
+func (s *Store) loadKey() ([]byte, error) {
+	key, err := readKeyFile(s.keyFile)
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// 首次启动且尚无任何密文时生成主密钥
+	if _, statErr := os.Stat(s.path); statErr == nil {
+		return nil, fmt.Errorf("master key file %s not found but %s exists", s.keyFile, s.path)
+	}
+
+	key = make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.keyFile), 0700); err != nil {
+		return nil, err
+	}
+	if err := writePrivateFile(s.keyFile, []byte(hex.EncodeToString(key)+"\n")); err != nil {
+		return nil, fmt.Errorf("failed to write master key: %w", err)
+	}
+
+	s.logger.Warn("Generated new master key for secrets", "path", s.keyFile, "key_id", keyID(key))
+	return key, nil
+}
+
+func (s *Store) readFile() (*secretsFile, error) {
+	file := &secretsFile{Secrets: make(map[string]sealedSecret)}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file: %w", err)
+	}
+	if file.Version > fileVersion {
+		return nil, fmt.Errorf("secrets file version %d is newer than supported version %d", file.Version, fileVersion)
+	}
+	if file.Secrets == nil {
+		file.Secrets = make(map[string]sealedSecret)
+	}
+	return file, nil
+}
+
+func (s *Store) saveLocked(key []byte, sealed map[string]sealedSecret) error {
+	data, err := json.MarshalIndent(secretsFile{
+		Version: fileVersion,
+		KeyID:   keyID(key),
+		Secrets: sealed,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	if err := writePrivateFile(s.path, data); err != nil {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	return nil
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("master key file %s must contain %d hex-encoded bytes", path, keySize)
+	}
+	return key, nil
+}
+
+// seal 以密文名称作为附加数据，防止密文在不同名称之间被替换
+func seal(key []byte, name, value string) (sealedSecret, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return sealedSecret{}, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return sealedSecret{}, err
+	}
+
+	return sealedSecret{
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, []byte(value), []byte(name)),
+	}, nil
+}
+
+func open(key []byte, name string, sealed sealedSecret) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed.Nonce) != gcm.NonceSize() {
+		return "", errors.New("invalid nonce")
+	}
+
+	plain, err := gcm.Open(nil, sealed.Nonce, sealed.Ciphertext, []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func keyID(key []byte) string {
+	if len(key) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func writePrivateFile(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	file.Close()
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
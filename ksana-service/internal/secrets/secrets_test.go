@@ -0,0 +1,202 @@
+package secrets
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestStore(dir string) *Store {
+	return New(dir, filepath.Join(dir, "master.key"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func openTestStore(t *testing.T, dir string) *Store {
+	t.Helper()
+
+	s := newTestStore(dir)
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return s
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotationRecovery(t *testing.T) {
+	tests := []struct {
+		name string
+		// interrupt 在完整轮换之后把目录改回中断时的状态，返回期望的密钥 ID；
+		// oldKey/newKey 为轮换前后的密钥文件内容，oldFile 为轮换前的密文文件
+		interrupt func(t *testing.T, dir string, oldKey, newKey, oldFile []byte) string
+		wantErr   error
+	}{
+		{
+			name: "completed",
+			interrupt: func(t *testing.T, dir string, oldKey, newKey, oldFile []byte) string {
+				return keyIDOf(t, newKey)
+			},
+		},
+		{
+			name: "interrupted before the key file was replaced",
+			interrupt: func(t *testing.T, dir string, oldKey, newKey, oldFile []byte) string {
+				writeFile(t, filepath.Join(dir, "master.key"), oldKey)
+				writeFile(t, filepath.Join(dir, "master.key.new"), newKey)
+				return keyIDOf(t, newKey)
+			},
+		},
+		{
+			name: "interrupted before the secrets file was written",
+			interrupt: func(t *testing.T, dir string, oldKey, newKey, oldFile []byte) string {
+				writeFile(t, filepath.Join(dir, "master.key"), oldKey)
+				writeFile(t, filepath.Join(dir, "master.key.new"), newKey)
+				writeFile(t, filepath.Join(dir, "secrets.json"), oldFile)
+				return keyIDOf(t, oldKey)
+			},
+		},
+		{
+			name: "key file does not match and no pending key",
+			interrupt: func(t *testing.T, dir string, oldKey, newKey, oldFile []byte) string {
+				writeFile(t, filepath.Join(dir, "master.key"), oldKey)
+				return ""
+			},
+			wantErr: ErrKeyMismatch,
+		},
+		{
+			name: "pending key does not match either",
+			interrupt: func(t *testing.T, dir string, oldKey, newKey, oldFile []byte) string {
+				writeFile(t, filepath.Join(dir, "master.key"), oldKey)
+				writeFile(t, filepath.Join(dir, "master.key.new"), []byte(strings.Repeat("ab", keySize)+"\n"))
+				return ""
+			},
+			wantErr: ErrKeyMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			keyPath := filepath.Join(dir, "master.key")
+
+			s := openTestStore(t, dir)
+			if _, _, err := s.Put("api-token", "s3cret", []string{"billing"}); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := s.Put("db-password", "hunter2", nil); err != nil {
+				t.Fatal(err)
+			}
+			oldKey, oldFile := readFile(t, keyPath), readFile(t, filepath.Join(dir, "secrets.json"))
+
+			rotatedID, count, err := s.Rotate()
+			if err != nil || count != 2 {
+				t.Fatalf("Rotate = %d, %v; want 2 secrets", count, err)
+			}
+			newKey := readFile(t, keyPath)
+			if rotatedID != keyIDOf(t, newKey) {
+				t.Fatalf("Rotate returned key %s, key file holds %s", rotatedID, keyIDOf(t, newKey))
+			}
+
+			wantKeyID := tt.interrupt(t, dir, oldKey, newKey, oldFile)
+
+			reopened := newTestStore(dir)
+			err = reopened.Open()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Open: err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+
+			if got := reopened.KeyID(); got != wantKeyID {
+				t.Errorf("key ID = %s, want %s", got, wantKeyID)
+			}
+			if got := keyIDOf(t, readFile(t, keyPath)); got != wantKeyID {
+				t.Errorf("key file holds %s, want %s", got, wantKeyID)
+			}
+			if _, err := os.Stat(keyPath + ".new"); !os.IsNotExist(err) {
+				t.Errorf("pending key file was left behind: %v", err)
+			}
+			for name, want := range map[string]string{"api-token": "s3cret", "db-password": "hunter2"} {
+				namespace := "billing"
+				if name == "db-password" {
+					namespace = "default"
+				}
+				if value, err := reopened.Resolve(namespace, name); err != nil || value != want {
+					t.Errorf("Resolve %s = %q, %v; want %q", name, value, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenWithoutKeyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	s := openTestStore(t, dir)
+	if _, _, err := s.Put("api-token", "s3cret", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "master.key")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 已有密文时不会静默生成新密钥
+	if err := newTestStore(dir).Open(); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Open without key file: err = %v, want an error", err)
+	}
+}
+
+func TestSwappedCiphertextIsRejected(t *testing.T) {
+	dir := t.TempDir()
+
+	s := openTestStore(t, dir)
+	if _, _, err := s.Put("a", "value-a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Put("b", "value-b", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	s.sealed["a"], s.sealed["b"] = s.sealed["b"], s.sealed["a"]
+	if err := s.saveLocked(s.key, s.sealed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newTestStore(dir).Open(); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Errorf("Open with swapped ciphertexts: err = %v, want a decryption error", err)
+	}
+}
+
+func keyIDOf(t *testing.T, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "key")
+	writeFile(t, path, data)
+	key, err := readKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyID(key)
+}
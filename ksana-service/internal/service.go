@@ -4,10 +4,16 @@ import (
 	"context"
 	"fmt"
 	"ksana-service/internal/api"
+	"ksana-service/internal/audit"
 	"ksana-service/internal/auth"
+	"ksana-service/internal/backup"
 	"ksana-service/internal/executor"
+	"ksana-service/internal/namespace"
+	"ksana-service/internal/revision"
 	"ksana-service/internal/scheduler"
+	"ksana-service/internal/secrets"
 	"ksana-service/internal/store"
+	"ksana-service/internal/trash"
 	"log/slog"
 	"net/http"
 	"os"
@@ -22,6 +28,12 @@ type Service struct {
 	scheduler *scheduler.Scheduler
 	executor  *executor.HTTPExecutor
 	store     store.Store
+	backups   *backup.Manager
+	trash     *trash.Trash
+	audit     *audit.Log
+	secrets   *secrets.Store
+	auth      *auth.Manager
+	jwt       *auth.JWTVerifier
 	logger    *slog.Logger
 }
 
@@ -34,6 +46,36 @@ type Config struct {
 	RetryBackoff   time.Duration
 	LogLevel       string
 	AuthKeysFile   string
+	PublicURL      string
+
+	AuthKeysReloadInterval time.Duration
+
+	JWTJWKSFile        string
+	JWTIssuer          string
+	JWTAudience        string
+	JWTRolesClaim      string
+	JWTNamespacesClaim string
+	JWTRoleScopes      string
+	JWTLeeway          time.Duration
+
+	StoreBackend            string
+	JournalCompactEvery     int
+	JournalSnapshotInterval time.Duration
+
+	StoreCorruptionMode     string
+	StoreGenerations        int
+	StoreGenerationInterval time.Duration
+
+	BackupInterval time.Duration
+	BackupRetain   int
+
+	TrashRetention time.Duration
+
+	AuditRetention time.Duration
+
+	SecretsKeyFile string
+
+	NamespacesFile string
 }
 
 func NewService(config Config) (*Service, error) {
@@ -47,29 +89,91 @@ func NewService(config Config) (*Service, error) {
 		logLevel = slog.LevelError
 	}
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	jsonHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: logLevel,
-	}))
+	})
 
-	store := store.NewJSONStore(config.DataDir)
+	// 所有日志都经过脱敏，密文明文不会出现在日志中
+	secretStore := secrets.New(config.DataDir, config.SecretsKeyFile, slog.New(jsonHandler))
+	logger := slog.New(secrets.NewRedactingHandler(jsonHandler, secretStore))
+
+	if err := store.ValidateCorruptionMode(config.StoreCorruptionMode); err != nil {
+		return nil, err
+	}
+
+	integrity := store.IntegrityConfig{
+		CorruptionMode:     config.StoreCorruptionMode,
+		Generations:        config.StoreGenerations,
+		GenerationInterval: config.StoreGenerationInterval,
+	}
+
+	var jobStore store.Store
+	switch config.StoreBackend {
+	case "json", "":
+		jobStore = store.NewJSONStore(config.DataDir, integrity, logger)
+	case "journal":
+		jobStore = store.NewJournalStore(config.DataDir, config.JournalCompactEvery, config.JournalSnapshotInterval, integrity, logger)
+	default:
+		return nil, fmt.Errorf("unknown store backend: %s", config.StoreBackend)
+	}
+
+	namespaces, err := namespace.Load(config.NamespacesFile, logger)
+	if err != nil {
+		return nil, err
+	}
 
 	executor := executor.NewHTTPExecutor(
 		config.Workers,
 		config.DefaultTimeout,
-		store,
+		config.PublicURL,
+		config.DataDir,
+		jobStore,
+		secretStore,
+		namespaces,
 		logger,
 	)
 
 	clock := &scheduler.RealClock{}
-	schedulerSvc := scheduler.NewScheduler(store, executor, clock, logger)
+	schedulerSvc := scheduler.NewScheduler(jobStore, executor, clock, logger)
 
-	authManager, err := auth.NewManager(config.AuthKeysFile, logger)
+	authManager, err := auth.NewManager(config.AuthKeysFile, config.AuthKeysReloadInterval, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create auth manager: %w", err)
 	}
 
-	handler := api.NewJobHandler(store, schedulerSvc, logger)
-	router := api.NewRouter(handler, authManager, logger)
+	// 未配置 JWKS 文件时只接受 API 密钥
+	var jwtVerifier *auth.JWTVerifier
+	if config.JWTJWKSFile != "" {
+		roleScopes, err := auth.ParseRoleScopes(config.JWTRoleScopes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_ROLE_SCOPES: %w", err)
+		}
+
+		jwtVerifier, err = auth.NewJWTVerifier(auth.JWTConfig{
+			JWKSFile:        config.JWTJWKSFile,
+			Issuer:          config.JWTIssuer,
+			Audience:        config.JWTAudience,
+			RolesClaim:      config.JWTRolesClaim,
+			NamespacesClaim: config.JWTNamespacesClaim,
+			RoleScopes:      roleScopes,
+			Leeway:          config.JWTLeeway,
+			ReloadInterval:  config.AuthKeysReloadInterval,
+		}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create JWT verifier: %w", err)
+		}
+	}
+
+	backups := backup.NewManager(config.DataDir, jobStore, schedulerSvc, config.BackupInterval, config.BackupRetain, logger)
+
+	revisions := revision.NewStore(config.DataDir)
+
+	jobTrash := trash.New(config.DataDir, config.TrashRetention, logger)
+
+	auditLog := audit.New(config.DataDir, config.AuditRetention, logger)
+
+	handler := api.NewJobHandler(jobStore, schedulerSvc, executor, backups, revisions, jobTrash, secretStore, namespaces, authManager, auditLog, config.PublicURL, logger)
+	router := api.NewRouter(handler, authManager, jwtVerifier, logger)
 
 	server := &http.Server{
 		Addr:    ":" + config.Port,
@@ -80,7 +184,13 @@ func NewService(config Config) (*Service, error) {
 		server:    server,
 		scheduler: schedulerSvc,
 		executor:  executor,
-		store:     store,
+		store:     jobStore,
+		backups:   backups,
+		trash:     jobTrash,
+		audit:     auditLog,
+		secrets:   secretStore,
+		auth:      authManager,
+		jwt:       jwtVerifier,
 		logger:    logger,
 	}, nil
 }
@@ -88,14 +198,38 @@ func NewService(config Config) (*Service, error) {
 func (s *Service) Start() error {
 	s.logger.Info("Starting Ksana scheduler service", "port", s.server.Addr)
 
+	if err := s.secrets.Open(); err != nil {
+		return fmt.Errorf("failed to open secrets: %w", err)
+	}
+
+	if err := s.audit.Open(); err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+
 	if _, err := s.store.Load(context.Background()); err != nil {
 		return fmt.Errorf("failed to load store: %w", err)
 	}
 
-	if err := s.scheduler.Start(); err != nil {
+	if err := s.executor.LoadRuns(); err != nil {
+		return fmt.Errorf("failed to load run history: %w", err)
+	}
+
+	// 只读模式下不调度任务，避免基于旧数据执行且无法记录结果
+	if s.store.Status().ReadOnly {
+		s.logger.Warn("Store is read-only after a corruption incident, scheduler not started")
+	} else if err := s.scheduler.Start(); err != nil {
 		return fmt.Errorf("failed to start scheduler: %w", err)
 	}
 
+	s.backups.Start()
+	s.trash.Start()
+	s.audit.Start()
+	s.auth.Start()
+	if s.jwt != nil {
+		s.jwt.Start()
+	}
+	go s.handleReloadSignal()
+
 	go func() {
 		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			s.logger.Error("HTTP server error", "error", err)
@@ -105,6 +239,20 @@ func (s *Service) Start() error {
 	return nil
 }
 
+// handleReloadSignal 收到 SIGHUP 时重新加载 API 密钥文件与 JWKS 文件
+func (s *Service) handleReloadSignal() {
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+
+	for range hupChan {
+		s.logger.Info("Received SIGHUP, reloading API keys")
+		s.auth.Reload()
+		if s.jwt != nil {
+			s.jwt.Reload()
+		}
+	}
+}
+
 func (s *Service) WaitForShutdown() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -120,6 +268,14 @@ func (s *Service) WaitForShutdown() {
 		s.logger.Error("Failed to shutdown HTTP server", "error", err)
 	}
 
+	s.backups.Stop()
+	s.trash.Stop()
+	s.audit.Stop()
+	s.auth.Stop()
+	if s.jwt != nil {
+		s.jwt.Stop()
+	}
+
 	s.logger.Info("Stopping scheduler...")
 	s.scheduler.Stop()
 
@@ -128,6 +284,11 @@ func (s *Service) WaitForShutdown() {
 		s.logger.Error("Failed to shutdown executor", "error", err)
 	}
 
+	s.logger.Info("Closing store...")
+	if err := s.store.Close(); err != nil {
+		s.logger.Error("Failed to close store", "error", err)
+	}
+
 	s.logger.Info("Graceful shutdown completed")
 }
 
@@ -141,6 +302,35 @@ func LoadConfigFromEnv() Config {
 		RetryBackoff:   getEnvDuration("RETRY_BACKOFF", 5*time.Second),
 		LogLevel:       getEnv("LOG_LEVEL", "info"),
 		AuthKeysFile:   getEnv("AUTH_KEYS_FILE", "./config/api_keys.txt"),
+		PublicURL:      getEnv("PUBLIC_URL", ""),
+
+		AuthKeysReloadInterval: getEnvDuration("AUTH_KEYS_RELOAD_INTERVAL", 10*time.Second),
+
+		JWTJWKSFile:        getEnv("JWT_JWKS_FILE", ""),
+		JWTIssuer:          getEnv("JWT_ISSUER", ""),
+		JWTAudience:        getEnv("JWT_AUDIENCE", ""),
+		JWTRolesClaim:      getEnv("JWT_ROLES_CLAIM", "roles"),
+		JWTNamespacesClaim: getEnv("JWT_NAMESPACES_CLAIM", "namespaces"),
+		JWTRoleScopes:      getEnv("JWT_ROLE_SCOPES", ""),
+		JWTLeeway:          getEnvDuration("JWT_LEEWAY", 30*time.Second),
+
+		StoreBackend:            getEnv("STORE_BACKEND", "json"),
+		JournalCompactEvery:     getEnvInt("JOURNAL_COMPACT_EVERY", 1000),
+		JournalSnapshotInterval: getEnvDuration("JOURNAL_SNAPSHOT_INTERVAL", 5*time.Minute),
+
+		StoreCorruptionMode:     getEnv("STORE_CORRUPTION_MODE", store.CorruptionModeFail),
+		StoreGenerations:        getEnvInt("STORE_GENERATIONS", 3),
+		StoreGenerationInterval: getEnvDuration("STORE_GENERATION_INTERVAL", time.Hour),
+
+		BackupInterval: getEnvDuration("BACKUP_INTERVAL", 0),
+		BackupRetain:   getEnvInt("BACKUP_RETAIN", 7),
+
+		TrashRetention: getEnvDuration("TRASH_RETENTION", 7*24*time.Hour),
+
+		AuditRetention: getEnvDuration("AUDIT_RETENTION", 365*24*time.Hour),
+
+		SecretsKeyFile: getEnv("SECRETS_KEY_FILE", "./config/secrets.key"),
+		NamespacesFile: getEnv("NAMESPACES_FILE", "./config/namespaces.json"),
 	}
 
 	return config
@@ -169,4 +359,4 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 		}
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
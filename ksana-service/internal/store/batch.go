@@ -0,0 +1,76 @@
+package store
+
+import (
+	"fmt"
+	"ksana-service/internal/model"
+)
+
+// BatchOp 为批量写入中的一项：Definition 非空时写入定义，否则删除 DeleteID。
+// ExpectedVersion 为调用方读取时的资源版本，批量写入只作用于已存在的任务
+type BatchOp struct {
+	Definition      *model.JobDefinition
+	DeleteID        string
+	ExpectedVersion int64
+}
+
+func (op *BatchOp) id() string {
+	if op.Definition != nil {
+		return op.Definition.ID
+	}
+	return op.DeleteID
+}
+
+// checkBatch 在写入前校验所有版本，同一任务在一批中只能出现一次
+func (m *memState) checkBatch(ops []BatchOp) error {
+	seen := make(map[string]bool, len(ops))
+	for i := range ops {
+		id := ops[i].id()
+		if seen[id] {
+			return fmt.Errorf("job %s appears more than once in batch", id)
+		}
+		seen[id] = true
+
+		if err := m.checkVersion(id, ops[i].ExpectedVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stampBatch 按顺序为批内定义分配连续的资源版本号
+func (m *memState) stampBatch(ops []BatchOp) {
+	version := m.data.ResourceVersion
+	for i := range ops {
+		if ops[i].Definition != nil {
+			version++
+			ops[i].Definition.ResourceVersion = version
+		}
+	}
+}
+
+// applyBatch 将整批变更应用到内存状态，返回待发布的事件；删除事件携带删除前的快照
+func (m *memState) applyBatch(ops []BatchOp) []Event {
+	events := make([]Event, 0, len(ops))
+	for i := range ops {
+		if def := ops[i].Definition; def != nil {
+			m.putDefinition(def)
+			events = append(events, Event{Type: EventUpdated, JobID: def.ID})
+			continue
+		}
+
+		snapshot, _ := m.get(ops[i].DeleteID)
+		m.delete(ops[i].DeleteID)
+		events = append(events, Event{Type: EventDeleted, JobID: ops[i].DeleteID, Job: snapshot})
+	}
+	return events
+}
+
+func (f *Feed) publishBatch(state *memState, events []Event) {
+	for _, event := range events {
+		if event.Type == EventDeleted {
+			f.publish(EventDeleted, event.JobID, event.Job)
+			continue
+		}
+		f.publishJob(event.Type, state, event.JobID)
+	}
+}
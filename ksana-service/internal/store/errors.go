@@ -0,0 +1,8 @@
+package store
+
+import "errors"
+
+var (
+	ErrNotFound = errors.New("job not found")
+	ErrConflict = errors.New("resource version conflict")
+)
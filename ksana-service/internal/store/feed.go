@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"ksana-service/internal/model"
+	"sync"
+	"time"
+)
+
+const (
+	EventCreated      = "created"
+	EventUpdated      = "updated"
+	EventDeleted      = "deleted"
+	EventStateChanged = "state_changed"
+
+	// EventReset 表示存储内容被整体替换（如从备份恢复），订阅方需要重新拉取全量任务
+	EventReset = "reset"
+
+	feedCapacity = 4096
+)
+
+// ErrSequenceOutOfRange 表示请求的序号已不在缓冲区内（过旧或服务已重启），订阅方需要重新拉取全量任务
+var ErrSequenceOutOfRange = errors.New("sequence is out of range, relist required")
+
+type Event struct {
+	Seq   int64      `json:"seq"`
+	Type  string     `json:"type"`
+	JobID string     `json:"job_id,omitempty"`
+	At    time.Time  `json:"at"`
+	Job   *model.Job `json:"job,omitempty"`
+}
+
+// Feed 保存最近的存储变更事件，序号在进程内单调递增；
+// 事件只在变更持久化成功后发布，且在存储锁内分配序号，保证顺序与写入顺序一致
+type Feed struct {
+	epoch string
+
+	mu      sync.Mutex
+	events  []Event
+	lastSeq int64
+	notify  chan struct{}
+}
+
+func newFeed() *Feed {
+	bytes := make([]byte, 8)
+	io.ReadFull(rand.Reader, bytes)
+
+	return &Feed{
+		epoch:  hex.EncodeToString(bytes),
+		notify: make(chan struct{}),
+	}
+}
+
+// Epoch 在每次进程启动时重新生成，订阅方可据此判断序号是否仍然有效
+func (f *Feed) Epoch() string {
+	return f.epoch
+}
+
+func (f *Feed) LastSeq() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastSeq
+}
+
+// Since 返回序号大于 since 的事件（最多 limit 条）以及当前最新序号
+func (f *Feed) Since(since int64, limit int) ([]Event, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if since > f.lastSeq || since < f.lastSeq-int64(len(f.events)) {
+		return nil, f.lastSeq, ErrSequenceOutOfRange
+	}
+
+	start := len(f.events) - int(f.lastSeq-since)
+	end := len(f.events)
+	if limit > 0 && end-start > limit {
+		end = start + limit
+	}
+
+	events := make([]Event, end-start)
+	copy(events, f.events[start:end])
+	return events, f.lastSeq, nil
+}
+
+// Wait 阻塞直到出现序号大于 since 的事件或 ctx 结束
+func (f *Feed) Wait(ctx context.Context, since int64) error {
+	for {
+		f.mu.Lock()
+		if f.lastSeq > since {
+			f.mu.Unlock()
+			return nil
+		}
+		notify := f.notify
+		f.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (f *Feed) publish(eventType, jobID string, job *model.Job) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastSeq++
+	f.events = append(f.events, Event{
+		Seq:   f.lastSeq,
+		Type:  eventType,
+		JobID: jobID,
+		At:    time.Now().UTC(),
+		Job:   job,
+	})
+	// 超过两倍容量时整体裁剪到容量大小，避免每次发布都移动整个缓冲区
+	if len(f.events) >= 2*feedCapacity {
+		f.events = append([]Event(nil), f.events[len(f.events)-feedCapacity:]...)
+	}
+
+	close(f.notify)
+	f.notify = make(chan struct{})
+}
+
+// publishJob 以当前内存状态为快照发布任务事件，调用方需持有存储锁
+func (f *Feed) publishJob(eventType string, state *memState, id string) {
+	job, err := state.get(id)
+	if err != nil {
+		return
+	}
+	f.publish(eventType, id, job)
+}
+
+func putEventType(existed bool) string {
+	if existed {
+		return EventUpdated
+	}
+	return EventCreated
+}
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"ksana-service/internal/metrics"
+	"ksana-service/internal/model"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	CorruptionModeFail     = "fail"
+	CorruptionModeReadOnly = "readonly"
+	CorruptionModeRecover  = "recover"
+)
+
+var (
+	ErrCorrupt  = errors.New("store file is corrupt")
+	ErrReadOnly = errors.New("store is read-only after a corruption incident")
+)
+
+var (
+	corruptionIncidents = metrics.NewCounter("ksana_store_corruption_incidents_total", "Store files that failed to parse on load.")
+	readOnlyGauge       = metrics.NewGauge("ksana_store_read_only", "1 while the store rejects writes after a corruption incident.")
+)
+
+type IntegrityConfig struct {
+	CorruptionMode     string
+	Generations        int
+	GenerationInterval time.Duration
+}
+
+type Incident struct {
+	At            time.Time `json:"at"`
+	Path          string    `json:"path"`
+	Error         string    `json:"error"`
+	Action        string    `json:"action"`
+	RecoveredFrom string    `json:"recovered_from,omitempty"`
+	QuarantinedAs string    `json:"quarantined_as,omitempty"`
+}
+
+type Status struct {
+	ReadOnly bool      `json:"read_only"`
+	Incident *Incident `json:"incident,omitempty"`
+}
+
+// integrityGuard 负责保留最近 N 份可正常解析的存储文件，并在加载失败时按配置处理
+type integrityGuard struct {
+	config IntegrityConfig
+	logger *slog.Logger
+
+	mu           sync.Mutex
+	status       Status
+	lastRotation time.Time
+}
+
+func newIntegrityGuard(config IntegrityConfig, logger *slog.Logger) integrityGuard {
+	if config.CorruptionMode == "" {
+		config.CorruptionMode = CorruptionModeFail
+	}
+	return integrityGuard{config: config, logger: logger}
+}
+
+func ValidateCorruptionMode(mode string) error {
+	switch mode {
+	case CorruptionModeFail, CorruptionModeReadOnly, CorruptionModeRecover:
+		return nil
+	}
+	return fmt.Errorf("unknown corruption mode %q (expected fail, readonly or recover)", mode)
+}
+
+func (g *integrityGuard) Status() Status {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	status := g.status
+	if status.Incident != nil {
+		incident := *status.Incident
+		status.Incident = &incident
+	}
+	return status
+}
+
+func (g *integrityGuard) writable() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.status.ReadOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// quarantine 在只读模式下整体替换存储内容前，将损坏的文件改名为 <文件名>.bad.<时间戳> 保留，避免被覆盖
+func (g *integrityGuard) quarantine() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	incident := g.status.Incident
+	if !g.status.ReadOnly || incident == nil || incident.QuarantinedAs != "" {
+		return nil
+	}
+
+	quarantined := fmt.Sprintf("%s.bad.%s", incident.Path, incident.At.Format("20060102T150405Z"))
+	if err := os.Rename(incident.Path, quarantined); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to quarantine corrupt file: %w", err)
+	}
+	incident.QuarantinedAs = quarantined
+	return nil
+}
+
+// resume 在存储内容被整体替换并落盘后解除只读，事件详情保留在状态中
+func (g *integrityGuard) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.status.ReadOnly {
+		return
+	}
+	g.status.ReadOnly = false
+	readOnlyGauge.Set(0)
+
+	var quarantined string
+	if g.status.Incident != nil {
+		quarantined = g.status.Incident.QuarantinedAs
+	}
+	g.logger.Warn("Store is writable again after restore", "quarantined_as", quarantined)
+}
+
+// handleCorrupt 在存储文件无法解析时调用，返回可继续使用的数据或阻止启动的错误
+func (g *integrityGuard) handleCorrupt(path string, parseErr error) (*model.JobStore, error) {
+	incident := &Incident{
+		At:    time.Now().UTC(),
+		Path:  path,
+		Error: parseErr.Error(),
+	}
+	corruptionIncidents.Inc()
+
+	var jobStore *model.JobStore
+	var data []byte
+	if g.config.CorruptionMode != CorruptionModeFail {
+		jobStore, data, incident.RecoveredFrom = g.newestGeneration(path)
+	}
+
+	var err error
+	switch g.config.CorruptionMode {
+	case CorruptionModeReadOnly:
+		incident.Action = CorruptionModeReadOnly
+		if jobStore == nil {
+			jobStore = newEmptyJobStore()
+		}
+	case CorruptionModeRecover:
+		if jobStore == nil {
+			incident.Action = CorruptionModeFail
+			err = fmt.Errorf("%w: %s: %v; no known-good generation to recover from", ErrCorrupt, path, parseErr)
+			break
+		}
+
+		incident.Action = "recovered"
+		incident.QuarantinedAs = fmt.Sprintf("%s.bad.%s", path, incident.At.Format("20060102T150405Z"))
+		if renameErr := os.Rename(path, incident.QuarantinedAs); renameErr != nil {
+			err = fmt.Errorf("failed to quarantine corrupt file: %w", renameErr)
+		} else if writeErr := WriteFileAtomic(path, data); writeErr != nil {
+			err = fmt.Errorf("failed to restore %s from %s: %w", path, incident.RecoveredFrom, writeErr)
+		}
+	default:
+		incident.Action = CorruptionModeFail
+		err = fmt.Errorf("%w: %s: %v", ErrCorrupt, path, parseErr)
+	}
+
+	g.mu.Lock()
+	g.status.Incident = incident
+	g.status.ReadOnly = incident.Action == CorruptionModeReadOnly
+	g.mu.Unlock()
+	if incident.Action == CorruptionModeReadOnly {
+		readOnlyGauge.Set(1)
+	}
+
+	g.logger.Error("Store file is corrupt",
+		"path", path,
+		"error", parseErr,
+		"action", incident.Action,
+		"recovered_from", incident.RecoveredFrom,
+		"quarantined_as", incident.QuarantinedAs)
+
+	if err != nil {
+		return nil, err
+	}
+	return jobStore, nil
+}
+
+func (g *integrityGuard) newestGeneration(path string) (*model.JobStore, []byte, string) {
+	for i := 1; i <= g.config.Generations; i++ {
+		genPath := generationPath(path, i)
+		data, err := os.ReadFile(genPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			g.logger.Warn("Failed to read store generation", "path", genPath, "error", err)
+			continue
+		}
+
+		jobStore, _, err := decodeJobStore(data)
+		if err != nil {
+			g.logger.Warn("Skipping unreadable store generation", "path", genPath, "error", err)
+			continue
+		}
+		return jobStore, data, genPath
+	}
+	return nil, nil, ""
+}
+
+// rotate 将刚确认可解析的内容保存为最新一代，同一间隔内只轮转一次
+func (g *integrityGuard) rotate(path string, data []byte) {
+	if g.config.Generations <= 0 {
+		return
+	}
+
+	g.mu.Lock()
+	if !g.lastRotation.IsZero() && time.Since(g.lastRotation) < g.config.GenerationInterval {
+		g.mu.Unlock()
+		return
+	}
+	g.lastRotation = time.Now()
+	g.mu.Unlock()
+
+	for i := g.config.Generations - 1; i >= 1; i-- {
+		if err := os.Rename(generationPath(path, i), generationPath(path, i+1)); err != nil && !os.IsNotExist(err) {
+			g.logger.Warn("Failed to rotate store generation", "path", generationPath(path, i), "error", err)
+		}
+	}
+
+	if err := WriteFileAtomic(generationPath(path, 1), data); err != nil {
+		g.logger.Warn("Failed to write store generation", "path", path, "error", err)
+	}
+}
+
+func generationPath(path string, generation int) string {
+	return fmt.Sprintf("%s.gen%d", path, generation)
+}
@@ -0,0 +1,637 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"ksana-service/internal/labels"
+	"ksana-service/internal/model"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	journalOpPut        = "put"
+	journalOpDefinition = "definition"
+	journalOpState      = "state"
+	journalOpDelete     = "delete"
+	// journalOpBatch 将多条定义写入与删除合并为一条记录，重放时整体生效或整体被截断
+	journalOpBatch = "batch"
+
+	journalHeaderSize = 8
+	maxJournalRecord  = 16 << 20
+)
+
+type journalEntry struct {
+	Op         string               `json:"op"`
+	ID         string               `json:"id,omitempty"`
+	Job        *model.Job           `json:"job,omitempty"`
+	Definition *model.JobDefinition `json:"definition,omitempty"`
+	State      *model.JobState      `json:"state,omitempty"`
+	Ops        []journalEntry       `json:"ops,omitempty"`
+}
+
+type JournalStore struct {
+	dataDir          string
+	compactEvery     int
+	snapshotInterval time.Duration
+	logger           *slog.Logger
+	guard            integrityGuard
+
+	mu      sync.RWMutex
+	state   memState
+	journal *os.File
+	entries int
+	feed    *Feed
+
+	compactCh chan struct{}
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+}
+
+func NewJournalStore(dataDir string, compactEvery int, snapshotInterval time.Duration, integrity IntegrityConfig, logger *slog.Logger) *JournalStore {
+	if compactEvery <= 0 {
+		compactEvery = 1000
+	}
+
+	return &JournalStore{
+		dataDir:          dataDir,
+		compactEvery:     compactEvery,
+		snapshotInterval: snapshotInterval,
+		logger:           logger,
+		guard:            newIntegrityGuard(integrity, logger),
+		compactCh:        make(chan struct{}, 1),
+		feed:             newFeed(),
+	}
+}
+
+func (s *JournalStore) Load(ctx context.Context) (*model.JobStore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	jobStore, migration, err := s.readSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	s.state.reset(jobStore)
+
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+
+	replayed, err := s.replayJournal()
+	if err != nil {
+		return nil, err
+	}
+	s.entries = replayed
+
+	s.journal, err = os.OpenFile(s.journalPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	if migration.Migrated() {
+		if err := s.compactLocked(); err != nil {
+			return nil, fmt.Errorf("failed to write migrated snapshot: %w", err)
+		}
+		s.logger.Info("Migrated journal snapshot",
+			"from_version", migration.FromVersion,
+			"to_version", migration.ToVersion,
+			"backup", migration.BackupPath)
+	}
+
+	s.logger.Info("Loaded journal store",
+		"jobs", len(s.state.data.Jobs),
+		"replayed_entries", replayed)
+
+	if s.stopCh == nil {
+		s.stopCh = make(chan struct{})
+		s.wg.Add(1)
+		go s.compactionLoop()
+	}
+
+	return s.state.data, nil
+}
+
+func (s *JournalStore) Save(ctx context.Context, jobStore *model.JobStore) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		return fmt.Errorf("store not loaded")
+	}
+
+	if err := s.guard.quarantine(); err != nil {
+		return err
+	}
+
+	jobStore.UpdatedAt = time.Now().UTC()
+	s.state.reset(jobStore)
+
+	if err := s.compactLocked(); err != nil {
+		return err
+	}
+	s.guard.resume()
+
+	s.feed.publish(EventReset, "", nil)
+	return nil
+}
+
+func (s *JournalStore) Export(ctx context.Context) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.state.export()
+}
+
+func (s *JournalStore) List(ctx context.Context) ([]model.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.state.list()
+}
+
+func (s *JournalStore) ListBySelector(ctx context.Context, selector labels.Selector) ([]model.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.state.listSelected(selector)
+}
+
+func (s *JournalStore) ListDefinitions(ctx context.Context) ([]model.JobDefinition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.state.listDefinitions()
+}
+
+func (s *JournalStore) Get(ctx context.Context, id string) (*model.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.state.get(id)
+}
+
+func (s *JournalStore) Put(ctx context.Context, job *model.Job) error {
+	if err := s.guard.writable(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job.ID == "" {
+		job.ID = generateID()
+	}
+	s.state.stamp(&job.JobDefinition)
+
+	_, existed := s.state.defMap[job.ID]
+
+	jobCopy := *job
+	if err := s.appendEntry(journalEntry{Op: journalOpPut, Job: &jobCopy}); err != nil {
+		return err
+	}
+
+	if err := s.state.put(job); err != nil {
+		return err
+	}
+
+	s.feed.publishJob(putEventType(existed), &s.state, job.ID)
+	return nil
+}
+
+func (s *JournalStore) PutDefinition(ctx context.Context, def *model.JobDefinition) error {
+	if err := s.guard.writable(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.putDefinitionLocked(def)
+}
+
+func (s *JournalStore) CompareAndPutDefinition(ctx context.Context, def *model.JobDefinition, expectedVersion int64) error {
+	if err := s.guard.writable(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.state.checkVersion(def.ID, expectedVersion); err != nil {
+		return err
+	}
+
+	return s.putDefinitionLocked(def)
+}
+
+func (s *JournalStore) putDefinitionLocked(def *model.JobDefinition) error {
+	if def.ID == "" {
+		def.ID = generateID()
+	}
+	s.state.stamp(def)
+
+	_, existed := s.state.defMap[def.ID]
+
+	defCopy := *def
+	if err := s.appendEntry(journalEntry{Op: journalOpDefinition, Definition: &defCopy}); err != nil {
+		return err
+	}
+
+	if err := s.state.putDefinition(def); err != nil {
+		return err
+	}
+
+	s.feed.publishJob(putEventType(existed), &s.state, def.ID)
+	return nil
+}
+
+func (s *JournalStore) UpdateState(ctx context.Context, id string, update func(*model.JobState)) (*model.JobState, error) {
+	if err := s.guard.writable(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.state.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	state := current.JobState
+	update(&state)
+
+	if err := s.appendEntry(journalEntry{Op: journalOpState, ID: id, State: &state}); err != nil {
+		return nil, err
+	}
+
+	s.state.setState(id, state)
+	s.feed.publishJob(EventStateChanged, &s.state, id)
+	return &state, nil
+}
+
+func (s *JournalStore) Delete(ctx context.Context, id string) error {
+	if err := s.guard.writable(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.state.get(id); err != nil {
+		return err
+	}
+
+	return s.deleteLocked(id)
+}
+
+func (s *JournalStore) CompareAndDelete(ctx context.Context, id string, expectedVersion int64) error {
+	if err := s.guard.writable(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.state.checkVersion(id, expectedVersion); err != nil {
+		return err
+	}
+
+	return s.deleteLocked(id)
+}
+
+func (s *JournalStore) Batch(ctx context.Context, ops []BatchOp) error {
+	if err := s.guard.writable(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.state.checkBatch(ops); err != nil {
+		return err
+	}
+
+	s.state.stampBatch(ops)
+
+	entry := journalEntry{Op: journalOpBatch, Ops: make([]journalEntry, 0, len(ops))}
+	for _, op := range ops {
+		if op.Definition != nil {
+			defCopy := *op.Definition
+			entry.Ops = append(entry.Ops, journalEntry{Op: journalOpDefinition, Definition: &defCopy})
+		} else {
+			entry.Ops = append(entry.Ops, journalEntry{Op: journalOpDelete, ID: op.DeleteID})
+		}
+	}
+	if err := s.appendEntry(entry); err != nil {
+		return err
+	}
+
+	s.feed.publishBatch(&s.state, s.state.applyBatch(ops))
+	return nil
+}
+
+func (s *JournalStore) deleteLocked(id string) error {
+	// 删除事件携带删除前的最后状态
+	snapshot, _ := s.state.get(id)
+
+	if err := s.appendEntry(journalEntry{Op: journalOpDelete, ID: id}); err != nil {
+		return err
+	}
+
+	if err := s.state.delete(id); err != nil {
+		return err
+	}
+
+	s.feed.publish(EventDeleted, id, snapshot)
+	return nil
+}
+
+func (s *JournalStore) Status() Status {
+	return s.guard.Status()
+}
+
+func (s *JournalStore) Feed() *Feed {
+	return s.feed
+}
+
+func (s *JournalStore) Close() error {
+	if s.stopCh != nil {
+		close(s.stopCh)
+		s.wg.Wait()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		return nil
+	}
+
+	// 只读模式下不压缩，损坏的快照与日志原样保留，等待从备份恢复
+	var err error
+	if s.entries > 0 && s.guard.writable() == nil {
+		err = s.compactLocked()
+	}
+
+	if closeErr := s.journal.Close(); err == nil {
+		err = closeErr
+	}
+	s.journal = nil
+	return err
+}
+
+func (s *JournalStore) appendEntry(entry journalEntry) error {
+	if s.journal == nil {
+		return fmt.Errorf("store not loaded")
+	}
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+
+	record := make([]byte, journalHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[journalHeaderSize:], payload)
+
+	if _, err := s.journal.Write(record); err != nil {
+		return fmt.Errorf("failed to append journal entry: %w", err)
+	}
+
+	if err := s.journal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+
+	s.entries++
+	if s.entries >= s.compactEvery {
+		select {
+		case s.compactCh <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (s *JournalStore) replayJournal() (int, error) {
+	file, err := os.OpenFile(s.journalPath(), os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	replayed := 0
+
+	for {
+		entry, size, err := readJournalRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.logger.Warn("Truncating torn journal tail",
+				"offset", offset,
+				"error", err)
+			if err := file.Truncate(offset); err != nil {
+				return replayed, fmt.Errorf("failed to truncate journal: %w", err)
+			}
+			if err := file.Sync(); err != nil {
+				return replayed, fmt.Errorf("failed to sync journal: %w", err)
+			}
+			break
+		}
+
+		s.replayEntry(entry)
+
+		offset += size
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+func (s *JournalStore) replayEntry(entry *journalEntry) {
+	switch entry.Op {
+	case journalOpPut:
+		if entry.Job != nil {
+			s.state.put(entry.Job)
+		}
+	case journalOpDefinition:
+		if entry.Definition != nil {
+			s.state.putDefinition(entry.Definition)
+		}
+	case journalOpState:
+		if entry.State != nil {
+			s.state.setState(entry.ID, *entry.State)
+		}
+	case journalOpDelete:
+		s.state.delete(entry.ID)
+	case journalOpBatch:
+		for i := range entry.Ops {
+			s.replayEntry(&entry.Ops[i])
+		}
+	}
+}
+
+func readJournalRecord(reader io.Reader) (*journalEntry, int64, error) {
+	header := make([]byte, journalHeaderSize)
+	n, err := io.ReadFull(reader, header)
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("short record header (%d bytes)", n)
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	if length == 0 || length > maxJournalRecord {
+		return nil, 0, fmt.Errorf("invalid record length %d", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, 0, errors.New("short record payload")
+	}
+
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, 0, errors.New("record checksum mismatch")
+	}
+
+	var entry journalEntry
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return nil, 0, fmt.Errorf("invalid record payload: %w", err)
+	}
+
+	return &entry, int64(journalHeaderSize) + int64(length), nil
+}
+
+func (s *JournalStore) readSnapshot() (*model.JobStore, *MigrationResult, error) {
+	path := s.snapshotPath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// 首次切换到日志存储时沿用已有的 jobs.json
+		path = filepath.Join(s.dataDir, "jobs.json")
+		data, err = os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return newEmptyJobStore(), &MigrationResult{}, nil
+		}
+		if err == nil {
+			s.logger.Info("Bootstrapping journal store from jobs.json")
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	jobStore, migration, err := decodeJobStore(data)
+	if errors.Is(err, ErrUnsupportedVersion) {
+		return nil, nil, err
+	}
+	if err != nil {
+		recovered, err := s.guard.handleCorrupt(path, err)
+		if err != nil {
+			return nil, nil, err
+		}
+		return recovered, &MigrationResult{}, nil
+	}
+
+	if migration.Migrated() {
+		if err := backupBeforeMigration(path, data, migration); err != nil {
+			return nil, nil, err
+		}
+	} else if path == s.snapshotPath() {
+		s.guard.rotate(path, data)
+	}
+
+	return jobStore, migration, nil
+}
+
+func (s *JournalStore) compactionLoop() {
+	defer s.wg.Done()
+
+	var tick <-chan time.Time
+	if s.snapshotInterval > 0 {
+		ticker := time.NewTicker(s.snapshotInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-s.compactCh:
+		case <-tick:
+		}
+
+		s.mu.Lock()
+		if s.journal != nil && s.entries > 0 && s.guard.writable() == nil {
+			if err := s.compactLocked(); err != nil {
+				s.logger.Error("Journal compaction failed", "error", err)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *JournalStore) compactLocked() error {
+	start := time.Now()
+	entries := s.entries
+
+	s.state.data.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(s.state.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	if err := WriteFileAtomic(s.snapshotPath(), data); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	s.guard.rotate(s.snapshotPath(), data)
+
+	// 快照落盘后日志中的记录已全部包含在快照内，重放是幂等的，因此截断前崩溃也不会丢数据
+	if err := s.journal.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate journal: %w", err)
+	}
+	if err := s.journal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	s.entries = 0
+
+	s.logger.Info("Compacted journal",
+		"entries", entries,
+		"jobs", len(s.state.data.Jobs),
+		"latency_ms", time.Since(start).Milliseconds())
+
+	return nil
+}
+
+func (s *JournalStore) snapshotPath() string {
+	return filepath.Join(s.dataDir, "snapshot.json")
+}
+
+func (s *JournalStore) journalPath() string {
+	return filepath.Join(s.dataDir, "journal.log")
+}
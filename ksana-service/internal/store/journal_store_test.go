@@ -0,0 +1,264 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"ksana-service/internal/model"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestJournalStore(t *testing.T, dir string, integrity IntegrityConfig) *JournalStore {
+	t.Helper()
+
+	s := NewJournalStore(dir, 1000, 0, integrity, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if _, err := s.Load(context.Background()); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return s
+}
+
+// crash 模拟进程退出：停止后台压缩并关闭日志文件，不写快照
+func crash(t *testing.T, s *JournalStore) {
+	t.Helper()
+
+	close(s.stopCh)
+	s.wg.Wait()
+	s.stopCh = nil
+	if err := s.journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.journal = nil
+}
+
+func putTestJob(t *testing.T, s *JournalStore, id, name string) {
+	t.Helper()
+
+	job := &model.Job{JobDefinition: model.JobDefinition{ID: id, Name: name, Namespace: model.DefaultNamespace}}
+	if err := s.Put(context.Background(), job); err != nil {
+		t.Fatalf("Put %s: %v", id, err)
+	}
+}
+
+func jobNames(t *testing.T, s *JournalStore) map[string]string {
+	t.Helper()
+
+	jobs, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]string, len(jobs))
+	for _, job := range jobs {
+		names[job.ID] = job.Name
+	}
+	return names
+}
+
+func TestJournalReplay(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	s := newTestJournalStore(t, dir, IntegrityConfig{})
+	putTestJob(t, s, "a", "first")
+	putTestJob(t, s, "b", "second")
+	putTestJob(t, s, "c", "third")
+
+	def, err := s.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	def.Name = "renamed"
+	if err := s.PutDefinition(ctx, &def.JobDefinition); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateState(ctx, "b", func(state *model.JobState) { state.LastStatus = model.JobStatusSuccess }); err != nil {
+		t.Fatal(err)
+	}
+	c, err := s.Get(ctx, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Batch(ctx, []BatchOp{{DeleteID: "c", ExpectedVersion: c.ResourceVersion}}); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, s)
+
+	reopened := newTestJournalStore(t, dir, IntegrityConfig{})
+	defer reopened.Close()
+
+	if reopened.entries != 6 {
+		t.Errorf("replayed %d entries, want 6", reopened.entries)
+	}
+	want := map[string]string{"a": "renamed", "b": "second"}
+	if got := jobNames(t, reopened); !equalNames(got, want) {
+		t.Errorf("jobs after replay = %v, want %v", got, want)
+	}
+	if job, _ := reopened.Get(ctx, "b"); job == nil || job.LastStatus != model.JobStatusSuccess {
+		t.Errorf("state of b was not replayed: %+v", job)
+	}
+}
+
+func TestJournalTruncatesTornTail(t *testing.T) {
+	validPayload := []byte(`{"op":"put","job":{"id":"x","name":"torn"}}`)
+
+	tests := []struct {
+		name string
+		tail []byte
+	}{
+		{name: "short header", tail: []byte{0, 0, 0}},
+		{name: "zero length", tail: testJournalRecord(nil, 0)},
+		{name: "length over limit", tail: testJournalHeader(maxJournalRecord+1, 0)},
+		{name: "short payload", tail: append(testJournalHeader(uint32(len(validPayload)), crc32.ChecksumIEEE(validPayload)), validPayload[:10]...)},
+		{name: "checksum mismatch", tail: testJournalRecord(validPayload, crc32.ChecksumIEEE(validPayload)+1)},
+		{name: "payload not json", tail: testJournalRecord([]byte("{not json"), crc32.ChecksumIEEE([]byte("{not json")))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			s := newTestJournalStore(t, dir, IntegrityConfig{})
+			putTestJob(t, s, "a", "first")
+			putTestJob(t, s, "b", "second")
+			crash(t, s)
+
+			journalPath := filepath.Join(dir, "journal.log")
+			valid, err := os.ReadFile(journalPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(journalPath, append(append([]byte(nil), valid...), tt.tail...), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			s = newTestJournalStore(t, dir, IntegrityConfig{})
+			if s.entries != 2 {
+				t.Errorf("replayed %d entries, want 2", s.entries)
+			}
+			if data, _ := os.ReadFile(journalPath); !bytes.Equal(data, valid) {
+				t.Errorf("journal is %d bytes after load, want the %d valid bytes", len(data), len(valid))
+			}
+
+			// 截断后继续追加的记录在下次加载时可以正常重放
+			putTestJob(t, s, "c", "third")
+			crash(t, s)
+
+			s = newTestJournalStore(t, dir, IntegrityConfig{})
+			defer s.Close()
+			want := map[string]string{"a": "first", "b": "second", "c": "third"}
+			if got := jobNames(t, s); !equalNames(got, want) {
+				t.Errorf("jobs = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestJournalCloseCompacts(t *testing.T) {
+	dir := t.TempDir()
+
+	s := newTestJournalStore(t, dir, IntegrityConfig{})
+	putTestJob(t, s, "a", "first")
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if info, err := os.Stat(filepath.Join(dir, "journal.log")); err != nil || info.Size() != 0 {
+		t.Fatalf("journal after close: %v, %v; want an empty file", info, err)
+	}
+
+	s = newTestJournalStore(t, dir, IntegrityConfig{})
+	defer s.Close()
+	if s.entries != 0 {
+		t.Errorf("replayed %d entries after compaction, want 0", s.entries)
+	}
+	if got := jobNames(t, s); !equalNames(got, map[string]string{"a": "first"}) {
+		t.Errorf("jobs from snapshot = %v", got)
+	}
+}
+
+func TestJournalReadOnlyKeepsFiles(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	s := newTestJournalStore(t, dir, IntegrityConfig{})
+	putTestJob(t, s, "a", "first")
+	crash(t, s)
+
+	snapshotPath := filepath.Join(dir, "snapshot.json")
+	journalPath := filepath.Join(dir, "journal.log")
+	corrupt := []byte("{broken")
+	if err := os.WriteFile(snapshotPath, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+	journal, err := os.ReadFile(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s = newTestJournalStore(t, dir, IntegrityConfig{CorruptionMode: CorruptionModeReadOnly})
+	if !s.Status().ReadOnly {
+		t.Fatal("store is not read-only after loading a corrupt snapshot")
+	}
+	if err := s.Put(ctx, &model.Job{JobDefinition: model.JobDefinition{ID: "b"}}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Put in read-only mode: err = %v, want ErrReadOnly", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// 只读模式下关闭不压缩：损坏的快照与日志原样保留
+	if data, _ := os.ReadFile(snapshotPath); !bytes.Equal(data, corrupt) {
+		t.Errorf("snapshot was rewritten in read-only mode: %q", data)
+	}
+	if data, _ := os.ReadFile(journalPath); !bytes.Equal(data, journal) {
+		t.Errorf("journal changed in read-only mode: %d bytes, want %d", len(data), len(journal))
+	}
+
+	// 从备份恢复后隔离损坏的快照并解除只读
+	s = newTestJournalStore(t, dir, IntegrityConfig{CorruptionMode: CorruptionModeReadOnly})
+	defer s.Close()
+	restored := newEmptyJobStore()
+	restored.Jobs = []model.JobDefinition{{ID: "r", Name: "restored", Namespace: model.DefaultNamespace}}
+	if err := s.Save(ctx, restored); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if s.Status().ReadOnly {
+		t.Error("store is still read-only after restore")
+	}
+	quarantined := s.Status().Incident.QuarantinedAs
+	if data, err := os.ReadFile(quarantined); err != nil || !bytes.Equal(data, corrupt) {
+		t.Errorf("quarantined file %q: %q, %v", quarantined, data, err)
+	}
+	if got := jobNames(t, s); !equalNames(got, map[string]string{"r": "restored"}) {
+		t.Errorf("jobs after restore = %v", got)
+	}
+}
+
+func testJournalHeader(length, checksum uint32) []byte {
+	h := make([]byte, journalHeaderSize)
+	binary.BigEndian.PutUint32(h[0:4], length)
+	binary.BigEndian.PutUint32(h[4:8], checksum)
+	return h
+}
+
+func testJournalRecord(payload []byte, checksum uint32) []byte {
+	return append(testJournalHeader(uint32(len(payload)), checksum), payload...)
+}
+
+func equalNames(got, want map[string]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for id, name := range want {
+		if got[id] != name {
+			return false
+		}
+	}
+	return true
+}
@@ -2,12 +2,12 @@ package store
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"ksana-service/internal/labels"
 	"ksana-service/internal/model"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
@@ -16,16 +16,19 @@ import (
 
 type JSONStore struct {
 	dataDir string
+	logger  *slog.Logger
+	guard   integrityGuard
 	mu      sync.RWMutex
-	data    *model.JobStore
-	jobMap  map[string]*model.Job
+	state   memState
+	feed    *Feed
 }
 
-func NewJSONStore(dataDir string) *JSONStore {
+func NewJSONStore(dataDir string, integrity IntegrityConfig, logger *slog.Logger) *JSONStore {
 	return &JSONStore{
 		dataDir: dataDir,
-		data:    nil,
-		jobMap:  make(map[string]*model.Job),
+		logger:  logger,
+		guard:   newIntegrityGuard(integrity, logger),
+		feed:    newFeed(),
 	}
 }
 
@@ -41,133 +44,271 @@ func (s *JSONStore) Load(ctx context.Context) (*model.JobStore, error) {
 
 	data, err := os.ReadFile(filePath)
 	if os.IsNotExist(err) {
-		s.data = &model.JobStore{
-			Version:   1,
-			UpdatedAt: time.Now().UTC(),
-			Jobs:      []model.Job{},
-		}
-		s.jobMap = make(map[string]*model.Job)
-		return s.data, nil
+		s.state.reset(newEmptyJobStore())
+		return s.state.data, nil
 	}
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to read jobs file: %w", err)
 	}
 
-	var jobStore model.JobStore
-	if err := json.Unmarshal(data, &jobStore); err != nil {
-		backupPath := filepath.Join(s.dataDir, fmt.Sprintf("jobs.bad.%d.json", time.Now().Unix()))
-		os.Rename(filePath, backupPath)
+	jobStore, migration, err := decodeJobStore(data)
+	if errors.Is(err, ErrUnsupportedVersion) {
+		return nil, err
+	}
+	if err != nil {
+		recovered, err := s.guard.handleCorrupt(filePath, err)
+		if err != nil {
+			return nil, err
+		}
+		s.state.reset(recovered)
+		return s.state.data, nil
+	}
+
+	s.state.reset(jobStore)
 
-		s.data = &model.JobStore{
-			Version:   1,
-			UpdatedAt: time.Now().UTC(),
-			Jobs:      []model.Job{},
+	if migration.Migrated() {
+		if err := backupBeforeMigration(filePath, data, migration); err != nil {
+			return nil, err
+		}
+		if err := s.atomicWrite(jobStore); err != nil {
+			return nil, fmt.Errorf("failed to write migrated jobs file: %w", err)
 		}
-		s.jobMap = make(map[string]*model.Job)
-		return s.data, nil
+		s.logger.Info("Migrated jobs file",
+			"from_version", migration.FromVersion,
+			"to_version", migration.ToVersion,
+			"backup", migration.BackupPath)
+	} else {
+		s.guard.rotate(filePath, data)
 	}
 
-	s.data = &jobStore
-	s.rebuildJobMap()
-	return s.data, nil
+	return s.state.data, nil
 }
 
 func (s *JSONStore) Save(ctx context.Context, jobStore *model.JobStore) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.guard.quarantine(); err != nil {
+		return err
+	}
+
 	jobStore.UpdatedAt = time.Now().UTC()
-	s.data = jobStore
-	s.rebuildJobMap()
+	s.state.reset(jobStore)
 
-	return s.atomicWrite(jobStore)
+	if err := s.atomicWrite(jobStore); err != nil {
+		return err
+	}
+	s.guard.resume()
+
+	s.feed.publish(EventReset, "", nil)
+	return nil
+}
+
+func (s *JSONStore) Export(ctx context.Context) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.state.export()
 }
 
 func (s *JSONStore) List(ctx context.Context) ([]model.Job, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if s.data == nil {
-		return nil, fmt.Errorf("store not loaded")
-	}
+	return s.state.list()
+}
 
-	jobs := make([]model.Job, len(s.data.Jobs))
-	copy(jobs, s.data.Jobs)
-	return jobs, nil
+func (s *JSONStore) ListBySelector(ctx context.Context, selector labels.Selector) ([]model.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.state.listSelected(selector)
+}
+
+func (s *JSONStore) ListDefinitions(ctx context.Context) ([]model.JobDefinition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.state.listDefinitions()
 }
 
 func (s *JSONStore) Get(ctx context.Context, id string) (*model.Job, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	job, exists := s.jobMap[id]
-	if !exists {
-		return nil, fmt.Errorf("job not found: %s", id)
+	return s.state.get(id)
+}
+
+func (s *JSONStore) Put(ctx context.Context, job *model.Job) error {
+	if err := s.guard.writable(); err != nil {
+		return err
 	}
 
-	jobCopy := *job
-	return &jobCopy, nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, existed := s.state.defMap[job.ID]
+	s.state.stamp(&job.JobDefinition)
+	if err := s.state.put(job); err != nil {
+		return err
+	}
+
+	if err := s.atomicWrite(s.state.data); err != nil {
+		return err
+	}
+
+	s.feed.publishJob(putEventType(existed), &s.state, job.ID)
+	return nil
 }
 
-func (s *JSONStore) Put(ctx context.Context, job *model.Job) error {
+func (s *JSONStore) PutDefinition(ctx context.Context, def *model.JobDefinition) error {
+	if err := s.guard.writable(); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.data == nil {
-		return fmt.Errorf("store not loaded")
+	return s.putDefinitionLocked(def)
+}
+
+func (s *JSONStore) CompareAndPutDefinition(ctx context.Context, def *model.JobDefinition, expectedVersion int64) error {
+	if err := s.guard.writable(); err != nil {
+		return err
 	}
 
-	if job.ID == "" {
-		job.ID = s.generateID()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.state.checkVersion(def.ID, expectedVersion); err != nil {
+		return err
 	}
 
-	_, exists := s.jobMap[job.ID]
-	if exists {
-		for i, j := range s.data.Jobs {
-			if j.ID == job.ID {
-				s.data.Jobs[i] = *job
-				break
-			}
-		}
-	} else {
-		s.data.Jobs = append(s.data.Jobs, *job)
+	return s.putDefinitionLocked(def)
+}
+
+func (s *JSONStore) putDefinitionLocked(def *model.JobDefinition) error {
+	_, existed := s.state.defMap[def.ID]
+	s.state.stamp(def)
+	if err := s.state.putDefinition(def); err != nil {
+		return err
+	}
+
+	if err := s.atomicWrite(s.state.data); err != nil {
+		return err
 	}
 
-	s.rebuildJobMap()
-	return s.atomicWrite(s.data)
+	s.feed.publishJob(putEventType(existed), &s.state, def.ID)
+	return nil
+}
+
+func (s *JSONStore) UpdateState(ctx context.Context, id string, update func(*model.JobState)) (*model.JobState, error) {
+	if err := s.guard.writable(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := s.state.updateState(id, update)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.atomicWrite(s.state.data); err != nil {
+		return &state, err
+	}
+
+	s.feed.publishJob(EventStateChanged, &s.state, id)
+	return &state, nil
 }
 
 func (s *JSONStore) Delete(ctx context.Context, id string) error {
+	if err := s.guard.writable(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteLocked(id)
+}
+
+func (s *JSONStore) CompareAndDelete(ctx context.Context, id string, expectedVersion int64) error {
+	if err := s.guard.writable(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.state.checkVersion(id, expectedVersion); err != nil {
+		return err
+	}
+
+	return s.deleteLocked(id)
+}
+
+func (s *JSONStore) Batch(ctx context.Context, ops []BatchOp) error {
+	if err := s.guard.writable(); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.data == nil {
-		return fmt.Errorf("store not loaded")
+	if err := s.state.checkBatch(ops); err != nil {
+		return err
 	}
 
-	_, exists := s.jobMap[id]
-	if !exists {
-		return fmt.Errorf("job not found: %s", id)
+	// 落盘失败时从备份恢复内存状态，保证整批要么全部生效要么全部不生效
+	backup, err := s.state.export()
+	if err != nil {
+		return err
 	}
 
-	var newJobs []model.Job
-	for _, job := range s.data.Jobs {
-		if job.ID != id {
-			newJobs = append(newJobs, job)
+	s.state.stampBatch(ops)
+	events := s.state.applyBatch(ops)
+
+	if err := s.atomicWrite(s.state.data); err != nil {
+		var restored model.JobStore
+		if jsonErr := json.Unmarshal(backup, &restored); jsonErr == nil {
+			s.state.reset(&restored)
 		}
+		return err
 	}
 
-	s.data.Jobs = newJobs
-	s.rebuildJobMap()
-	return s.atomicWrite(s.data)
+	s.feed.publishBatch(&s.state, events)
+	return nil
 }
 
-func (s *JSONStore) rebuildJobMap() {
-	s.jobMap = make(map[string]*model.Job)
-	for i := range s.data.Jobs {
-		s.jobMap[s.data.Jobs[i].ID] = &s.data.Jobs[i]
+func (s *JSONStore) deleteLocked(id string) error {
+	// 删除事件携带删除前的最后状态
+	snapshot, _ := s.state.get(id)
+
+	if err := s.state.delete(id); err != nil {
+		return err
+	}
+
+	if err := s.atomicWrite(s.state.data); err != nil {
+		return err
 	}
+
+	s.feed.publish(EventDeleted, id, snapshot)
+	return nil
+}
+
+func (s *JSONStore) Status() Status {
+	return s.guard.Status()
+}
+
+func (s *JSONStore) Feed() *Feed {
+	return s.feed
+}
+
+func (s *JSONStore) Close() error {
+	return nil
 }
 
 func (s *JSONStore) atomicWrite(jobStore *model.JobStore) error {
@@ -177,6 +318,15 @@ func (s *JSONStore) atomicWrite(jobStore *model.JobStore) error {
 	}
 
 	filePath := filepath.Join(s.dataDir, "jobs.json")
+	if err := WriteFileAtomic(filePath, data); err != nil {
+		return err
+	}
+
+	s.guard.rotate(filePath, data)
+	return nil
+}
+
+func WriteFileAtomic(filePath string, data []byte) error {
 	tmpPath := filePath + ".tmp"
 
 	tmpFile, err := os.Create(tmpPath)
@@ -206,9 +356,3 @@ func (s *JSONStore) atomicWrite(jobStore *model.JobStore) error {
 
 	return nil
 }
-
-func (s *JSONStore) generateID() string {
-	bytes := make([]byte, 16)
-	io.ReadFull(rand.Reader, bytes)
-	return hex.EncodeToString(bytes)
-}
\ No newline at end of file
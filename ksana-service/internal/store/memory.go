@@ -0,0 +1,285 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"ksana-service/internal/labels"
+	"ksana-service/internal/model"
+	"sort"
+	"time"
+)
+
+type memState struct {
+	data     *model.JobStore
+	defMap   map[string]*model.JobDefinition
+	position map[string]int
+
+	// labelIndex 为 标签键 -> 标签值 -> 任务 ID 集合，用于选择器查询时避免扫描全部任务
+	labelIndex map[string]map[string]map[string]struct{}
+}
+
+func newEmptyJobStore() *model.JobStore {
+	return &model.JobStore{
+		Version:   model.StoreVersion,
+		UpdatedAt: time.Now().UTC(),
+		Jobs:      []model.JobDefinition{},
+		States:    map[string]model.JobState{},
+	}
+}
+
+func (m *memState) reset(jobStore *model.JobStore) {
+	if jobStore.States == nil {
+		jobStore.States = map[string]model.JobState{}
+	}
+	for _, def := range jobStore.Jobs {
+		if def.ResourceVersion > jobStore.ResourceVersion {
+			jobStore.ResourceVersion = def.ResourceVersion
+		}
+	}
+	m.data = jobStore
+	m.rebuildIndex()
+}
+
+// stamp 为即将写入的定义分配新的资源版本号，版本号在整个存储内单调递增
+func (m *memState) stamp(def *model.JobDefinition) {
+	if m.data != nil {
+		def.ResourceVersion = m.data.ResourceVersion + 1
+	}
+}
+
+func (m *memState) checkVersion(id string, expected int64) error {
+	def, exists := m.defMap[id]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	if def.ResourceVersion != expected {
+		return fmt.Errorf("%w: job %s is at version %d, expected %d", ErrConflict, id, def.ResourceVersion, expected)
+	}
+	return nil
+}
+
+func (m *memState) export() ([]byte, error) {
+	if m.data == nil {
+		return nil, fmt.Errorf("store not loaded")
+	}
+
+	return json.MarshalIndent(m.data, "", "  ")
+}
+
+func (m *memState) list() ([]model.Job, error) {
+	if m.data == nil {
+		return nil, fmt.Errorf("store not loaded")
+	}
+
+	jobs := make([]model.Job, 0, len(m.data.Jobs))
+	for _, def := range m.data.Jobs {
+		jobs = append(jobs, model.Job{JobDefinition: def, JobState: m.data.States[def.ID]})
+	}
+	return jobs, nil
+}
+
+func (m *memState) listDefinitions() ([]model.JobDefinition, error) {
+	if m.data == nil {
+		return nil, fmt.Errorf("store not loaded")
+	}
+
+	defs := make([]model.JobDefinition, len(m.data.Jobs))
+	copy(defs, m.data.Jobs)
+	return defs, nil
+}
+
+func (m *memState) get(id string) (*model.Job, error) {
+	def, exists := m.defMap[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	return &model.Job{JobDefinition: *def, JobState: m.data.States[id]}, nil
+}
+
+func (m *memState) put(job *model.Job) error {
+	if err := m.putDefinition(&job.JobDefinition); err != nil {
+		return err
+	}
+
+	m.data.States[job.ID] = job.JobState
+	return nil
+}
+
+func (m *memState) putDefinition(def *model.JobDefinition) error {
+	if m.data == nil {
+		return fmt.Errorf("store not loaded")
+	}
+
+	if def.ID == "" {
+		def.ID = generateID()
+	}
+	// 旧版本写入的日志记录没有命名空间
+	if def.Namespace == "" {
+		def.Namespace = model.DefaultNamespace
+	}
+
+	if def.ResourceVersion > m.data.ResourceVersion {
+		m.data.ResourceVersion = def.ResourceVersion
+	}
+
+	if existing, exists := m.defMap[def.ID]; exists {
+		m.unindexLabels(existing.ID, existing.Labels)
+		*existing = *def
+		m.indexLabels(existing.ID, existing.Labels)
+		return nil
+	}
+
+	m.data.Jobs = append(m.data.Jobs, *def)
+	m.rebuildIndex()
+	return nil
+}
+
+func (m *memState) updateState(id string, update func(*model.JobState)) (model.JobState, error) {
+	if m.data == nil {
+		return model.JobState{}, fmt.Errorf("store not loaded")
+	}
+
+	if _, exists := m.defMap[id]; !exists {
+		return model.JobState{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	state := m.data.States[id]
+	update(&state)
+	m.data.States[id] = state
+	return state, nil
+}
+
+func (m *memState) setState(id string, state model.JobState) {
+	if _, exists := m.defMap[id]; exists {
+		m.data.States[id] = state
+	}
+}
+
+func (m *memState) delete(id string) error {
+	if m.data == nil {
+		return fmt.Errorf("store not loaded")
+	}
+
+	if _, exists := m.defMap[id]; !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	var newJobs []model.JobDefinition
+	for _, def := range m.data.Jobs {
+		if def.ID != id {
+			newJobs = append(newJobs, def)
+		}
+	}
+
+	m.data.Jobs = newJobs
+	delete(m.data.States, id)
+	m.rebuildIndex()
+	return nil
+}
+
+func (m *memState) rebuildIndex() {
+	m.defMap = make(map[string]*model.JobDefinition)
+	m.position = make(map[string]int)
+	m.labelIndex = make(map[string]map[string]map[string]struct{})
+	for i := range m.data.Jobs {
+		def := &m.data.Jobs[i]
+		m.defMap[def.ID] = def
+		m.position[def.ID] = i
+		m.indexLabels(def.ID, def.Labels)
+	}
+}
+
+func (m *memState) indexLabels(id string, labels map[string]string) {
+	for key, value := range labels {
+		values, exists := m.labelIndex[key]
+		if !exists {
+			values = make(map[string]map[string]struct{})
+			m.labelIndex[key] = values
+		}
+		ids, exists := values[value]
+		if !exists {
+			ids = make(map[string]struct{})
+			values[value] = ids
+		}
+		ids[id] = struct{}{}
+	}
+}
+
+func (m *memState) unindexLabels(id string, labels map[string]string) {
+	for key, value := range labels {
+		ids := m.labelIndex[key][value]
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(m.labelIndex[key], value)
+		}
+		if len(m.labelIndex[key]) == 0 {
+			delete(m.labelIndex, key)
+		}
+	}
+}
+
+// listSelected 先用可索引的条件（=、in、存在）求候选集合的交集，再逐个校验全部条件；
+// 只有不可索引的条件（!=、notin、不存在）时退化为全量扫描。结果保持存储中的顺序
+func (m *memState) listSelected(selector labels.Selector) ([]model.Job, error) {
+	if m.data == nil {
+		return nil, fmt.Errorf("store not loaded")
+	}
+	if selector.Empty() {
+		return m.list()
+	}
+
+	var candidates map[string]struct{}
+	for _, req := range selector {
+		if !req.Indexable() {
+			continue
+		}
+
+		matched := make(map[string]struct{})
+		for value, ids := range m.labelIndex[req.Key] {
+			if req.Operator != labels.OpExists && !req.Matches(map[string]string{req.Key: value}) {
+				continue
+			}
+			for id := range ids {
+				if candidates == nil {
+					matched[id] = struct{}{}
+				} else if _, ok := candidates[id]; ok {
+					matched[id] = struct{}{}
+				}
+			}
+		}
+		candidates = matched
+	}
+
+	var defs []*model.JobDefinition
+	if candidates == nil {
+		for i := range m.data.Jobs {
+			defs = append(defs, &m.data.Jobs[i])
+		}
+	} else {
+		for id := range candidates {
+			defs = append(defs, m.defMap[id])
+		}
+		sort.Slice(defs, func(i, j int) bool {
+			return m.position[defs[i].ID] < m.position[defs[j].ID]
+		})
+	}
+
+	jobs := []model.Job{}
+	for _, def := range defs {
+		if selector.Matches(def.Labels) {
+			jobs = append(jobs, model.Job{JobDefinition: *def, JobState: m.data.States[def.ID]})
+		}
+	}
+	return jobs, nil
+}
+
+func generateID() string {
+	bytes := make([]byte, 16)
+	io.ReadFull(rand.Reader, bytes)
+	return hex.EncodeToString(bytes)
+}
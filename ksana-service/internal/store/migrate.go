@@ -0,0 +1,233 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported store version")
+
+// migration 将 From 版本的原始文档升级到 From+1，返回人类可读的变更说明
+type migration struct {
+	From        int
+	Description string
+	Apply       func(doc map[string]interface{}) ([]string, error)
+}
+
+// migrations 必须按 From 升序排列且连续，Load 与 migrate 子命令共用同一条升级路径
+var migrations = []migration{
+	{From: 1, Description: "split runtime fields into per-job states", Apply: migrateV1ToV2},
+	{From: 2, Description: "assign jobs without a namespace to the default namespace", Apply: migrateV2ToV3},
+}
+
+type MigrationStep struct {
+	From        int
+	To          int
+	Description string
+	Changes     []string
+}
+
+type MigrationResult struct {
+	Path        string
+	FromVersion int
+	ToVersion   int
+	Steps       []MigrationStep
+	BackupPath  string
+}
+
+func (r *MigrationResult) Migrated() bool {
+	return len(r.Steps) > 0
+}
+
+// DecodeJobStore 解析任意受支持版本的存储内容，旧版本在内存中升级，不写回文件
+func DecodeJobStore(data []byte) (*model.JobStore, error) {
+	jobStore, _, err := decodeJobStore(data)
+	return jobStore, err
+}
+
+func decodeJobStore(data []byte) (*model.JobStore, *MigrationResult, error) {
+	migrated, result, err := migrateDocument(data)
+	if err != nil {
+		return nil, result, err
+	}
+
+	var jobStore model.JobStore
+	if err := json.Unmarshal(migrated, &jobStore); err != nil {
+		return nil, result, err
+	}
+	if jobStore.States == nil {
+		jobStore.States = map[string]model.JobState{}
+	}
+
+	return &jobStore, result, nil
+}
+
+func migrateDocument(data []byte) ([]byte, *MigrationResult, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	version := documentVersion(doc)
+	result := &MigrationResult{FromVersion: version, ToVersion: version}
+	if version > model.StoreVersion {
+		return nil, result, fmt.Errorf("%w: file is version %d, this build supports up to version %d", ErrUnsupportedVersion, version, model.StoreVersion)
+	}
+
+	for _, m := range migrations {
+		if m.From != result.ToVersion {
+			continue
+		}
+
+		changes, err := m.Apply(doc)
+		if err != nil {
+			return nil, result, fmt.Errorf("migration %d -> %d failed: %w", m.From, m.From+1, err)
+		}
+
+		result.ToVersion = m.From + 1
+		doc["version"] = result.ToVersion
+		result.Steps = append(result.Steps, MigrationStep{
+			From:        m.From,
+			To:          result.ToVersion,
+			Description: m.Description,
+			Changes:     changes,
+		})
+	}
+
+	if result.ToVersion != model.StoreVersion {
+		return nil, result, fmt.Errorf("%w: no migration path from version %d", ErrUnsupportedVersion, result.ToVersion)
+	}
+
+	if !result.Migrated() {
+		return data, result, nil
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, result, err
+	}
+	return migrated, result, nil
+}
+
+func documentVersion(doc map[string]interface{}) int {
+	// version 1 的文件从未写入有效版本号，缺省即视为 1
+	if version, ok := doc["version"].(float64); ok && version > 1 {
+		return int(version)
+	}
+	return 1
+}
+
+var legacyStateFields = []string{"last_run_at", "next_run_at", "last_status", "last_error"}
+
+func migrateV1ToV2(doc map[string]interface{}) ([]string, error) {
+	jobs, _ := doc["jobs"].([]interface{})
+	states := map[string]interface{}{}
+	var changes []string
+
+	for i, raw := range jobs {
+		job, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("jobs[%d] is not an object", i)
+		}
+		id, _ := job["id"].(string)
+		if id == "" {
+			return nil, fmt.Errorf("jobs[%d] has no id", i)
+		}
+
+		state := map[string]interface{}{}
+		var moved []string
+		for _, field := range legacyStateFields {
+			if value, exists := job[field]; exists {
+				delete(job, field)
+				if value != nil {
+					state[field] = value
+					moved = append(moved, field)
+				}
+			}
+		}
+
+		states[id] = state
+		if len(moved) > 0 {
+			changes = append(changes, fmt.Sprintf("job %s: moved %s into states", id, strings.Join(moved, ", ")))
+		}
+	}
+
+	doc["states"] = states
+	changes = append(changes, fmt.Sprintf("%d job definitions kept", len(jobs)))
+	return changes, nil
+}
+
+func migrateV2ToV3(doc map[string]interface{}) ([]string, error) {
+	jobs, _ := doc["jobs"].([]interface{})
+	assigned := 0
+
+	for i, raw := range jobs {
+		job, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("jobs[%d] is not an object", i)
+		}
+		if namespace, _ := job["namespace"].(string); namespace == "" {
+			job["namespace"] = model.DefaultNamespace
+			assigned++
+		}
+	}
+
+	return []string{fmt.Sprintf("%d jobs assigned to namespace %q", assigned, model.DefaultNamespace)}, nil
+}
+
+// backupBeforeMigration 在升级后的内容写回前保留原始文件
+func backupBeforeMigration(path string, data []byte, result *MigrationResult) error {
+	backupPath := fmt.Sprintf("%s.v%d.%s.bak", path, result.FromVersion, time.Now().UTC().Format("20060102T150405Z"))
+	if err := WriteFileAtomic(backupPath, data); err != nil {
+		return fmt.Errorf("failed to back up %s before migration: %w", path, err)
+	}
+
+	result.BackupPath = backupPath
+	return nil
+}
+
+// Migrate 检查数据目录下的存储文件并升级到当前版本；dryRun 时只返回计划，不写任何文件
+func Migrate(dataDir string, dryRun bool) ([]*MigrationResult, error) {
+	var results []*MigrationResult
+	for _, name := range []string{"jobs.json", "snapshot.json"} {
+		path := filepath.Join(dataDir, name)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return results, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		jobStore, result, err := decodeJobStore(data)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", path, err)
+		}
+		result.Path = path
+		results = append(results, result)
+
+		if dryRun || !result.Migrated() {
+			continue
+		}
+
+		if err := backupBeforeMigration(path, data, result); err != nil {
+			return results, err
+		}
+
+		migrated, err := json.MarshalIndent(jobStore, "", "  ")
+		if err != nil {
+			return results, fmt.Errorf("failed to marshal %s: %w", path, err)
+		}
+		if err := WriteFileAtomic(path, migrated); err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
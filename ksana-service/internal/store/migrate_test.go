@@ -0,0 +1,234 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrateDocument(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      string
+		fromSteps []int
+	}{
+		{
+			name: "v1 without version field",
+			input: `{
+				"jobs": [
+					{"id": "a", "name": "first", "last_run_at": "2024-01-01T00:00:00Z", "last_status": "success", "next_run_at": null},
+					{"id": "b", "name": "second", "namespace": "billing"}
+				]
+			}`,
+			want: `{
+				"version": 3,
+				"jobs": [
+					{"id": "a", "name": "first", "namespace": "default"},
+					{"id": "b", "name": "second", "namespace": "billing"}
+				],
+				"states": {
+					"a": {"last_run_at": "2024-01-01T00:00:00Z", "last_status": "success"},
+					"b": {}
+				}
+			}`,
+			fromSteps: []int{1, 2},
+		},
+		{
+			name: "v1 with explicit version",
+			input: `{
+				"version": 1,
+				"jobs": [{"id": "a", "name": "first", "last_error": "boom"}]
+			}`,
+			want: `{
+				"version": 3,
+				"jobs": [{"id": "a", "name": "first", "namespace": "default"}],
+				"states": {"a": {"last_error": "boom"}}
+			}`,
+			fromSteps: []int{1, 2},
+		},
+		{
+			name: "v2",
+			input: `{
+				"version": 2,
+				"jobs": [
+					{"id": "a", "name": "first"},
+					{"id": "b", "name": "second", "namespace": ""},
+					{"id": "c", "name": "third", "namespace": "ops"}
+				],
+				"states": {"a": {"last_status": "failed"}}
+			}`,
+			want: `{
+				"version": 3,
+				"jobs": [
+					{"id": "a", "name": "first", "namespace": "default"},
+					{"id": "b", "name": "second", "namespace": "default"},
+					{"id": "c", "name": "third", "namespace": "ops"}
+				],
+				"states": {"a": {"last_status": "failed"}}
+			}`,
+			fromSteps: []int{2},
+		},
+		{
+			name:  "empty v1 document",
+			input: `{}`,
+			want: `{
+				"version": 3,
+				"states": {}
+			}`,
+			fromSteps: []int{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrated, result, err := migrateDocument([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("migrateDocument: %v", err)
+			}
+			assertJSONEqual(t, migrated, tt.want)
+
+			var steps []int
+			for _, step := range result.Steps {
+				if step.To != step.From+1 {
+					t.Errorf("step %d -> %d is not a single version bump", step.From, step.To)
+				}
+				steps = append(steps, step.From)
+			}
+			if !reflect.DeepEqual(steps, tt.fromSteps) {
+				t.Errorf("steps from %v, want %v", steps, tt.fromSteps)
+			}
+			if result.ToVersion != 3 {
+				t.Errorf("ToVersion = %d, want 3", result.ToVersion)
+			}
+
+			// 已是当前版本的文档再次迁移应原样返回
+			again, result, err := migrateDocument(migrated)
+			if err != nil {
+				t.Fatalf("second migrateDocument: %v", err)
+			}
+			if result.Migrated() {
+				t.Errorf("second run applied %d steps, want none", len(result.Steps))
+			}
+			if string(again) != string(migrated) {
+				t.Errorf("second run changed the document:\n%s\nwant:\n%s", again, migrated)
+			}
+		})
+	}
+}
+
+func TestMigrateDocumentRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		unsupported bool
+	}{
+		{name: "future version", input: `{"version": 4, "jobs": []}`, unsupported: true},
+		{name: "far future version", input: `{"version": 99}`, unsupported: true},
+		{name: "v1 job without id", input: `{"jobs": [{"name": "anonymous"}]}`},
+		{name: "v1 job not an object", input: `{"jobs": ["a"]}`},
+		{name: "v2 job not an object", input: `{"version": 2, "jobs": [1]}`},
+		{name: "not json", input: `{broken`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := migrateDocument([]byte(tt.input))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := errors.Is(err, ErrUnsupportedVersion); got != tt.unsupported {
+				t.Errorf("errors.Is(err, ErrUnsupportedVersion) = %v, want %v (err: %v)", got, tt.unsupported, err)
+			}
+		})
+	}
+}
+
+func TestMigrateFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jobs.json")
+	original := []byte(`{"jobs": [{"id": "a", "name": "first", "last_status": "success"}]}`)
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := Migrate(dir, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(results) != 1 || !results[0].Migrated() || results[0].BackupPath != "" {
+		t.Fatalf("dry run results = %+v", results)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(original) {
+		t.Fatal("dry run modified the file")
+	}
+
+	results, err = Migrate(dir, false)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if len(results) != 1 || results[0].FromVersion != 1 || results[0].ToVersion != 3 {
+		t.Fatalf("results = %+v", results)
+	}
+	backup, err := os.ReadFile(results[0].BackupPath)
+	if err != nil {
+		t.Fatalf("read backup: %v", err)
+	}
+	if string(backup) != string(original) {
+		t.Errorf("backup content = %s, want the original file", backup)
+	}
+
+	jobStore, err := DecodeJobStore(mustReadFile(t, path))
+	if err != nil {
+		t.Fatalf("decode migrated file: %v", err)
+	}
+	if jobStore.Version != 3 || len(jobStore.Jobs) != 1 || jobStore.Jobs[0].Namespace != "default" {
+		t.Errorf("migrated store = %+v", jobStore)
+	}
+	if state := jobStore.States["a"]; state.LastStatus != "success" {
+		t.Errorf("state of job a = %+v, want last_status success", state)
+	}
+
+	results, err = Migrate(dir, false)
+	if err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+	if len(results) != 1 || results[0].Migrated() {
+		t.Errorf("second migrate results = %+v, want no steps", results)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": 4, "jobs": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(dir, false); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("future version: err = %v, want ErrUnsupportedVersion", err)
+	}
+}
+
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("result is not valid JSON: %v", err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("fixture is not valid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("migrated document:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -2,14 +2,28 @@ package store
 
 import (
 	"context"
+	"ksana-service/internal/labels"
 	"ksana-service/internal/model"
 )
 
 type Store interface {
 	Load(ctx context.Context) (*model.JobStore, error)
+	// Save 整体替换存储内容，用于从备份恢复；只读模式下同样允许，损坏的文件先改名隔离，写入成功后解除只读
 	Save(ctx context.Context, jobStore *model.JobStore) error
+	Export(ctx context.Context) ([]byte, error)
 	List(ctx context.Context) ([]model.Job, error)
+	ListBySelector(ctx context.Context, selector labels.Selector) ([]model.Job, error)
+	ListDefinitions(ctx context.Context) ([]model.JobDefinition, error)
 	Get(ctx context.Context, id string) (*model.Job, error)
 	Put(ctx context.Context, job *model.Job) error
+	PutDefinition(ctx context.Context, def *model.JobDefinition) error
+	CompareAndPutDefinition(ctx context.Context, def *model.JobDefinition, expectedVersion int64) error
+	UpdateState(ctx context.Context, id string, update func(*model.JobState)) (*model.JobState, error)
 	Delete(ctx context.Context, id string) error
-}
\ No newline at end of file
+	CompareAndDelete(ctx context.Context, id string, expectedVersion int64) error
+	// Batch 整批写入：任一任务版本不匹配时整批拒绝，否则所有变更作为一次落盘生效
+	Batch(ctx context.Context, ops []BatchOp) error
+	Status() Status
+	Feed() *Feed
+	Close() error
+}
@@ -0,0 +1,261 @@
+package trash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ksana-service/internal/model"
+	"ksana-service/internal/store"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("trash entry not found")
+
+type Entry struct {
+	Job       model.Job `json:"job"`
+	DeletedAt time.Time `json:"deleted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	KeyID     string    `json:"key_id,omitempty"`
+}
+
+type trashFile struct {
+	Version int     `json:"version"`
+	Entries []Entry `json:"entries"`
+}
+
+// Trash 保存软删除的任务（含运行状态），超过保留期后自动清除
+type Trash struct {
+	path      string
+	retention time.Duration
+	logger    *slog.Logger
+
+	mu      sync.Mutex
+	entries map[string]Entry
+	loaded  bool
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func New(dataDir string, retention time.Duration, logger *slog.Logger) *Trash {
+	return &Trash{
+		path:      filepath.Join(dataDir, "trash.json"),
+		retention: retention,
+		logger:    logger,
+		entries:   make(map[string]Entry),
+	}
+}
+
+// Enabled 为 false 时删除操作直接永久删除
+func (t *Trash) Enabled() bool {
+	return t.retention > 0
+}
+
+func (t *Trash) Start() {
+	if !t.Enabled() || t.stopCh != nil {
+		return
+	}
+
+	t.stopCh = make(chan struct{})
+	t.wg.Add(1)
+	go t.purgeLoop()
+}
+
+func (t *Trash) Stop() {
+	if t.stopCh == nil {
+		return
+	}
+	close(t.stopCh)
+	t.wg.Wait()
+	t.stopCh = nil
+}
+
+func (t *Trash) Put(job *model.Job, keyID string) (*Entry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.loadLocked(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	entry := Entry{
+		Job:       *job,
+		DeletedAt: now,
+		ExpiresAt: now.Add(t.retention),
+		KeyID:     keyID,
+	}
+	t.entries[job.ID] = entry
+
+	if err := t.saveLocked(); err != nil {
+		delete(t.entries, job.ID)
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (t *Trash) List() ([]Entry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.loadLocked(); err != nil {
+		return nil, err
+	}
+	t.purgeExpiredLocked()
+
+	entries := make([]Entry, 0, len(t.entries))
+	for _, entry := range t.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries, nil
+}
+
+func (t *Trash) Get(id string) (*Entry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.loadLocked(); err != nil {
+		return nil, err
+	}
+	t.purgeExpiredLocked()
+
+	entry, exists := t.entries[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return &entry, nil
+}
+
+func (t *Trash) Remove(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.loadLocked(); err != nil {
+		return err
+	}
+
+	entry, exists := t.entries[id]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	delete(t.entries, id)
+	if err := t.saveLocked(); err != nil {
+		t.entries[id] = entry
+		return err
+	}
+	return nil
+}
+
+func (t *Trash) purgeLoop() {
+	defer t.wg.Done()
+
+	interval := time.Hour
+	if t.retention < interval {
+		interval = t.retention
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		t.mu.Lock()
+		if err := t.loadLocked(); err != nil {
+			t.logger.Error("Failed to load trash", "error", err)
+		} else {
+			t.purgeExpiredLocked()
+		}
+		t.mu.Unlock()
+	}
+}
+
+func (t *Trash) purgeExpiredLocked() {
+	now := time.Now()
+	var purged []string
+	for id, entry := range t.entries {
+		if now.After(entry.ExpiresAt) {
+			purged = append(purged, id)
+		}
+	}
+	if len(purged) == 0 {
+		return
+	}
+
+	removed := make(map[string]Entry, len(purged))
+	for _, id := range purged {
+		removed[id] = t.entries[id]
+		delete(t.entries, id)
+	}
+
+	if err := t.saveLocked(); err != nil {
+		for id, entry := range removed {
+			t.entries[id] = entry
+		}
+		t.logger.Error("Failed to purge expired trash entries", "error", err)
+		return
+	}
+
+	for _, id := range purged {
+		t.logger.Info("Purged expired job from trash", "job_id", id)
+	}
+}
+
+func (t *Trash) loadLocked() error {
+	if t.loaded {
+		return nil
+	}
+
+	data, err := os.ReadFile(t.path)
+	if os.IsNotExist(err) {
+		t.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read trash: %w", err)
+	}
+
+	var file trashFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse trash: %w", err)
+	}
+
+	for _, entry := range file.Entries {
+		t.entries[entry.Job.ID] = entry
+	}
+	t.loaded = true
+	return nil
+}
+
+func (t *Trash) saveLocked() error {
+	file := trashFile{Version: 1, Entries: make([]Entry, 0, len(t.entries))}
+	for _, entry := range t.entries {
+		file.Entries = append(file.Entries, entry)
+	}
+	sort.Slice(file.Entries, func(i, j int) bool {
+		return file.Entries[i].DeletedAt.Before(file.Entries[j].DeletedAt)
+	})
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal trash: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	return store.WriteFileAtomic(t.path, data)
+}
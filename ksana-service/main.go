@@ -4,9 +4,14 @@ import (
 	"ksana-service/internal"
 	"log"
 	"log/slog"
+	"os"
 )
 
 func main() {
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
 	config := internal.LoadConfigFromEnv()
 
 	service, err := internal.NewService(config)